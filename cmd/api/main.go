@@ -10,10 +10,12 @@ import (
 	"time"
 
 	"aws-dynamodb-store/internal/config"
+	"aws-dynamodb-store/internal/repository"
 	"aws-dynamodb-store/internal/server"
 	"aws-dynamodb-store/internal/service"
 
 	dynamodbrepo "aws-dynamodb-store/internal/repository/dynamodb"
+	memoryrepo "aws-dynamodb-store/internal/repository/memory"
 )
 
 func gracefulShutdown(apiServer *http.Server, done chan bool) {
@@ -50,15 +52,42 @@ func main() {
 
 	log.Printf("Log level set to: %s", appCfg.LogLevel)
 	log.Printf("Server starting on port: %d", appCfg.ServerPort)
-	log.Printf("Using DynamoDB table: %s in region: %s", appCfg.DynamoDB.TableName, appCfg.DynamoDB.AWSRegion)
-	if appCfg.DynamoDB.UseDynamoDBLocal {
-		log.Printf("Connecting to DynamoDB Local at: %s", appCfg.DynamoDB.DynamoDBLocalURL)
+
+	var repository repository.Repository
+	if appCfg.Backend == config.BackendMemory {
+		log.Println("Using the in-memory backend, data is lost on exit")
+		repository = memoryrepo.NewMemoryRepository(time.Duration(appCfg.DynamoDB.SoftDeleteRetentionDays) * 24 * time.Hour)
+	} else {
+		log.Printf("Using DynamoDB table: %s in region: %s", appCfg.DynamoDB.TableName, appCfg.DynamoDB.AWSRegion)
+		if appCfg.DynamoDB.UseDynamoDBLocal {
+			log.Printf("Connecting to DynamoDB Local at: %s", appCfg.DynamoDB.DynamoDBLocalURL)
+		}
+		client := dynamodbrepo.NewDynamoDBClient(appCfg.DynamoDB)
+		if appCfg.DynamoDB.EnsureSchema {
+			report, err := dynamodbrepo.EnsureSchema(context.Background(), client, appCfg.DynamoDB)
+			if err != nil {
+				log.Fatalf("Failed to provision table %s: %v", appCfg.DynamoDB.TableName, err)
+			}
+			log.Print(report)
+		}
+		repository, err = dynamodbrepo.NewDynamoDBRepository(context.Background(), client, appCfg.DynamoDB)
+		if err != nil {
+			log.Fatalf("Failed to open table %s: %v", appCfg.DynamoDB.TableName, err)
+		}
 	}
 
-	repository := dynamodbrepo.NewDynamoDBRepository(appCfg.DynamoDB)
+	var options []service.Option
+	if appCfg.AsyncDerivedData {
+		log.Println("Derived data of role, permission and group holders is left to the stream processor")
+		options = append(options, service.WithDeferredFanOut())
+	}
+	if appCfg.CacheTTLSeconds > 0 {
+		log.Printf("Caching role and grant lookups for %ds, at most %d entries each", appCfg.CacheTTLSeconds, appCfg.CacheMaxEntries)
+		options = append(options, service.WithCache(time.Duration(appCfg.CacheTTLSeconds)*time.Second, appCfg.CacheMaxEntries))
+	}
 
 	services := &service.Service{
-		RBACService: service.NewRBACService(repository),
+		RBACService: service.NewRBACService(repository, options...),
 	}
 
 	server := server.NewServer(*appCfg, repository, services)
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"aws-dynamodb-store/internal/config"
+	"aws-dynamodb-store/internal/domain"
+
+	dynamodbrepo "aws-dynamodb-store/internal/repository/dynamodb"
+)
+
+func main() {
+	tenant := flag.String("tenant", "", "tenant to move unscoped items into (default DYNAMODB_DEFAULT_TENANT)")
+	dryRun := flag.Bool("dry-run", false, "only report the items to move, do not move them")
+	repairOnly := flag.Bool("repair-only", false, "only repair mis-keyed role and permission items in place, do not move anything into a tenant")
+	flag.Parse()
+
+	appCfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if *repairOnly {
+		repair(appCfg.DynamoDB, *dryRun)
+		return
+	}
+	if *tenant == "" {
+		*tenant = appCfg.DynamoDB.DefaultTenant
+	}
+	if *tenant == "" {
+		log.Fatal("No tenant given, set -tenant or DYNAMODB_DEFAULT_TENANT")
+	}
+
+	log.Printf("Using DynamoDB table: %s in region: %s", appCfg.DynamoDB.TableName, appCfg.DynamoDB.AWSRegion)
+
+	client := dynamodbrepo.NewDynamoDBClient(appCfg.DynamoDB)
+
+	report, err := dynamodbrepo.MigrateTenant(context.Background(), client, appCfg.DynamoDB.TableName, domain.TenantID(*tenant), *dryRun)
+	if report != nil {
+		log.Printf("Scanned %d items, found %d unscoped", report.Scanned, len(report.Actions))
+		for _, action := range report.Actions {
+			log.Print(action.String())
+		}
+		for _, key := range report.Skipped {
+			log.Printf("Skipped %s: not an RBAC item", key)
+		}
+		for _, key := range report.Unclassified {
+			log.Printf("Left %s", key)
+		}
+		if !*dryRun {
+			log.Printf("Moved %d of %d items into tenant %s", report.Applied, len(report.Actions), *tenant)
+		}
+	}
+	if err != nil {
+		log.Fatalf("Migration failed: %v", err)
+	}
+}
+
+func repair(cfg config.DynamoDBConfig, dryRun bool) {
+	log.Printf("Using DynamoDB table: %s in region: %s", cfg.TableName, cfg.AWSRegion)
+
+	client := dynamodbrepo.NewDynamoDBClient(cfg)
+
+	report, err := dynamodbrepo.RepairTable(context.Background(), client, cfg.TableName, dryRun)
+	if report != nil {
+		log.Printf("Scanned %d items, found %d inconsistent", report.Scanned, len(report.Actions))
+		for _, action := range report.Actions {
+			log.Print(action.String())
+		}
+		for _, key := range report.Unclassified {
+			log.Printf("Left %s", key)
+		}
+		if !dryRun {
+			log.Printf("Repaired %d of %d items", report.Applied, len(report.Actions))
+		}
+	}
+	if err != nil {
+		log.Fatalf("Repair failed: %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"aws-dynamodb-store/internal/config"
+	"aws-dynamodb-store/internal/processor"
+	"aws-dynamodb-store/internal/service"
+
+	dynamodbrepo "aws-dynamodb-store/internal/repository/dynamodb"
+)
+
+func main() {
+	fromLatest := flag.Bool("from-latest", false, "skip records written before startup")
+	flag.Parse()
+
+	appCfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	log.Printf("Using DynamoDB table: %s in region: %s", appCfg.DynamoDB.TableName, appCfg.DynamoDB.AWSRegion)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	source, err := dynamodbrepo.NewStreamsSource(ctx, appCfg.DynamoDB)
+	if err != nil {
+		log.Fatalf("Failed to open table stream: %v", err)
+	}
+	source.FromLatest = *fromLatest
+
+	repo, err := dynamodbrepo.NewDynamoDBRepository(ctx, dynamodbrepo.NewDynamoDBClient(appCfg.DynamoDB), appCfg.DynamoDB)
+	if err != nil {
+		log.Fatalf("Failed to open table %s: %v", appCfg.DynamoDB.TableName, err)
+	}
+	// The processor itself recomputes synchronously.
+	rbacService := service.NewRBACService(repo)
+
+	log.Println("Processing table stream")
+	err = processor.New(rbacService).Run(ctx, source)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		log.Fatalf("Processor failed: %v", err)
+	}
+	log.Println("Processor stopped")
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"aws-dynamodb-store/internal/config"
+
+	dynamodbrepo "aws-dynamodb-store/internal/repository/dynamodb"
+)
+
+func main() {
+	appCfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	log.Printf("Using DynamoDB table: %s in region: %s", appCfg.DynamoDB.TableName, appCfg.DynamoDB.AWSRegion)
+
+	client := dynamodbrepo.NewDynamoDBClient(appCfg.DynamoDB)
+
+	report, err := dynamodbrepo.EnsureSchema(context.Background(), client, appCfg.DynamoDB)
+	if report != nil {
+		log.Print(report)
+	}
+	if err != nil {
+		log.Fatalf("Schema provisioning failed: %v", err)
+	}
+	// Existing indexes are not changed, so they may still differ.
+	if err := dynamodbrepo.VerifySchema(context.Background(), client, appCfg.DynamoDB); err != nil {
+		log.Fatal(err)
+	}
+}
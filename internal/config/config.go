@@ -9,12 +9,28 @@ import (
 	"github.com/joho/godotenv" // Optional: For loading .env files during local development
 )
 
+// Storage backends selectable with REPOSITORY_BACKEND.
+const (
+	BackendDynamoDB = "dynamodb"
+	BackendMemory   = "memory"
+)
+
 // AppConfig holds all configuration for the application.
 type AppConfig struct {
 	ServerPort int
 	DynamoDB   DynamoDBConfig
 	Auth       AuthConfig
 	LogLevel   string
+	// Storage backend: "dynamodb", or "memory" for local demos without a table.
+	// The memory backend keeps nothing across restarts.
+	Backend string
+	// Leave recomputing derived data of role, permission and group holders to
+	// the stream processor (cmd/processor) instead of the request.
+	AsyncDerivedData bool
+	// How long access checks may reuse the roles of a user and the grants of a
+	// role, 0 to disable the cache, and how many of each are kept.
+	CacheTTLSeconds int
+	CacheMaxEntries int
 	// Add other application-specific configurations here
 }
 
@@ -25,6 +41,14 @@ type DynamoDBConfig struct {
 	EntityTypeIndex  string
 	UseDynamoDBLocal bool   // To switch to DynamoDB Local for testing/development
 	DynamoDBLocalURL string // URL for DynamoDB Local (e.g., http://localhost:8000)
+	// How long soft-deleted users, roles and permissions stay restorable before
+	// the table TTL reaps them and their edges.
+	SoftDeleteRetentionDays int
+	// Create the table, its indexes and TTL at startup where they are missing.
+	EnsureSchema bool
+	// Tenant the migration moves items written before keys were
+	// tenant-scoped into, unless another one is given.
+	DefaultTenant string
 	// You might add Read/Write capacity settings if using provisioned mode and managing it here
 }
 
@@ -47,14 +71,21 @@ func LoadConfig() (*AppConfig, error) {
 	}
 
 	appCfg := &AppConfig{
-		ServerPort: getEnvAsInt("SERVER_PORT", 8080),
-		LogLevel:   getEnv("LOG_LEVEL", "info"),
+		ServerPort:       getEnvAsInt("SERVER_PORT", 8080),
+		LogLevel:         getEnv("LOG_LEVEL", "info"),
+		Backend:          getEnv("REPOSITORY_BACKEND", BackendDynamoDB),
+		AsyncDerivedData: getEnvAsBool("ASYNC_DERIVED_DATA", false),
+		CacheTTLSeconds:  getEnvAsInt("CACHE_TTL_SECONDS", 0),
+		CacheMaxEntries:  getEnvAsInt("CACHE_MAX_ENTRIES", 10000),
 		DynamoDB: DynamoDBConfig{
-			AWSRegion:        getEnv("AWS_REGION", "us-east-1"), // Default to a common region
-			TableName:        getEnv("DYNAMODB_TABLE_NAME", "Resources"),
-			EntityTypeIndex:  getEnv("DYNAMODB_ENTITY_TYPE_GSI_NAME", "EntityTypeIndex"),
-			UseDynamoDBLocal: getEnvAsBool("DYNAMODB_USE_LOCAL", false),
-			DynamoDBLocalURL: getEnv("DYNAMODB_LOCAL_URL", "http://localhost:8000"),
+			AWSRegion:               getEnv("AWS_REGION", "us-east-1"), // Default to a common region
+			TableName:               getEnv("DYNAMODB_TABLE_NAME", "Resources"),
+			EntityTypeIndex:         getEnv("DYNAMODB_ENTITY_TYPE_GSI_NAME", "EntityTypeIndex"),
+			UseDynamoDBLocal:        getEnvAsBool("DYNAMODB_USE_LOCAL", false),
+			DynamoDBLocalURL:        getEnv("DYNAMODB_LOCAL_URL", "http://localhost:8000"),
+			SoftDeleteRetentionDays: getEnvAsInt("DYNAMODB_SOFT_DELETE_RETENTION_DAYS", 30),
+			EnsureSchema:            getEnvAsBool("DYNAMODB_ENSURE_SCHEMA", false),
+			DefaultTenant:           getEnv("DYNAMODB_DEFAULT_TENANT", ""),
 		},
 		Auth: AuthConfig{
 			JWTSecret:      getEnv("JWT_SECRET", "a_very_secure_secret_key_please_change_me"), // CHANGE THIS!
@@ -66,6 +97,9 @@ func LoadConfig() (*AppConfig, error) {
 	if appCfg.Auth.JWTSecret == "a_very_secure_secret_key_please_change_me" && os.Getenv("APP_ENV") == "production" {
 		log.Println("CRITICAL WARNING: Default JWT_SECRET is being used in a production-like environment. Please set a strong, unique secret.")
 	}
+	if appCfg.Backend != BackendDynamoDB && appCfg.Backend != BackendMemory {
+		log.Fatalf("FATAL: unknown REPOSITORY_BACKEND %q, use %s or %s.", appCfg.Backend, BackendDynamoDB, BackendMemory)
+	}
 	if appCfg.DynamoDB.TableName == "" {
 		log.Fatal("FATAL: DYNAMODB_TABLE_NAME environment variable is not set.")
 	}
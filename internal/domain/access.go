@@ -0,0 +1,35 @@
+package domain
+
+// Attributes the server derives for every check. Conditions can rely on them
+// because callers cannot set them.
+const (
+	AttributeRequestIP      = "request.ip"
+	AttributeRequestTime    = "request.time"
+	AttributeRequestWeekday = "request.weekday"
+)
+
+// ServerAttribute reports whether the attribute name is derived by the server.
+func ServerAttribute(name string) bool {
+	return name == AttributeRequestIP || name == AttributeRequestTime || name == AttributeRequestWeekday
+}
+
+// AccessRequest asks whether a user holds a permission, optionally on a
+// resource. Attributes holds the request.* and resource.* attributes that the
+// conditions of the user's grants are evaluated against. SourceIP is the
+// address the check came from; it becomes request.ip.
+type AccessRequest struct {
+	UserID       UserID
+	PermissionID PermissionID
+	ResourceID   string
+	Attributes   map[string]string
+	SourceIP     string
+}
+
+// Decision is the outcome of an AccessRequest. DeniedBy is the deny grant
+// that applied. FailedCondition is the comparison that kept the most specific
+// matching allow grant from applying.
+type Decision struct {
+	Allowed         bool         `json:"allowed"`
+	DeniedBy        PermissionID `json:"deniedBy,omitempty"`
+	FailedCondition string       `json:"failedCondition,omitempty"`
+}
@@ -0,0 +1,30 @@
+package domain
+
+import "time"
+
+// RoleAssignment grants a role to a user, on every resource or, when
+// ResourceID is set, on that resource only. NotBefore and ExpiresAt optionally
+// bound the window in which the assignment is in effect. AssignedBy, Reason
+// and TicketRef record who granted it and why.
+type RoleAssignment struct {
+	UserID     UserID     `json:"userId"`
+	RoleID     RoleID     `json:"roleId"`
+	ResourceID string     `json:"resourceId,omitempty"`
+	AssignedAt time.Time  `json:"assignedAt"`
+	AssignedBy UserID     `json:"assignedBy,omitempty"`
+	Reason     string     `json:"reason,omitempty"`
+	TicketRef  string     `json:"ticketRef,omitempty"`
+	NotBefore  *time.Time `json:"notBefore,omitempty"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+}
+
+// ActiveAt reports whether the assignment is in effect at t.
+func (a *RoleAssignment) ActiveAt(t time.Time) bool {
+	if a.NotBefore != nil && t.Before(*a.NotBefore) {
+		return false
+	}
+	if a.ExpiresAt != nil && !t.Before(*a.ExpiresAt) {
+		return false
+	}
+	return true
+}
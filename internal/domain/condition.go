@@ -0,0 +1,270 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// A Condition restricts a permission grant to checks whose attributes satisfy
+// it. It is one or more comparisons joined by &&:
+//
+//	request.ip in "10.8.0.0/16" && request.time >= "09:00" && request.time < "17:00"
+//	user.department == resource.department
+//
+// Operands are quoted literals or attribute names. user.* attributes come from
+// the stored user, request.* and resource.* attributes from the check. The
+// ordering operators compare numbers when both operands parse as numbers, and
+// strings otherwise, so times must be zero-padded HH:MM. in tests whether an
+// IP lies in a CIDR block, or else whether the value is one of a
+// comma-separated list. A comparison referencing a missing attribute is
+// undetermined, see ErrUndetermined.
+type Condition string
+
+// ErrUndetermined is returned by Evaluate when the condition references an
+// attribute the check does not have and none of its other comparisons fails.
+// The condition neither holds nor fails: an allow must not apply, a deny must.
+var ErrUndetermined = errors.New("condition references a missing attribute")
+
+// Attribute namespaces a condition may reference.
+var conditionNamespaces = []string{"user.", "request.", "resource."}
+
+var conditionOperators = map[string]bool{
+	"==": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true, "in": true,
+}
+
+type conditionOperand struct {
+	literal bool
+	value   string // The literal, or the attribute name
+}
+
+func (o conditionOperand) String() string {
+	if o.literal {
+		return fmt.Sprintf("%q", o.value)
+	}
+	return o.value
+}
+
+func (o conditionOperand) resolve(attributes map[string]string) (string, bool) {
+	if o.literal {
+		return o.value, true
+	}
+	value, ok := attributes[o.value]
+	return value, ok
+}
+
+type comparison struct {
+	left     conditionOperand
+	operator string
+	right    conditionOperand
+}
+
+func (c comparison) String() string {
+	return c.left.String() + " " + c.operator + " " + c.right.String()
+}
+
+// holds reports whether the comparison holds. ok is false when an operand
+// references a missing attribute.
+func (c comparison) holds(attributes map[string]string) (holds bool, ok bool) {
+	left, ok := c.left.resolve(attributes)
+	if !ok {
+		return false, false
+	}
+	right, ok := c.right.resolve(attributes)
+	if !ok {
+		return false, false
+	}
+
+	switch c.operator {
+	case "==":
+		return left == right, true
+	case "!=":
+		return left != right, true
+	case "<":
+		return compareOperands(left, right) < 0, true
+	case "<=":
+		return compareOperands(left, right) <= 0, true
+	case ">":
+		return compareOperands(left, right) > 0, true
+	case ">=":
+		return compareOperands(left, right) >= 0, true
+	case "in":
+		return within(left, right), true
+	}
+	return false, true
+}
+
+// compareOperands orders a and b as numbers when both parse as one, and as
+// strings otherwise.
+func compareOperands(a string, b string) int {
+	x, errX := strconv.ParseFloat(a, 64)
+	y, errY := strconv.ParseFloat(b, 64)
+	if errX != nil || errY != nil {
+		return strings.Compare(a, b)
+	}
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+func within(value string, set string) bool {
+	if _, block, err := net.ParseCIDR(set); err == nil {
+		ip := net.ParseIP(value)
+		return ip != nil && block.Contains(ip)
+	}
+	for _, member := range strings.Split(set, ",") {
+		if strings.TrimSpace(member) == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate reports whether the condition parses. The empty condition is valid
+// and always holds.
+func (c Condition) Validate() error {
+	_, err := c.parse()
+	return err
+}
+
+// Evaluate reports whether attributes satisfy the condition. If they don't,
+// failed is the first comparison that did not hold. When no comparison fails
+// but one references a missing attribute, ok is false, failed is that
+// comparison and err wraps ErrUndetermined.
+func (c Condition) Evaluate(attributes map[string]string) (ok bool, failed string, err error) {
+	comparisons, err := c.parse()
+	if err != nil {
+		return false, string(c), err
+	}
+	undetermined := ""
+	for _, comparison := range comparisons {
+		holds, determined := comparison.holds(attributes)
+		switch {
+		case !determined:
+			if undetermined == "" {
+				undetermined = comparison.String()
+			}
+		case !holds:
+			return false, comparison.String(), nil
+		}
+	}
+	if undetermined != "" {
+		return false, undetermined, fmt.Errorf("%w: %s", ErrUndetermined, undetermined)
+	}
+	return true, "", nil
+}
+
+func (c Condition) parse() ([]comparison, error) {
+	if strings.TrimSpace(string(c)) == "" {
+		return nil, nil
+	}
+	tokens, err := tokenizeCondition(string(c))
+	if err != nil {
+		return nil, err
+	}
+
+	var comparisons []comparison
+	for len(tokens) > 0 {
+		if len(tokens) < 3 {
+			return nil, fmt.Errorf("incomplete comparison at %q", joinTokens(tokens))
+		}
+		left, err := tokens[0].operand()
+		if err != nil {
+			return nil, err
+		}
+		operator := tokens[1]
+		if operator.literal || !conditionOperators[operator.text] {
+			return nil, fmt.Errorf("unknown operator %q", operator.text)
+		}
+		right, err := tokens[2].operand()
+		if err != nil {
+			return nil, err
+		}
+		comparisons = append(comparisons, comparison{left: left, operator: operator.text, right: right})
+
+		tokens = tokens[3:]
+		if len(tokens) == 0 {
+			break
+		}
+		if tokens[0].literal || tokens[0].text != "&&" {
+			return nil, fmt.Errorf("expected && before %q", tokens[0].text)
+		}
+		tokens = tokens[1:]
+		if len(tokens) == 0 {
+			return nil, fmt.Errorf("condition ends with &&")
+		}
+	}
+	return comparisons, nil
+}
+
+type conditionToken struct {
+	text    string
+	literal bool
+}
+
+func (t conditionToken) operand() (conditionOperand, error) {
+	if t.literal {
+		return conditionOperand{literal: true, value: t.text}, nil
+	}
+	for _, namespace := range conditionNamespaces {
+		if strings.HasPrefix(t.text, namespace) && len(t.text) > len(namespace) {
+			return conditionOperand{value: t.text}, nil
+		}
+	}
+	return conditionOperand{}, fmt.Errorf("%q is neither a quoted literal nor a user., request. or resource. attribute", t.text)
+}
+
+func joinTokens(tokens []conditionToken) string {
+	texts := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		texts = append(texts, token.text)
+	}
+	return strings.Join(texts, " ")
+}
+
+func tokenizeCondition(s string) ([]conditionToken, error) {
+	const operatorChars = "=!<>&"
+
+	var tokens []conditionToken
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(s[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at offset %d", i)
+			}
+			tokens = append(tokens, conditionToken{text: s[i+1 : i+1+end], literal: true})
+			i += end + 2
+		case strings.IndexByte(operatorChars, c) >= 0:
+			j := i
+			for j < len(s) && strings.IndexByte(operatorChars, s[j]) >= 0 {
+				j++
+			}
+			tokens = append(tokens, conditionToken{text: s[i:j]})
+			i = j
+		case isNameChar(c):
+			j := i
+			for j < len(s) && isNameChar(s[j]) {
+				j++
+			}
+			tokens = append(tokens, conditionToken{text: s[i:j]})
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected %q at offset %d", c, i)
+		}
+	}
+	return tokens, nil
+}
+
+func isNameChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '_' || c == '-'
+}
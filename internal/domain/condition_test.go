@@ -0,0 +1,64 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestConditionEvaluate(t *testing.T) {
+	attributes := map[string]string{
+		"request.ip":          "10.8.3.4",
+		"request.time":        "10:30",
+		"request.weekday":     "Tue",
+		"request.amount":      "900",
+		"user.department":     "finance",
+		"resource.department": "finance",
+	}
+
+	tests := []struct {
+		condition        Condition
+		want             bool
+		wantFailed       string
+		wantUndetermined bool
+	}{
+		{"", true, "", false},
+		{`request.ip in "10.8.0.0/16"`, true, "", false},
+		{`request.ip in "192.168.0.0/24"`, false, `request.ip in "192.168.0.0/24"`, false},
+		{`request.time >= "09:00" && request.time < "17:00"`, true, "", false},
+		{`request.time >= "09:00" && request.time < '10:00'`, false, `request.time < "10:00"`, false},
+		{`request.weekday in "Mon,Tue,Wed,Thu,Fri"`, true, "", false},
+		{"user.department == resource.department", true, "", false},
+		{`user.department != "finance"`, false, `user.department != "finance"`, false},
+		{`request.amount < "1000"`, true, "", false}, // Numeric, lexically "900" > "1000"
+		{`request.amount >= "1e3"`, false, `request.amount >= "1e3"`, false},
+		{`resource.owner == "u1"`, false, `resource.owner == "u1"`, true},
+		{`resource.owner == "u1" && user.department == "legal"`, false, `user.department == "legal"`, false},
+	}
+
+	for _, tt := range tests {
+		ok, failed, err := tt.condition.Evaluate(attributes)
+		if undetermined := errors.Is(err, ErrUndetermined); undetermined != tt.wantUndetermined || err != nil && !undetermined {
+			t.Errorf("%q: unexpected error %v", tt.condition, err)
+			continue
+		}
+		if ok != tt.want || failed != tt.wantFailed {
+			t.Errorf("%q: expected %v, %q; got %v, %q", tt.condition, tt.want, tt.wantFailed, ok, failed)
+		}
+	}
+}
+
+func TestConditionValidate(t *testing.T) {
+	invalid := []Condition{
+		`request.ip`,
+		`request.ip ~ "10.0.0.0/8"`,
+		`department == "finance"`,
+		`request.ip in "10.0.0.0/8" &&`,
+		`request.ip in "10.0.0.0/8" request.time < "17:00"`,
+		`request.time < "17:00`,
+	}
+	for _, condition := range invalid {
+		if err := condition.Validate(); err == nil {
+			t.Errorf("%q: expected an error", condition)
+		}
+	}
+}
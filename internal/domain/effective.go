@@ -0,0 +1,41 @@
+package domain
+
+import "time"
+
+// EffectivePermissions is the precomputed outcome of a user's global grants,
+// through its roles, its groups' roles and their ancestors. It is stored on the
+// user so most checks need a single read. Grants with a condition cannot be
+// precomputed, so permissions they match are listed in Conditional and
+// checked in full.
+type EffectivePermissions struct {
+	Allowed     []PermissionID
+	Denied      []PermissionID
+	Conditional []PermissionID
+	ComputedAt  time.Time
+	ValidUntil  *time.Time // When the next of the user's role assignments starts or expires
+}
+
+// Decide returns the decision for target at now, or nil when target has to be
+// checked in full because the permissions went stale or a conditional grant
+// matches it.
+func (e *EffectivePermissions) Decide(target PermissionID, now time.Time) *Decision {
+	if e.ValidUntil != nil && !now.Before(*e.ValidUntil) {
+		return nil
+	}
+	for _, p := range e.Conditional {
+		if p.Matches(target) {
+			return nil
+		}
+	}
+	for _, p := range e.Denied {
+		if p.Matches(target) {
+			return &Decision{DeniedBy: p}
+		}
+	}
+	for _, p := range e.Allowed {
+		if p.Matches(target) {
+			return &Decision{Allowed: true}
+		}
+	}
+	return &Decision{}
+}
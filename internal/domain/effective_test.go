@@ -0,0 +1,39 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEffectivePermissionsDecide(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	later := now.Add(time.Hour)
+	permissions := &EffectivePermissions{
+		Allowed:     []PermissionID{"account:*", "document:read"},
+		Denied:      []PermissionID{"account:finance"},
+		Conditional: []PermissionID{"prod:write"},
+		ValidUntil:  &later,
+	}
+
+	tests := []struct {
+		target PermissionID
+		want   *Decision
+	}{
+		{"document:read", &Decision{Allowed: true}},
+		{"account:sales", &Decision{Allowed: true}},
+		{"account:finance", &Decision{DeniedBy: "account:finance"}},
+		{"document:write", &Decision{}},
+		{"prod:write", nil},
+	}
+
+	for _, tt := range tests {
+		got := permissions.Decide(tt.target, now)
+		if (got == nil) != (tt.want == nil) || got != nil && *got != *tt.want {
+			t.Errorf("Decide(%q) = %+v; want %+v", tt.target, got, tt.want)
+		}
+	}
+
+	if got := permissions.Decide("document:read", later); got != nil {
+		t.Errorf("expected stale permissions to be inconclusive; got %+v", got)
+	}
+}
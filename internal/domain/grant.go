@@ -0,0 +1,21 @@
+package domain
+
+import "time"
+
+type Effect string
+
+const (
+	EffectAllow Effect = "allow"
+	EffectDeny  Effect = "deny"
+)
+
+// PermissionGrant attaches a permission to a role. A deny grant overrides
+// every allow of the permissions it matches, across all of a user's roles. A
+// grant with a Condition only applies to checks that satisfy it.
+type PermissionGrant struct {
+	RoleID       RoleID       `json:"roleId"`
+	PermissionID PermissionID `json:"permissionId"`
+	Effect       Effect       `json:"effect"`
+	Condition    Condition    `json:"condition,omitempty"`
+	AssignedAt   time.Time    `json:"assignedAt"`
+}
@@ -0,0 +1,14 @@
+package domain
+
+import "time"
+
+type GroupID string
+
+type Group struct {
+	ID          GroupID   `json:"id"`
+	DisplayName string    `json:"displayName"`
+	Description string    `json:"description,omitempty"`
+	MemberCount int       `json:"memberCount"` // Recounted after membership changes, may lag behind
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
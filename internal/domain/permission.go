@@ -10,4 +10,5 @@ type Permission struct {
 	Description string       `json:"description,omitempty"`
 	CreatedAt   time.Time    `json:"createdAt"`
 	UpdatedAt   time.Time    `json:"updatedAt"`
+	Version     int64        `json:"version"`
 }
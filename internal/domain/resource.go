@@ -0,0 +1,24 @@
+package domain
+
+import "strings"
+
+// Resources are identified by slash-delimited paths such as
+// org/acme/project/42/doc/7. A role assigned on a path applies to the path and
+// everything beneath it.
+
+// CleanResourcePath drops leading and trailing slashes.
+func CleanResourcePath(path string) string {
+	return strings.Trim(path, "/")
+}
+
+// ResourceCovers reports whether a grant on path applies to target, that is
+// whether path is target itself or one of its ancestors.
+func ResourceCovers(path string, target string) bool {
+	return path == target || strings.HasPrefix(target, path+"/")
+}
+
+// ResourceRoot returns the first segment of path.
+func ResourceRoot(path string) string {
+	root, _, _ := strings.Cut(path, "/")
+	return root
+}
@@ -0,0 +1,24 @@
+package domain
+
+import "testing"
+
+func TestResourceCovers(t *testing.T) {
+	tests := []struct {
+		path   string
+		target string
+		want   bool
+	}{
+		{"org/acme", "org/acme", true},
+		{"org/acme", "org/acme/project/42/doc/7", true},
+		{"org", "org/acme/project/42", true},
+		{"org/acme", "org/acme-corp", false},
+		{"org/acme/project/42", "org/acme", false},
+		{"org/other", "org/acme/project/42", false},
+	}
+
+	for _, tt := range tests {
+		if got := ResourceCovers(tt.path, tt.target); got != tt.want {
+			t.Errorf("ResourceCovers(%q, %q) = %v; want %v", tt.path, tt.target, got, tt.want)
+		}
+	}
+}
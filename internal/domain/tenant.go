@@ -0,0 +1,3 @@
+package domain
+
+type TenantID string
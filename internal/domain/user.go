@@ -5,9 +5,13 @@ import "time"
 type UserID string
 
 type User struct {
-	ID          UserID    `json:"id"`
-	DisplayName string    `json:"displayName"`
-	Email       string    `json:"email"`
-	CreatedAt   time.Time `json:"createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt"`
+	ID          UserID            `json:"id"`
+	DisplayName string            `json:"displayName"`
+	Email       string            `json:"email"`
+	Attributes  map[string]string `json:"attributes,omitempty"` // Referenced by grant conditions as user.<name>
+	CreatedAt   time.Time         `json:"createdAt"`
+	UpdatedAt   time.Time         `json:"updatedAt"`
+	Version     int64             `json:"version"`
+
+	Permissions *EffectivePermissions `json:"-"` // nil until computed
 }
@@ -0,0 +1,85 @@
+package domain
+
+import "strings"
+
+// Permission IDs are colon-delimited, e.g. billing:invoice:create. A permission
+// assigned to a role may use * as a whole segment to match any single segment
+// (*:read); a trailing * matches one or more segments (document:*), so * alone
+// matches every permission.
+
+const PermissionWildcard = "*"
+
+// IsPattern reports whether the permission ID contains wildcard segments.
+func (p PermissionID) IsPattern() bool {
+	return strings.Contains(string(p), PermissionWildcard)
+}
+
+// ValidPattern reports whether wildcards only appear as whole segments.
+func (p PermissionID) ValidPattern() bool {
+	for _, segment := range strings.Split(string(p), ":") {
+		if segment != PermissionWildcard && strings.Contains(segment, PermissionWildcard) {
+			return false
+		}
+	}
+	return true
+}
+
+// Matches reports whether the granted permission p covers target.
+func (p PermissionID) Matches(target PermissionID) bool {
+	if p == target {
+		return true
+	}
+	if !p.IsPattern() {
+		return false
+	}
+
+	patternSegments := strings.Split(string(p), ":")
+	targetSegments := strings.Split(string(target), ":")
+	for i, segment := range patternSegments {
+		if i >= len(targetSegments) {
+			return false
+		}
+		if segment == PermissionWildcard && i == len(patternSegments)-1 {
+			return true
+		}
+		if segment != PermissionWildcard && segment != targetSegments[i] {
+			return false
+		}
+	}
+	return len(patternSegments) == len(targetSegments)
+}
+
+// MoreSpecific reports whether p takes precedence over other when both match
+// the same permission. Exact IDs come first, then patterns with more literal
+// segments, then the pattern whose first wildcard comes later
+// (billing:invoice:* before *:invoice:create), then the longer pattern.
+func (p PermissionID) MoreSpecific(other PermissionID) bool {
+	if p.IsPattern() != other.IsPattern() {
+		return !p.IsPattern()
+	}
+
+	a := strings.Split(string(p), ":")
+	b := strings.Split(string(other), ":")
+	if literalA, literalB := literalSegments(a), literalSegments(b); literalA != literalB {
+		return literalA > literalB
+	}
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if (a[i] == PermissionWildcard) != (b[i] == PermissionWildcard) {
+			return b[i] == PermissionWildcard
+		}
+	}
+	if len(a) != len(b) {
+		return len(a) > len(b)
+	}
+	return p < other
+}
+
+func literalSegments(segments []string) int {
+	literal := 0
+	for _, segment := range segments {
+		if segment != PermissionWildcard {
+			literal++
+		}
+	}
+	return literal
+}
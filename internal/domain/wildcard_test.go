@@ -0,0 +1,64 @@
+package domain
+
+import "testing"
+
+func TestPermissionMatches(t *testing.T) {
+	tests := []struct {
+		granted PermissionID
+		target  PermissionID
+		want    bool
+	}{
+		{"document:read", "document:read", true},
+		{"document:read", "document:write", false},
+		{"document:*", "document:read", true},
+		{"document:*", "document:comment:create", true},
+		{"document:*", "document", false},
+		{"*:read", "document:read", true},
+		{"*:read", "document:write", false},
+		{"*:read", "billing:invoice:read", false},
+		{"billing:invoice:*", "billing:invoice:create", true},
+		{"billing:invoice:*", "billing:refund:create", false},
+		{"*", "billing:invoice:create", true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.granted.Matches(tt.target); got != tt.want {
+			t.Errorf("%q.Matches(%q) = %v; want %v", tt.granted, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestValidPattern(t *testing.T) {
+	for id, want := range map[PermissionID]bool{"document:*": true, "*:read": true, "*": true, "doc*:read": false, "document:re*": false} {
+		if got := id.ValidPattern(); got != want {
+			t.Errorf("%q.ValidPattern() = %v; want %v", id, got, want)
+		}
+	}
+}
+
+func TestPermissionMoreSpecific(t *testing.T) {
+	tests := []struct {
+		p, other PermissionID
+		want     bool
+	}{
+		{"document:read", "document:*", true}, // Exact before pattern
+		{"document:*", "document:read", false},
+		{"a:b:*", "a:*", true}, // More literal segments
+		{"a:*", "a:b:*", false},
+		{"billing:*:create", "*:invoice:*", true},
+		{"billing:invoice:*", "*:invoice:create", true}, // Later first wildcard
+		{"*:invoice:create", "billing:invoice:*", false},
+		{"document:*", "*:read", true},
+		{"a:*:*", "a:*", true}, // Longer pattern
+		{"a:*", "a:*:*", false},
+		{"a:*", "b:*", true}, // Equal specificity orders by ID
+		{"b:*", "a:*", false},
+		{"document:*", "document:*", false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.p.MoreSpecific(tt.other); got != tt.want {
+			t.Errorf("%q.MoreSpecific(%q) = %v; want %v", tt.p, tt.other, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+package model
+
+// AccessCheckInput asks whether the user holds PermissionID, optionally on
+// ResourceID. Attributes are keyed request.<name> or resource.<name>, except
+// request.ip, request.time and request.weekday, which the server sets.
+type AccessCheckInput struct {
+	PermissionID string            `json:"permissionId"`
+	ResourceID   string            `json:"resourceId,omitempty"`
+	Attributes   map[string]string `json:"attributes,omitempty"`
+}
@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+type RoleAssignmentInput struct {
+	RoleID     string     `json:"roleId"`
+	ResourceID string     `json:"resourceId,omitempty"`
+	Reason     string     `json:"reason,omitempty"`
+	TicketRef  string     `json:"ticketRef,omitempty"`
+	NotBefore  *time.Time `json:"notBefore,omitempty"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+}
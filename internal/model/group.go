@@ -0,0 +1,14 @@
+package model
+
+type GroupCreateInput struct {
+	DisplayName string `json:"displayName"`
+	Description string `json:"description,omitempty"`
+}
+
+type GroupMemberInput struct {
+	UserID string `json:"userId"`
+}
+
+type GroupRoleInput struct {
+	RoleID string `json:"roleId"`
+}
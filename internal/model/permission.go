@@ -0,0 +1,9 @@
+package model
+
+// PermissionUpdateInput changes the fields that are set. Version is the version
+// the client read and is required for optimistic concurrency control.
+type PermissionUpdateInput struct {
+	DisplayName *string `json:"displayName,omitempty"`
+	Description *string `json:"description,omitempty"`
+	Version     int64   `json:"version"`
+}
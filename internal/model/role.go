@@ -0,0 +1,21 @@
+package model
+
+// RoleUpdateInput changes the fields that are set. Version is the version the
+// client read and is required for optimistic concurrency control.
+type RoleUpdateInput struct {
+	DisplayName *string `json:"displayName,omitempty"`
+	Description *string `json:"description,omitempty"`
+	Version     int64   `json:"version"`
+}
+
+type ParentRoleInput struct {
+	ParentID string `json:"parentId"`
+}
+
+// RolePermissionInput grants a permission to a role. Effect is "allow" (the
+// default) or "deny". Condition restricts the grant to matching checks.
+type RolePermissionInput struct {
+	PermissionID string `json:"permissionId"`
+	Effect       string `json:"effect,omitempty"`
+	Condition    string `json:"condition,omitempty"`
+}
@@ -6,6 +6,16 @@ type UserCreateInput struct {
 	// Password    string `json:"password" validate:"required,min=8"` // Plain text password from client
 }
 
+// UserUpdateInput changes the fields that are set. Attributes, when set,
+// replace all of the user's attributes. Version is the version the client read
+// and is required for optimistic concurrency control.
+type UserUpdateInput struct {
+	DisplayName *string            `json:"name,omitempty"`
+	Email       *string            `json:"email,omitempty"`
+	Attributes  *map[string]string `json:"attributes,omitempty"`
+	Version     int64              `json:"version"`
+}
+
 type UserResponse struct {
 	ID          string `json:"id"` // EntityID
 	DisplayName string `json:"displayName,omitempty"`
@@ -0,0 +1,48 @@
+package processor
+
+import (
+	"context"
+	"sync"
+
+	dynamodbrepo "aws-dynamodb-store/internal/repository/dynamodb"
+)
+
+// ChannelSource is an in-process Source fed with Publish, for tests and local
+// runs without a stream.
+type ChannelSource struct {
+	batches chan []dynamodbrepo.StreamRecord
+	close   sync.Once
+}
+
+func NewChannelSource(buffer int) *ChannelSource {
+	return &ChannelSource{batches: make(chan []dynamodbrepo.StreamRecord, buffer)}
+}
+
+// Publish queues records as one batch. It blocks while the buffer is full.
+func (s *ChannelSource) Publish(records ...dynamodbrepo.StreamRecord) {
+	s.batches <- records
+}
+
+// Close makes Run return once the queued batches are handled.
+func (s *ChannelSource) Close() {
+	s.close.Do(func() { close(s.batches) })
+}
+
+// Run hands every published batch to handle. Retrying a failing batch is up
+// to handle, as with Processor.Run; when handle fails Run returns the error,
+// leaving the remaining batches queued.
+func (s *ChannelSource) Run(ctx context.Context, handle func(context.Context, []dynamodbrepo.StreamRecord) error) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case batch, ok := <-s.batches:
+			if !ok {
+				return nil
+			}
+			if err := handle(ctx, batch); err != nil {
+				return err
+			}
+		}
+	}
+}
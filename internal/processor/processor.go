@@ -0,0 +1,174 @@
+// Package processor maintains derived data, such as the effective permissions
+// stored on users and group member counts, from the change stream of the
+// table. It lets the API return as soon as an edge is written instead of
+// recomputing every affected user in the request.
+package processor
+
+import (
+	"aws-dynamodb-store/internal/domain"
+	"aws-dynamodb-store/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	dynamodbrepo "aws-dynamodb-store/internal/repository/dynamodb"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Source delivers batches of change records to handle until ctx is done or
+// the source is exhausted. A batch handle fails on is delivered again.
+type Source interface {
+	Run(ctx context.Context, handle func(context.Context, []dynamodbrepo.StreamRecord) error) error
+}
+
+// Recomputer recomputes derived data. service.RBACService implements it.
+type Recomputer interface {
+	RecomputeEffectivePermissions(ctx context.Context, userID domain.UserID) error
+	RecomputeRoleHolders(ctx context.Context, roleID domain.RoleID) error
+	RecomputePermissionHolders(ctx context.Context, permissionID domain.PermissionID) error
+	RecomputeGroupMembers(ctx context.Context, groupID domain.GroupID) error
+	RecountGroupMembers(ctx context.Context, groupID domain.GroupID) error
+}
+
+type Processor struct {
+	recomputer Recomputer
+	backoff    Backoff
+	deadLetter func(records []dynamodbrepo.StreamRecord, err error)
+}
+
+type Option func(*Processor)
+
+// WithBackoff sets how a failing batch is retried. DefaultBackoff is used
+// otherwise.
+func WithBackoff(backoff Backoff) Option {
+	return func(p *Processor) {
+		p.backoff = backoff
+	}
+}
+
+// WithDeadLetter sets what is done with a batch that still fails after the
+// last attempt. By default the keys of its records are logged.
+func WithDeadLetter(deadLetter func(records []dynamodbrepo.StreamRecord, err error)) Option {
+	return func(p *Processor) {
+		p.deadLetter = deadLetter
+	}
+}
+
+func New(recomputer Recomputer, options ...Option) *Processor {
+	p := &Processor{
+		recomputer: recomputer,
+		backoff:    DefaultBackoff,
+		deadLetter: logDeadLetter,
+	}
+	for _, option := range options {
+		option(p)
+	}
+	return p
+}
+
+// Run handles the records of source until it stops. A failing batch is
+// retried with backoff, and handed to the dead letter once the attempts run
+// out so the records behind it are not held up.
+func (p *Processor) Run(ctx context.Context, source Source) error {
+	return source.Run(ctx, p.handleWithRetry)
+}
+
+func (p *Processor) handleWithRetry(ctx context.Context, records []dynamodbrepo.StreamRecord) error {
+	err := p.backoff.Retry(ctx, func(ctx context.Context) error {
+		return p.Handle(ctx, records)
+	})
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+	p.deadLetter(records, err)
+	return nil
+}
+
+// logDeadLetter logs the keys of the dropped records. The derived data they
+// call for stays stale until the next change to the same items.
+func logDeadLetter(records []dynamodbrepo.StreamRecord, err error) {
+	log.Printf("Dropping %d records: %v", len(records), err)
+	for _, record := range records {
+		log.Printf("Dropped %s %s %s / %s", record.EventID, record.EventName, keyValue(record, "PK"), keyValue(record, "SK"))
+	}
+}
+
+// task is a single recomputation. A batch runs each task once, however many
+// of its records call for it.
+type task struct {
+	tenant domain.TenantID
+	kind   string
+	id     string
+}
+
+const (
+	taskUser             = "user"
+	taskRoleHolders      = "role"
+	taskPermHolders      = "permission"
+	taskGroupMembers     = "group"
+	taskGroupMemberCount = "group-count"
+)
+
+// Handle recomputes the derived data the records invalidate. It fails if any
+// recomputation failed, after attempting all of them.
+func (p *Processor) Handle(ctx context.Context, records []dynamodbrepo.StreamRecord) error {
+	var tasks []task
+	seen := make(map[task]bool)
+	add := func(t task) {
+		if t.id != "" && !seen[t] {
+			seen[t] = true
+			tasks = append(tasks, t)
+		}
+	}
+	for _, record := range records {
+		change, ok := dynamodbrepo.DecodeStreamRecord(record)
+		if !ok {
+			continue
+		}
+		add(task{change.Tenant, taskUser, string(change.UserID)})
+		add(task{change.Tenant, taskRoleHolders, string(change.RoleID)})
+		add(task{change.Tenant, taskPermHolders, string(change.PermissionID)})
+		add(task{change.Tenant, taskGroupMembers, string(change.GroupID)})
+		add(task{change.Tenant, taskGroupMemberCount, string(change.CountGroupID)})
+	}
+
+	var errs []error
+	for _, t := range tasks {
+		if err := p.run(repository.WithTenant(ctx, t.tenant), t); err != nil {
+			// The entity may have been deleted since; there is nothing to maintain then.
+			if errors.Is(err, repository.ErrNotFound) {
+				continue
+			}
+			errs = append(errs, fmt.Errorf("tenant %s: %s %s: %w", t.tenant, t.kind, t.id, err))
+		}
+	}
+	if len(tasks) > 0 {
+		log.Printf("Processed %d records, %d recomputations, %d failed", len(records), len(tasks), len(errs))
+	}
+	return errors.Join(errs...)
+}
+
+func (p *Processor) run(ctx context.Context, t task) error {
+	switch t.kind {
+	case taskUser:
+		return p.recomputer.RecomputeEffectivePermissions(ctx, domain.UserID(t.id))
+	case taskRoleHolders:
+		return p.recomputer.RecomputeRoleHolders(ctx, domain.RoleID(t.id))
+	case taskPermHolders:
+		return p.recomputer.RecomputePermissionHolders(ctx, domain.PermissionID(t.id))
+	case taskGroupMembers:
+		return p.recomputer.RecomputeGroupMembers(ctx, domain.GroupID(t.id))
+	case taskGroupMemberCount:
+		return p.recomputer.RecountGroupMembers(ctx, domain.GroupID(t.id))
+	}
+	return fmt.Errorf("unknown task %s", t.kind)
+}
+
+func keyValue(record dynamodbrepo.StreamRecord, name string) string {
+	if v, ok := record.Keys[name].(*types.AttributeValueMemberS); ok {
+		return v.Value
+	}
+	return ""
+}
@@ -0,0 +1,184 @@
+package processor
+
+import (
+	"aws-dynamodb-store/internal/domain"
+	"aws-dynamodb-store/internal/repository"
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	dynamodbrepo "aws-dynamodb-store/internal/repository/dynamodb"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// fakeRecomputer records the recomputations it is asked for and fails the
+// first fails calls.
+type fakeRecomputer struct {
+	mu    sync.Mutex
+	calls []string
+	fails int
+}
+
+func (f *fakeRecomputer) record(ctx context.Context, call string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fails > 0 {
+		f.fails--
+		return errors.New("unavailable")
+	}
+	tenant, _ := repository.TenantFromContext(ctx)
+	f.calls = append(f.calls, string(tenant)+"/"+call)
+	return nil
+}
+
+func (f *fakeRecomputer) RecomputeEffectivePermissions(ctx context.Context, userID domain.UserID) error {
+	return f.record(ctx, "user:"+string(userID))
+}
+
+func (f *fakeRecomputer) RecomputeRoleHolders(ctx context.Context, roleID domain.RoleID) error {
+	return f.record(ctx, "role:"+string(roleID))
+}
+
+func (f *fakeRecomputer) RecomputePermissionHolders(ctx context.Context, permissionID domain.PermissionID) error {
+	return f.record(ctx, "permission:"+string(permissionID))
+}
+
+func (f *fakeRecomputer) RecomputeGroupMembers(ctx context.Context, groupID domain.GroupID) error {
+	return f.record(ctx, "group:"+string(groupID))
+}
+
+func (f *fakeRecomputer) RecountGroupMembers(ctx context.Context, groupID domain.GroupID) error {
+	return f.record(ctx, "count:"+string(groupID))
+}
+
+func record(event, pk, sk string) dynamodbrepo.StreamRecord {
+	return dynamodbrepo.StreamRecord{
+		EventName: event,
+		Keys: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: pk},
+			"SK": &types.AttributeValueMemberS{Value: sk},
+		},
+	}
+}
+
+func TestProcessorRecomputesAffectedData(t *testing.T) {
+	recomputer := &fakeRecomputer{}
+	source := NewChannelSource(2)
+	source.Publish(
+		record(dynamodbrepo.EventInsert, "TENANT#t1#ROLE#r1", "TENANT#t1#PERMISSION#p1"),
+		record(dynamodbrepo.EventModify, "TENANT#t1#ROLE#r1", "TENANT#t1#PERMISSION#p2"),
+		record(dynamodbrepo.EventInsert, "TENANT#t1#USER#u1", "TENANT#t1#GROUP#g1"),
+		record(dynamodbrepo.EventModify, "TENANT#t1#USER#u1", "TENANT#t1#METADATA#u1"),
+	)
+	source.Publish(record(dynamodbrepo.EventRemove, "TENANT#t2#GROUP#g1", "TENANT#t2#ROLE#r1"))
+	source.Close()
+
+	if err := New(recomputer).Run(context.Background(), source); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"t1/role:r1", "t1/user:u1", "t1/count:g1", "t2/group:g1"}
+	if !reflect.DeepEqual(recomputer.calls, want) {
+		t.Errorf("unexpected recomputations %v; want %v", recomputer.calls, want)
+	}
+}
+
+var testBackoff = Backoff{Initial: time.Millisecond, Max: 4 * time.Millisecond, MaxAttempts: 3}
+
+func TestProcessorRetriesFailedBatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		fails       int
+		wantCalls   []string
+		wantDropped int
+	}{
+		{"recovers", 2, []string{"t1/user:u1", "t1/user:u2"}, 0},
+		{"gives up", 3, []string{"t1/user:u2"}, 1},
+	}
+	for _, tt := range tests {
+		recomputer := &fakeRecomputer{fails: tt.fails}
+		source := NewChannelSource(2)
+		source.Publish(record(dynamodbrepo.EventInsert, "TENANT#t1#USER#u1", "TENANT#t1#ROLE#r1"))
+		source.Publish(record(dynamodbrepo.EventInsert, "TENANT#t1#USER#u2", "TENANT#t1#ROLE#r1"))
+		source.Close()
+
+		var dropped [][]dynamodbrepo.StreamRecord
+		p := New(recomputer, WithBackoff(testBackoff), WithDeadLetter(func(records []dynamodbrepo.StreamRecord, err error) {
+			dropped = append(dropped, records)
+		}))
+		if err := p.Run(context.Background(), source); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		if !reflect.DeepEqual(recomputer.calls, tt.wantCalls) {
+			t.Errorf("%s: unexpected recomputations %v; want %v", tt.name, recomputer.calls, tt.wantCalls)
+		}
+		if len(dropped) != tt.wantDropped {
+			t.Errorf("%s: expected %d dropped batches; got %d", tt.name, tt.wantDropped, len(dropped))
+		}
+	}
+}
+
+func TestProcessorStopsRetryingWhenCanceled(t *testing.T) {
+	recomputer := &fakeRecomputer{fails: 1 << 30}
+	source := NewChannelSource(1)
+	source.Publish(record(dynamodbrepo.EventInsert, "TENANT#t1#USER#u1", "TENANT#t1#ROLE#r1"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	p := New(recomputer, WithBackoff(Backoff{Initial: time.Hour, Max: time.Hour, MaxAttempts: 10}))
+	if err := p.Run(ctx, source); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline to stop the retries; got %v", err)
+	}
+}
+
+func TestChannelSourceReturnsHandleErrors(t *testing.T) {
+	source := NewChannelSource(2)
+	source.Publish(record(dynamodbrepo.EventInsert, "TENANT#t1#USER#u1", "TENANT#t1#ROLE#r1"))
+	source.Publish(record(dynamodbrepo.EventInsert, "TENANT#t1#USER#u2", "TENANT#t1#ROLE#r1"))
+	source.Close()
+
+	failure := errors.New("unavailable")
+	calls := 0
+	err := source.Run(context.Background(), func(context.Context, []dynamodbrepo.StreamRecord) error {
+		calls++
+		return failure
+	})
+	if !errors.Is(err, failure) || calls != 1 {
+		t.Errorf("expected the first error without retrying; got %v after %d calls", err, calls)
+	}
+	if queued := len(source.batches); queued != 1 {
+		t.Errorf("expected the second batch to stay queued; got %d", queued)
+	}
+}
+
+func TestBackoffRetry(t *testing.T) {
+	attempts := 0
+	err := testBackoff.Retry(context.Background(), func(context.Context) error {
+		attempts++
+		return errors.New("unavailable")
+	})
+	if err == nil || attempts != testBackoff.MaxAttempts {
+		t.Errorf("expected to give up after %d attempts; got %d, %v", testBackoff.MaxAttempts, attempts, err)
+	}
+}
+
+func TestProcessorIgnoresDeletedEntities(t *testing.T) {
+	p := New(notFoundRecomputer{&fakeRecomputer{}})
+	err := p.Handle(context.Background(), []dynamodbrepo.StreamRecord{
+		record(dynamodbrepo.EventRemove, "TENANT#t1#ROLE#r1", "TENANT#t1#PARENT#r0"),
+	})
+	if err != nil {
+		t.Errorf("expected deleted entities to be skipped; got %v", err)
+	}
+}
+
+type notFoundRecomputer struct{ *fakeRecomputer }
+
+func (notFoundRecomputer) RecomputeRoleHolders(ctx context.Context, roleID domain.RoleID) error {
+	return repository.ErrNotFound
+}
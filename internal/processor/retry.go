@@ -0,0 +1,47 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Backoff spaces out the attempts at a failing batch. The delay starts at
+// Initial and doubles after every failed attempt, up to Max. After MaxAttempts
+// attempts the batch is given up on.
+type Backoff struct {
+	Initial     time.Duration
+	Max         time.Duration
+	MaxAttempts int
+}
+
+// DefaultBackoff retries a batch for about five minutes.
+var DefaultBackoff = Backoff{Initial: 100 * time.Millisecond, Max: 30 * time.Second, MaxAttempts: 16}
+
+// Retry calls fn until it succeeds, ctx is done or MaxAttempts attempts have
+// failed. It returns nil on success, ctx.Err() when ctx is done, and the last
+// error of fn otherwise.
+func (b Backoff) Retry(ctx context.Context, fn func(context.Context) error) error {
+	delay := b.Initial
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if attempt >= b.MaxAttempts {
+			return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		delay = min(2*delay, b.Max)
+	}
+}
@@ -0,0 +1,119 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	batchGetMaxKeys     = 100 // BatchGetItem limit per request
+	batchGetMaxAttempts = 8
+)
+
+// batchGetBaseBackoff is the wait before the first retry of UnprocessedKeys.
+// It doubles with every further attempt.
+var batchGetBaseBackoff = 50 * time.Millisecond
+
+type itemKey struct {
+	PK string
+	SK string
+}
+
+func (k itemKey) attributes() map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: k.PK},
+		"SK": &types.AttributeValueMemberS{Value: k.SK},
+	}
+}
+
+// hydrate loads the items behind keys and converts them, preserving the order
+// of keys. Keys whose item no longer exists or is soft-deleted are skipped.
+func hydrate[I any, T any](ctx context.Context, client DynamoDBAPI, tableName string, keys []itemKey, toEntity func(*I) T) ([]T, error) {
+	items, err := batchGetItems(ctx, client, tableName, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	entities := make([]T, 0, len(keys))
+	for _, key := range keys {
+		raw, ok := items[key]
+		if !ok {
+			log.Printf("Warning: item %s / %s not found", key.PK, key.SK)
+			continue
+		}
+		if isDeleted(raw) {
+			continue
+		}
+		var item I
+		if err := attributevalue.UnmarshalMap(raw, &item); err != nil {
+			log.Print(err.Error())
+			continue
+		}
+		entities = append(entities, toEntity(&item))
+	}
+	return entities, nil
+}
+
+// batchGetItems fetches keys with BatchGetItem in chunks of 100, retrying
+// UnprocessedKeys with exponential backoff.
+func batchGetItems(ctx context.Context, client DynamoDBAPI, tableName string, keys []itemKey) (map[itemKey]map[string]types.AttributeValue, error) {
+	items := make(map[itemKey]map[string]types.AttributeValue, len(keys))
+
+	// BatchGetItem rejects requests with duplicate keys.
+	seen := make(map[itemKey]bool, len(keys))
+	unique := make([]itemKey, 0, len(keys))
+	for _, key := range keys {
+		if !seen[key] {
+			seen[key] = true
+			unique = append(unique, key)
+		}
+	}
+
+	for start := 0; start < len(unique); start += batchGetMaxKeys {
+		end := min(start+batchGetMaxKeys, len(unique))
+
+		pending := make([]map[string]types.AttributeValue, 0, end-start)
+		for _, key := range unique[start:end] {
+			pending = append(pending, key.attributes())
+		}
+
+		for attempt := 0; len(pending) > 0; attempt++ {
+			if attempt > 0 {
+				if attempt >= batchGetMaxAttempts {
+					return nil, fmt.Errorf("failed to batch get items: %d keys still unprocessed after %d attempts", len(pending), attempt)
+				}
+				select {
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				case <-time.After(batchGetBaseBackoff << (attempt - 1)):
+				}
+			}
+
+			out, err := client.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{
+				RequestItems: map[string]types.KeysAndAttributes{
+					tableName: {Keys: pending},
+				},
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to batch get items: %w", err)
+			}
+
+			for _, item := range out.Responses[tableName] {
+				items[itemKey{PK: stringAttr(item, "PK"), SK: stringAttr(item, "SK")}] = item
+			}
+
+			pending = nil
+			if unprocessed, ok := out.UnprocessedKeys[tableName]; ok {
+				pending = unprocessed.Keys
+			}
+		}
+	}
+
+	return items, nil
+}
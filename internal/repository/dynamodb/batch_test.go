@@ -0,0 +1,125 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// newBatchTable stores n user metadata items and returns their keys.
+func newBatchTable(n int) (*fakeDynamoDB, []itemKey) {
+	client := newFakeDynamoDB()
+	keys := make([]itemKey, n)
+	for i := range keys {
+		keys[i] = tenantKeys("TENANT#t1#").metadata(UserPrefix, fmt.Sprintf("u%03d", i))
+		client.Put(keys[i].attributes())
+	}
+	return client, keys
+}
+
+func TestBatchGetItemsChunks(t *testing.T) {
+	tests := []struct {
+		name         string
+		items        int
+		duplicates   int // Keys requested twice
+		wantRequests []int
+	}{
+		{"single key", 1, 0, []int{1}},
+		{"full chunk", 100, 0, []int{100}},
+		{"one past a chunk", 101, 0, []int{100, 1}},
+		{"several chunks", 250, 0, []int{100, 100, 50}},
+		{"duplicates", 100, 20, []int{100}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, keys := newBatchTable(tt.items)
+			var requests []int
+			client.Intercept = func(operation string, input any) error {
+				if operation == "BatchGetItem" {
+					requests = append(requests, len(input.(*dynamodb.BatchGetItemInput).RequestItems["rbac"].Keys))
+				}
+				return nil
+			}
+
+			items, err := batchGetItems(context.Background(), client, "rbac", append(keys, keys[:tt.duplicates]...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(requests) != fmt.Sprint(tt.wantRequests) {
+				t.Errorf("expected requests of %v keys; got %v", tt.wantRequests, requests)
+			}
+			if len(items) != tt.items {
+				t.Errorf("expected %d items; got %d", tt.items, len(items))
+			}
+		})
+	}
+}
+
+func TestBatchGetItemsRetriesUnprocessedKeys(t *testing.T) {
+	backoff := batchGetBaseBackoff
+	batchGetBaseBackoff = time.Millisecond
+	t.Cleanup(func() { batchGetBaseBackoff = backoff })
+
+	tests := []struct {
+		name        string
+		unprocessed int // Calls that leave half of their keys unprocessed, -1 for every call leaving all of them
+		wantCalls   int
+		wantErr     bool
+	}{
+		{"none", 0, 1, false},
+		{"some", 3, 4, false},
+		{"never processed", -1, batchGetMaxAttempts, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, keys := newBatchTable(10)
+			calls := 0
+			client.Unprocessed = func(keys []map[string]types.AttributeValue) []map[string]types.AttributeValue {
+				calls++
+				switch {
+				case tt.unprocessed < 0:
+					return keys
+				case calls <= tt.unprocessed:
+					return keys[len(keys)/2:]
+				}
+				return nil
+			}
+
+			items, err := batchGetItems(context.Background(), client, "rbac", keys)
+			if calls != tt.wantCalls {
+				t.Errorf("expected %d calls; got %d", tt.wantCalls, calls)
+			}
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "unprocessed") {
+					t.Errorf("expected an error about the unprocessed keys; got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(items) != len(keys) {
+				t.Errorf("expected all %d items; got %d", len(keys), len(items))
+			}
+		})
+	}
+}
+
+func TestBatchGetItemsStopsRetryingWhenCanceled(t *testing.T) {
+	client, keys := newBatchTable(10)
+	ctx, cancel := context.WithCancel(context.Background())
+	client.Unprocessed = func(keys []map[string]types.AttributeValue) []map[string]types.AttributeValue {
+		cancel()
+		return keys
+	}
+
+	if _, err := batchGetItems(ctx, client, "rbac", keys); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled; got %v", err)
+	}
+}
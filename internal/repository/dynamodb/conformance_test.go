@@ -0,0 +1,93 @@
+package dynamodb
+
+import (
+	"aws-dynamodb-store/internal/config"
+	"aws-dynamodb-store/internal/repository"
+	"aws-dynamodb-store/internal/repository/repositorytest"
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// TestConformance runs the repository conformance suite against DynamoDB
+// Local at DYNAMODB_TEST_URL, e.g. http://localhost:8000 with the container
+// from docker-compose.yml. It creates a table of its own and drops it again.
+func TestConformance(t *testing.T) {
+	endpoint := os.Getenv("DYNAMODB_TEST_URL")
+	if endpoint == "" {
+		t.Skip("DYNAMODB_TEST_URL is not set")
+	}
+	// DynamoDB Local accepts any credentials, but requests must be signed.
+	if os.Getenv("AWS_ACCESS_KEY_ID") == "" {
+		t.Setenv("AWS_ACCESS_KEY_ID", "local")
+		t.Setenv("AWS_SECRET_ACCESS_KEY", "local")
+	}
+
+	cfg := config.DynamoDBConfig{
+		AWSRegion:               "us-west-2",
+		TableName:               fmt.Sprintf("conformance-%d", time.Now().UnixNano()),
+		EntityTypeIndex:         "EntityTypeIndex",
+		UseDynamoDBLocal:        true,
+		DynamoDBLocalURL:        endpoint,
+		SoftDeleteRetentionDays: 1,
+	}
+	client := NewDynamoDBClient(cfg)
+	createTestTable(t, client, cfg)
+
+	repo, err := NewDynamoDBRepository(context.Background(), client, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repositorytest.Run(t, func(t *testing.T) repository.Repository {
+		return repo
+	})
+}
+
+// TestConformanceFake runs the conformance suite against fakeDynamoDB, which
+// keeps the fake honest for the tests that depend on it.
+func TestConformanceFake(t *testing.T) {
+	cfg := config.DynamoDBConfig{TableName: "rbac", EntityTypeIndex: fakeEntityTypeIndex, SoftDeleteRetentionDays: 1}
+	repositorytest.Run(t, func(t *testing.T) repository.Repository {
+		client := newFakeDynamoDB()
+		return repository.Repository{
+			User:       NewDynamoDBUserRepository(client, cfg),
+			Role:       NewDynamoDBRoleRepository(client, cfg),
+			Permission: NewDynamoDBPermissionRepository(client, cfg),
+			Group:      NewDynamoDBGroupRepository(client, cfg),
+		}
+	})
+}
+
+// createTestTable creates the table with EnsureSchema and drops it once the
+// test has finished.
+func createTestTable(t *testing.T, client *dynamodb.Client, cfg config.DynamoDBConfig) {
+	t.Helper()
+	ctx := context.Background()
+
+	t.Cleanup(func() {
+		if _, err := client.DeleteTable(ctx, &dynamodb.DeleteTableInput{TableName: aws.String(cfg.TableName)}); err != nil {
+			t.Logf("failed to delete table %s: %v", cfg.TableName, err)
+		}
+	})
+	report, err := EnsureSchema(ctx, client, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.CreatedTable || !report.EnabledTTL {
+		t.Errorf("expected the table to be created with TTL; got %s", report)
+	}
+
+	// Running it again changes nothing.
+	report, err = EnsureSchema(ctx, client, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.CreatedTable || len(report.CreatedIndexes) > 0 || report.EnabledTTL {
+		t.Errorf("expected no changes; got %s", report)
+	}
+}
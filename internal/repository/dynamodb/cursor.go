@@ -0,0 +1,88 @@
+package dynamodb
+
+import (
+	"aws-dynamodb-store/internal/repository"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// encodeCursor turns a LastEvaluatedKey into an opaque token. All key
+// attributes in the table and its indexes are strings.
+func encodeCursor(key map[string]types.AttributeValue) (string, error) {
+	if len(key) == 0 {
+		return "", nil
+	}
+	plain := make(map[string]string, len(key))
+	for name, value := range key {
+		s, ok := value.(*types.AttributeValueMemberS)
+		if !ok {
+			return "", fmt.Errorf("unsupported key attribute %s of type %T", name, value)
+		}
+		plain[name] = s.Value
+	}
+	raw, err := json.Marshal(plain)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func decodeCursor(cursor string) (map[string]types.AttributeValue, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, repository.ErrInvalidCursor
+	}
+	var plain map[string]string
+	if err := json.Unmarshal(raw, &plain); err != nil || len(plain) == 0 {
+		return nil, repository.ErrInvalidCursor
+	}
+	key := make(map[string]types.AttributeValue, len(plain))
+	for name, value := range plain {
+		key[name] = &types.AttributeValueMemberS{Value: value}
+	}
+	return key, nil
+}
+
+// queryPage runs the Query for the requested page and returns the items
+// together with the cursor for the next one. DynamoDB applies Limit before
+// the FilterExpression, so a filtered query is repeated from where the last
+// one stopped until the page is full or the partition is exhausted.
+func queryPage(ctx context.Context, client DynamoDBAPI, input *dynamodb.QueryInput, page repository.PageRequest) ([]map[string]types.AttributeValue, string, error) {
+	startKey, err := decodeCursor(page.Cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	input.ExclusiveStartKey = startKey
+
+	var items []map[string]types.AttributeValue
+	for {
+		if page.Limit > 0 {
+			input.Limit = aws.Int32(int32(page.Limit - len(items)))
+		}
+		out, err := client.Query(ctx, input)
+		if err != nil {
+			return nil, "", err
+		}
+		items = append(items, out.Items...)
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+
+		if input.FilterExpression == nil || page.Limit <= 0 || len(items) >= page.Limit || len(out.LastEvaluatedKey) == 0 {
+			break
+		}
+	}
+
+	next, err := encodeCursor(input.ExclusiveStartKey)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+	return items, next, nil
+}
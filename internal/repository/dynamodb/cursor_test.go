@@ -0,0 +1,40 @@
+package dynamodb
+
+import (
+	"aws-dynamodb-store/internal/repository"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	key := map[string]types.AttributeValue{
+		"PK":         &types.AttributeValueMemberS{Value: "USER#u1"},
+		"SK":         &types.AttributeValueMemberS{Value: "METADATA#u1"},
+		"EntityType": &types.AttributeValueMemberS{Value: EntityTypeUser},
+	}
+
+	cursor, err := encodeCursor(key)
+	if err != nil {
+		t.Fatalf("encodeCursor: %v", err)
+	}
+	decoded, err := decodeCursor(cursor)
+	if err != nil {
+		t.Fatalf("decodeCursor: %v", err)
+	}
+	for name, value := range key {
+		got, ok := decoded[name].(*types.AttributeValueMemberS)
+		if !ok || got.Value != value.(*types.AttributeValueMemberS).Value {
+			t.Errorf("expected %s to round trip; got %v", name, decoded[name])
+		}
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	for _, cursor := range []string{"not base64!", "bm90LWpzb24"} {
+		if _, err := decodeCursor(cursor); !errors.Is(err, repository.ErrInvalidCursor) {
+			t.Errorf("expected ErrInvalidCursor for %q; got %v", cursor, err)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package dynamodb
+
+import (
+	"aws-dynamodb-store/internal/domain"
+	"aws-dynamodb-store/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	transactWriteMaxItems = 100 // TransactWriteItems limit per request
+	cascadeRounds         = 5   // Rounds of edge queries before deleteCascade gives up
+)
+
+// deleteEntity removes the metadata item of prefix+id in the tenant of k,
+// everything else stored in its partition and every edge that points at it.
+func deleteEntity(ctx context.Context, client DynamoDBAPI, tableName string, k tenantKeys, prefix string, id string) error {
+	queries, err := edgeQueries(ctx, client, tableName, k, prefix, id)
+	if err != nil {
+		return err
+	}
+	return deleteCascade(ctx, client, tableName, k.metadata(prefix, id), queries...)
+}
+
+// edgeQueries returns the queries that match everything stored in the
+// partition of prefix+id in the tenant of k and every edge that points at it.
+func edgeQueries(ctx context.Context, client DynamoDBAPI, tableName string, k tenantKeys, prefix string, id string) ([]*dynamodb.QueryInput, error) {
+	queries := []*dynamodb.QueryInput{
+		partitionQuery(tableName, k.key(prefix, id)),
+		referencingQuery(tableName, k.key(prefix, id)),
+	}
+	if prefix == RolePrefix {
+		// Child roles point at their parents with PARENT# edges.
+		queries = append(queries, referencingQuery(tableName, k.key(ParentPrefix, id)))
+	}
+	if prefix == UserPrefix {
+		// Resource-scoped assignments live in one partition per resource,
+		// listed by the RESOURCE# markers in the user's partition.
+		markers, err := queryKeys(ctx, client, prefixQuery(tableName, k.key(prefix, id), k.key(ResourcePrefix, "")))
+		if err != nil {
+			return nil, err
+		}
+		for _, marker := range markers {
+			resourceID := keyID(marker.SK, ResourcePrefix)
+			queries = append(queries, partitionQuery(tableName, bindingPartition(k, domain.UserID(id), resourceID)))
+		}
+	}
+	return queries, nil
+}
+
+// deleteCascade removes the metadata item and every item returned by the edge
+// queries. Edges are deleted in transactional chunks first and the metadata
+// item goes last, so an interrupted delete leaves the entity in place and
+// calling it again picks up the remaining edges.
+//
+// Edges found through GSI1 are eventually consistent, so the queries are run
+// again after each round of deletes until they find nothing new. An edge
+// whose index entry is still in flight after that is missed; after
+// cascadeRounds rounds that keep finding edges the delete gives up with
+// repository.ErrConflict and leaves the metadata item.
+func deleteCascade(ctx context.Context, client DynamoDBAPI, tableName string, metadata itemKey, edgeQueries ...*dynamodb.QueryInput) error {
+	_, err := getItemById(ctx, client, tableName, metadata.PK, metadata.SK)
+	metadataExists := err == nil
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+
+	seen := map[itemKey]bool{metadata: true}
+	for round := 0; ; round++ {
+		var keys []itemKey
+		for _, query := range edgeQueries {
+			edgeKeys, err := queryKeys(ctx, client, query)
+			if err != nil {
+				return err
+			}
+			for _, key := range edgeKeys {
+				if !seen[key] {
+					seen[key] = true
+					keys = append(keys, key)
+				}
+			}
+		}
+		if len(keys) == 0 {
+			break
+		}
+		if round == cascadeRounds {
+			return fmt.Errorf("failed to delete %s: edges are still being added: %w", metadata.PK, repository.ErrConflict)
+		}
+		if err := deleteItems(ctx, client, tableName, keys); err != nil {
+			return err
+		}
+	}
+
+	if !metadataExists {
+		if len(seen) == 1 {
+			return repository.ErrNotFound
+		}
+		return nil
+	}
+	return deleteItems(ctx, client, tableName, []itemKey{metadata})
+}
+
+// queryKeys returns the primary keys of every item matched by input.
+func queryKeys(ctx context.Context, client DynamoDBAPI, input *dynamodb.QueryInput) ([]itemKey, error) {
+	items, err := queryItems(ctx, client, input, "PK, SK")
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]itemKey, 0, len(items))
+	for _, item := range items {
+		keys = append(keys, itemKey{PK: stringAttr(item, "PK"), SK: stringAttr(item, "SK")})
+	}
+	return keys, nil
+}
+
+// queryItems returns the projected attributes of every item matched by input.
+func queryItems(ctx context.Context, client DynamoDBAPI, input *dynamodb.QueryInput, projection string) ([]map[string]types.AttributeValue, error) {
+	input.ProjectionExpression = aws.String(projection)
+
+	paginator := dynamodb.NewQueryPaginator(client, input)
+	var items []map[string]types.AttributeValue
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query edges: %w", err)
+		}
+		items = append(items, page.Items...)
+	}
+	return items, nil
+}
+
+// deleteItems deletes keys in order, in transactions of at most 100 items.
+func deleteItems(ctx context.Context, client DynamoDBAPI, tableName string, keys []itemKey) error {
+	for start := 0; start < len(keys); start += transactWriteMaxItems {
+		end := min(start+transactWriteMaxItems, len(keys))
+
+		transactItems := make([]types.TransactWriteItem, 0, end-start)
+		for _, key := range keys[start:end] {
+			transactItems = append(transactItems, types.TransactWriteItem{
+				Delete: &types.Delete{
+					TableName: aws.String(tableName),
+					Key:       key.attributes(),
+				},
+			})
+		}
+
+		_, err := client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems: transactItems,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete items %d-%d of %d: %w", start, end, len(keys), err)
+		}
+	}
+	return nil
+}
+
+// partitionQuery matches every item stored under pk, including its metadata.
+func partitionQuery(tableName string, pk string) *dynamodb.QueryInput {
+	return &dynamodb.QueryInput{
+		TableName:              aws.String(tableName),
+		KeyConditionExpression: aws.String("PK = :pkVal"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pkVal": &types.AttributeValueMemberS{Value: pk},
+		},
+	}
+}
+
+// prefixQuery matches the items stored under pk whose SK starts with skPrefix.
+func prefixQuery(tableName string, pk string, skPrefix string) *dynamodb.QueryInput {
+	return &dynamodb.QueryInput{
+		TableName:              aws.String(tableName),
+		KeyConditionExpression: aws.String("PK = :pkVal AND begins_with(SK, :skPrefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pkVal":    &types.AttributeValueMemberS{Value: pk},
+			":skPrefix": &types.AttributeValueMemberS{Value: skPrefix},
+		},
+	}
+}
+
+// referencingQuery matches every edge whose SK points at sk, using GSI1.
+func referencingQuery(tableName string, sk string) *dynamodb.QueryInput {
+	return &dynamodb.QueryInput{
+		TableName:              aws.String(tableName),
+		IndexName:              aws.String(GSI1Name), // GSI1PK = SK, GSI1SK = PK
+		KeyConditionExpression: aws.String("SK = :skVal"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":skVal": &types.AttributeValueMemberS{Value: sk},
+		},
+	}
+}
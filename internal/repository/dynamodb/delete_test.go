@@ -0,0 +1,246 @@
+package dynamodb
+
+import (
+	"aws-dynamodb-store/internal/config"
+	"aws-dynamodb-store/internal/domain"
+	"aws-dynamodb-store/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// newDeleteFixture stores a role with the given number of assigned users and
+// returns the client, the repositories and the tenant context.
+func newDeleteFixture(t *testing.T, users int) (*fakeDynamoDB, repository.Repository, context.Context) {
+	t.Helper()
+	client := newFakeDynamoDB()
+	cfg := config.DynamoDBConfig{TableName: "rbac", EntityTypeIndex: fakeEntityTypeIndex, SoftDeleteRetentionDays: 1}
+	repo := repository.Repository{
+		User: NewDynamoDBUserRepository(client, cfg),
+		Role: NewDynamoDBRoleRepository(client, cfg),
+	}
+	ctx := repository.WithTenant(context.Background(), "t1")
+
+	if err := repo.Role.CreateRole(ctx, &domain.Role{ID: "role-support", DisplayName: "Support"}); err != nil {
+		t.Fatal(err)
+	}
+	for i := range users {
+		assignment := &domain.RoleAssignment{UserID: domain.UserID(fmt.Sprintf("u%03d", i)), RoleID: "role-support"}
+		if err := repo.User.AssignRoleToUser(ctx, assignment); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return client, repo, ctx
+}
+
+// transactionKeys records the keys deleted by each TransactWriteItems call.
+func transactionKeys(input any) []itemKey {
+	var keys []itemKey
+	for _, item := range input.(*dynamodb.TransactWriteItemsInput).TransactItems {
+		if item.Delete != nil {
+			keys = append(keys, keyOf(item.Delete.Key))
+		}
+	}
+	return keys
+}
+
+func TestDeleteCascade(t *testing.T) {
+	metadata := itemKey{PK: "TENANT#t1#ROLE#role-support", SK: "TENANT#t1#METADATA#role-support"}
+
+	tests := []struct {
+		name  string
+		users int
+		// chunks is the expected number of keys per transaction, the
+		// metadata item being the last one.
+		chunks []int
+	}{
+		{name: "no edges", users: 0, chunks: []int{1}},
+		{name: "one chunk", users: 100, chunks: []int{100, 1}},
+		{name: "several chunks", users: 250, chunks: []int{100, 100, 50, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, repo, ctx := newDeleteFixture(t, tt.users)
+
+			var transactions [][]itemKey
+			client.Intercept = func(operation string, input any) error {
+				if operation == "TransactWriteItems" {
+					transactions = append(transactions, transactionKeys(input))
+				}
+				return nil
+			}
+			if err := repo.Role.DeleteRole(ctx, "role-support"); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(transactions) != len(tt.chunks) {
+				t.Fatalf("expected %d transactions; got %d", len(tt.chunks), len(transactions))
+			}
+			for i, keys := range transactions {
+				if len(keys) != tt.chunks[i] {
+					t.Errorf("expected transaction %d to delete %d items; got %d", i, tt.chunks[i], len(keys))
+				}
+				for j, key := range keys {
+					last := i == len(transactions)-1 && j == len(keys)-1
+					if (key == metadata) != last {
+						t.Errorf("expected the metadata item to be deleted last; found it at %d/%d", i, j)
+					}
+				}
+			}
+			if keys := client.Keys(); len(keys) != 0 {
+				t.Errorf("expected an empty table; got %v", keys)
+			}
+		})
+	}
+}
+
+func TestDeleteCascadeResumesAfterFailure(t *testing.T) {
+	client, repo, ctx := newDeleteFixture(t, 250)
+
+	transactions := 0
+	injected := errors.New("injected failure")
+	client.Intercept = func(operation string, input any) error {
+		if operation != "TransactWriteItems" {
+			return nil
+		}
+		transactions++
+		if transactions == 2 {
+			return injected
+		}
+		return nil
+	}
+	if err := repo.Role.DeleteRole(ctx, "role-support"); !errors.Is(err, injected) {
+		t.Fatalf("expected the injected failure; got %v", err)
+	}
+	// The first chunk is gone, the role and the rest of its edges remain.
+	if _, err := repo.Role.GetRoleByID(ctx, "role-support"); err != nil {
+		t.Fatalf("expected the role to survive the failed delete; got %v", err)
+	}
+	if got := len(client.Keys()); got != 151 {
+		t.Errorf("expected 150 edges and the metadata item to remain; got %d items", got)
+	}
+
+	client.Intercept = nil
+	if err := repo.Role.DeleteRole(ctx, "role-support"); err != nil {
+		t.Fatal(err)
+	}
+	if keys := client.Keys(); len(keys) != 0 {
+		t.Errorf("expected an empty table; got %v", keys)
+	}
+	if err := repo.Role.DeleteRole(ctx, "role-support"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("expected ErrNotFound once deleted; got %v", err)
+	}
+}
+
+func TestDeleteCascadeRequeriesEdges(t *testing.T) {
+	client, repo, ctx := newDeleteFixture(t, 3)
+
+	// Edges that show up in GSI1 while the delete runs are found by the
+	// next round of queries, before the metadata item goes.
+	added := 0
+	client.Intercept = func(operation string, input any) error {
+		if operation != "TransactWriteItems" || added == 2 {
+			return nil
+		}
+		added++
+		client.Put(attributeMap(t, assignmentToItem(tenantKeys("TENANT#t1#"), &domain.RoleAssignment{
+			UserID: domain.UserID(fmt.Sprintf("late%d", added)),
+			RoleID: "role-support",
+		})))
+		return nil
+	}
+	if err := repo.Role.DeleteRole(ctx, "role-support"); err != nil {
+		t.Fatal(err)
+	}
+	if keys := client.Keys(); len(keys) != 0 {
+		t.Errorf("expected an empty table; got %v", keys)
+	}
+
+	// Edges that keep coming make the delete give up and keep the role.
+	client, repo, ctx = newDeleteFixture(t, 1)
+	added = 0
+	client.Intercept = func(operation string, input any) error {
+		if operation == "TransactWriteItems" {
+			added++
+			client.Put(attributeMap(t, assignmentToItem(tenantKeys("TENANT#t1#"), &domain.RoleAssignment{
+				UserID: domain.UserID(fmt.Sprintf("late%d", added)),
+				RoleID: "role-support",
+			})))
+		}
+		return nil
+	}
+	if err := repo.Role.DeleteRole(ctx, "role-support"); !errors.Is(err, repository.ErrConflict) {
+		t.Fatalf("expected ErrConflict; got %v", err)
+	}
+	if _, err := repo.Role.GetRoleByID(ctx, "role-support"); err != nil {
+		t.Errorf("expected the role to remain; got %v", err)
+	}
+}
+
+func TestDeleteEntityRemovesEdges(t *testing.T) {
+	k := tenantKeys("TENANT#t1#")
+
+	tests := []struct {
+		name    string
+		delete  func(repo repository.Repository, ctx context.Context) error
+		deleted []string // Keys no remaining item may be stored under or point at
+	}{
+		{
+			name:    "user",
+			delete:  func(repo repository.Repository, ctx context.Context) error { return repo.User.DeleteUser(ctx, "u1") },
+			deleted: []string{k.key(UserPrefix, "u1"), bindingPartition(k, "u1", "org/acme")},
+		},
+		{
+			name: "role",
+			delete: func(repo repository.Repository, ctx context.Context) error {
+				return repo.Role.DeleteRole(ctx, "editor")
+			},
+			deleted: []string{k.key(RolePrefix, "editor"), k.key(ParentPrefix, "editor")},
+		},
+		{
+			name:    "parent role",
+			delete:  func(repo repository.Repository, ctx context.Context) error { return repo.Role.DeleteRole(ctx, "admin") },
+			deleted: []string{k.key(RolePrefix, "admin"), k.key(ParentPrefix, "admin")},
+		},
+		{
+			name: "permission",
+			delete: func(repo repository.Repository, ctx context.Context) error {
+				return repo.Permission.DeletePermission(ctx, "document:read")
+			},
+			deleted: []string{k.key(PermissionPrefix, "document:read")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newFakeDynamoDB()
+			repo := newFakeRepository(client)
+			ctx := repository.WithTenant(context.Background(), "t1")
+			must := func(err error) {
+				t.Helper()
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			must(repo.User.CreateUser(ctx, &domain.User{ID: "u1", Email: "u1@example.com"}))
+			must(repo.Role.CreateRole(ctx, &domain.Role{ID: "editor"}))
+			must(repo.Role.CreateRole(ctx, &domain.Role{ID: "admin"}))
+			must(repo.Permission.CreatePermission(ctx, &domain.Permission{ID: "document:read"}))
+			must(repo.Role.AssignPermissionToRole(ctx, &domain.PermissionGrant{RoleID: "editor", PermissionID: "document:read"}))
+			must(repo.Role.AddParentRole(ctx, "editor", "admin"))
+			must(repo.User.AssignRoleToUser(ctx, &domain.RoleAssignment{UserID: "u1", RoleID: "editor"}))
+			must(repo.User.AssignRoleToUser(ctx, &domain.RoleAssignment{UserID: "u1", RoleID: "editor", ResourceID: "org/acme"}))
+
+			must(tt.delete(repo, ctx))
+			for _, key := range client.Keys() {
+				for _, deleted := range tt.deleted {
+					if key.PK == deleted || key.SK == deleted {
+						t.Errorf("expected %s / %s to be deleted with %s", key.PK, key.SK, tt.name)
+					}
+				}
+			}
+		})
+	}
+}
@@ -1,4 +1,4 @@
-// internal/repository/dynamodb/dynamodb_rbac.go
+// internal/repository/dynamodb/dynamodb.go
 package dynamodb
 
 import (
@@ -8,6 +8,11 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"maps"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	awsConfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
@@ -21,22 +26,74 @@ const (
 	EntityTypeUser       = "USER"
 	EntityTypeRole       = "ROLE"
 	EntityTypePermission = "PERMISSION"
-	MetadataPrefix       = "METADATA#"
-	UserPrefix           = "USER#"
-	RolePrefix           = "ROLE#"
-	PermissionPrefix     = "PERMISSION#"
-	GSI1Name             = "GSI1" // Name of your GSI (SK-PK)
+	EntityTypeGroup      = "GROUP"
 
+	EntityTypeUserRoleAssignment       = "UserRoleAssignment"
+	EntityTypeRolePermissionAssignment = "RolePermissionAssignment"
+	EntityTypeRoleInheritance          = "RoleInheritance"
+	EntityTypeGroupMembership          = "GroupMembership"
+	EntityTypeGroupRoleAssignment      = "GroupRoleAssignment"
+	EntityTypeUserResource             = "UserResource" // USER#userID / RESOURCE#resourceID, lists the user's binding partitions
+
+	MetadataPrefix   = "METADATA#"
+	UserPrefix       = "USER#"
+	RolePrefix       = "ROLE#"
+	PermissionPrefix = "PERMISSION#"
+	ParentPrefix     = "PARENT#" // ROLE#child / PARENT#parent role inheritance edges
+	GroupPrefix      = "GROUP#"
+	ResourcePrefix   = "RESOURCE#"
+	TenantPrefix     = "TENANT#" // TENANT#tenantID# leads every key of a tenant
+	GSI1Name         = "GSI1"    // Name of your GSI (SK-PK)
+	TTLAttribute     = "TTL"     // Epoch seconds, the table's TimeToLive attribute
 )
 
+// DynamoDBAPI is the part of *dynamodb.Client the repositories use.
+type DynamoDBAPI interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	BatchGetItem(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error)
+	TransactWriteItems(ctx context.Context, params *dynamodb.TransactWriteItemsInput, optFns ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error)
+	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+}
+
 // Helper struct for DynamoDB items
 type baseItem struct {
-	PK         string `dynamodbav:"PK"`
-	SK         string `dynamodbav:"SK"`
-	EntityType string `dynamodbav:"EntityType,omitempty"` // For filtering and clarity
+	PK         string     `dynamodbav:"PK"`
+	SK         string     `dynamodbav:"SK"`
+	EntityType string     `dynamodbav:"EntityType,omitempty"` // For filtering and clarity
+	DeletedAt  *time.Time `dynamodbav:"DeletedAt,omitempty"`  // Set while soft-deleted
+	TTL        int64      `dynamodbav:"TTL,omitempty"`
+}
+
+// tableAPI is what NewDynamoDBRepository needs of its client: the item calls
+// of the repositories and the schema calls of VerifySchema.
+type tableAPI interface {
+	DynamoDBAPI
+	schemaAPI
+}
+
+// NewDynamoDBRepository builds the repositories on the table of cfg, sharing
+// client. It returns a *SchemaMismatchError when the table does not match
+// TableDefinition.
+func NewDynamoDBRepository(ctx context.Context, client tableAPI, cfg config.DynamoDBConfig) (repository.Repository, error) {
+	if err := VerifySchema(ctx, client, cfg); err != nil {
+		return repository.Repository{}, err
+	}
+
+	return repository.Repository{
+		User:       NewDynamoDBUserRepository(client, cfg),
+		Role:       NewDynamoDBRoleRepository(client, cfg),
+		Permission: NewDynamoDBPermissionRepository(client, cfg),
+		Group:      NewDynamoDBGroupRepository(client, cfg),
+	}, nil
 }
 
-func NewDynamoDBRepository(cfg config.DynamoDBConfig) repository.Repository {
+// NewDynamoDBClient builds a client for the configured region, pointing it at
+// DynamoDB Local when enabled.
+func NewDynamoDBClient(cfg config.DynamoDBConfig) *dynamodb.Client {
 
 	var client *dynamodb.Client
 
@@ -60,17 +117,13 @@ func NewDynamoDBRepository(cfg config.DynamoDBConfig) repository.Repository {
 		)
 	}
 
-	return repository.Repository{
-		User:       NewDynamoDBUserRepository(client, cfg),
-		Role:       NewDynamoDBRoleRepository(client, cfg),
-		Permission: NewDynamoDBPermissionRepository(client, cfg),
-	}
+	return client
 }
 
-func createItem(ctx context.Context, client *dynamodb.Client, tableName string, item interface{}) error {
+func createItem(ctx context.Context, client DynamoDBAPI, tableName string, item interface{}) error {
 	av, err := attributevalue.MarshalMap(item)
 	if err != nil {
-		return fmt.Errorf("failed to marshal user: %w", err)
+		return fmt.Errorf("failed to marshal item: %w", err)
 	}
 
 	_, err = client.PutItem(ctx, &dynamodb.PutItemInput{
@@ -88,7 +141,7 @@ func createItem(ctx context.Context, client *dynamodb.Client, tableName string,
 	return nil
 }
 
-func getItemById(ctx context.Context, client *dynamodb.Client, tableName string, pk string, sk string) (*dynamodb.GetItemOutput, error) {
+func getItemById(ctx context.Context, client DynamoDBAPI, tableName string, pk string, sk string) (*dynamodb.GetItemOutput, error) {
 	out, err := client.GetItem(ctx, &dynamodb.GetItemInput{
 		TableName: aws.String(tableName),
 		Key: map[string]types.AttributeValue{
@@ -106,12 +159,100 @@ func getItemById(ctx context.Context, client *dynamodb.Client, tableName string,
 	return out, nil
 }
 
-// Implement UpdateUser and DeleteUser similarly. DeleteUser will need to:
-// 1. Find all USER#id / ROLE#roleID items and delete them.
-// 2. Delete the USER#id / METADATA#id item.
-// This might be better done with BatchWriteItem or TransactWriteItems for atomicity.
+// updateItem writes the changed attributes of the item at key, provided its
+// stored Version still equals expectedVersion. Empty strings and maps remove
+// the attribute. UpdatedAt is refreshed, Version is incremented and the updated
+// item is unmarshalled into out.
+func updateItem(ctx context.Context, client DynamoDBAPI, tableName string, key itemKey, expectedVersion int64, changes map[string]interface{}, out interface{}) error {
+	names := map[string]string{
+		"#UpdatedAt": "UpdatedAt",
+		"#Version":   "Version",
+	}
+	values := map[string]types.AttributeValue{
+		":updatedAt":  &types.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339Nano)},
+		":newVersion": &types.AttributeValueMemberN{Value: strconv.FormatInt(expectedVersion+1, 10)},
+	}
+	sets := []string{"#UpdatedAt = :updatedAt", "#Version = :newVersion"}
+	var removes []string
+
+	// Sorted so the generated expression is stable.
+	fields := make([]string, 0, len(changes))
+	for field := range changes {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
 
-// For brevity, full implementation of Role and Permission repos are omitted but follow the same pattern.
-// Ensure proper marshalling/unmarshalling for roleItem and permissionItem.
-// For example, GetRolePermissions would query PK=ROLE#roleID and SK begins_with PERMISSION#,
-// then for each result, extract the permissionID from SK and call GetPermissionByID on PermissionRepository.
+	for i, field := range fields {
+		name := fmt.Sprintf("#f%d", i)
+		names[name] = field
+		if isEmptyChange(changes[field]) {
+			removes = append(removes, name)
+			continue
+		}
+		av, err := attributevalue.Marshal(changes[field])
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s: %w", field, err)
+		}
+		value := fmt.Sprintf(":f%d", i)
+		values[value] = av
+		sets = append(sets, name+" = "+value)
+	}
+
+	updateExpression := "SET " + strings.Join(sets, ", ")
+	if len(removes) > 0 {
+		updateExpression += " REMOVE " + strings.Join(removes, ", ")
+	}
+
+	// Items written before versioning was introduced have no Version attribute.
+	condition := "attribute_exists(PK) AND attribute_not_exists(DeletedAt) AND attribute_not_exists(#Version)"
+	if expectedVersion > 0 {
+		condition = "attribute_exists(PK) AND attribute_not_exists(DeletedAt) AND #Version = :expectedVersion"
+		values[":expectedVersion"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(expectedVersion, 10)}
+	}
+
+	result, err := client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(tableName),
+		Key:                       key.attributes(),
+		UpdateExpression:          aws.String(updateExpression),
+		ConditionExpression:       aws.String(condition),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+		ReturnValues:              types.ReturnValueAllNew,
+	})
+	if err != nil {
+		var condCheckFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &condCheckFailed) {
+			return repository.ErrConflict
+		}
+		return fmt.Errorf("failed to update item: %w", err)
+	}
+
+	if err := attributevalue.UnmarshalMap(result.Attributes, out); err != nil {
+		return fmt.Errorf("failed to unmarshal updated item: %w", err)
+	}
+	return nil
+}
+
+// setIfChanged records field in changes when the new value differs.
+func setIfChanged(changes map[string]interface{}, field string, old string, new string) {
+	if old != new {
+		changes[field] = new
+	}
+}
+
+// setMapIfChanged records field in changes when the new map differs.
+func setMapIfChanged(changes map[string]interface{}, field string, old map[string]string, new map[string]string) {
+	if !maps.Equal(old, new) {
+		changes[field] = new
+	}
+}
+
+func isEmptyChange(value interface{}) bool {
+	switch v := value.(type) {
+	case string:
+		return v == ""
+	case map[string]string:
+		return len(v) == 0
+	}
+	return value == nil
+}
@@ -0,0 +1,770 @@
+// Package dynamodbtest provides Fake, an in-memory stand-in for the parts of
+// the DynamoDB API the repositories use, so their request logic can be tested
+// without DynamoDB Local.
+package dynamodbtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// MaxTransactItems is the number of items DynamoDB accepts in a single
+// TransactWriteItems call.
+const MaxTransactItems = 100
+
+// Key is the primary key of an item.
+type Key struct {
+	PK string
+	SK string
+}
+
+// Index is a global secondary index that projects every attribute.
+type Index struct {
+	Name  string
+	Hash  string
+	Range string
+}
+
+// Fake is an in-memory DynamoDB table for tests of the request logic. It
+// evaluates the condition, filter, key and update expressions the
+// repositories use, honours Limit and ExclusiveStartKey like DynamoDB does
+// (Limit applies before the filter), rejects transactions of more than
+// MaxTransactItems items, and answers queries on its indexes from the table.
+//
+// Expressions support comparisons (=, <>, <, <=, >, >=) of names and
+// placeholders, attribute_exists, attribute_not_exists, begins_with and
+// contains, combined with AND, OR, NOT and parentheses. Updates support SET,
+// including if_not_exists and + and -, and REMOVE.
+//
+// It also records the table, indexes and TTL setting created through the
+// schema calls; see DescribeTable.
+type Fake struct {
+	mu      sync.Mutex
+	items   map[Key]map[string]types.AttributeValue
+	indexes map[string]Index
+	table   *types.TableDescription // Set by CreateTable
+	ttl     *types.TimeToLiveDescription
+
+	// Intercept, when set, runs before every call with its input and fails
+	// the call with the error it returns. It may modify the table.
+	Intercept func(operation string, input any) error
+	// Unprocessed, when set, picks the keys of each BatchGetItem call that
+	// are returned as UnprocessedKeys instead of being read, as DynamoDB does
+	// when it is throttled or the response grows too large.
+	Unprocessed func(keys []map[string]types.AttributeValue) []map[string]types.AttributeValue
+	// Transactions counts the TransactWriteItems calls that were applied.
+	Transactions int
+}
+
+// New returns an empty table with the given indexes.
+func New(indexes ...Index) *Fake {
+	f := &Fake{items: make(map[Key]map[string]types.AttributeValue), indexes: make(map[string]Index)}
+	for _, index := range indexes {
+		f.indexes[index.Name] = index
+	}
+	return f
+}
+
+func (f *Fake) before(operation string, input any) error {
+	if f.Intercept == nil {
+		return nil
+	}
+	return f.Intercept(operation, input)
+}
+
+// Put stores item as is, bypassing the API.
+func (f *Fake) Put(item map[string]types.AttributeValue) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.items[keyOf(item)] = cloneItem(item)
+}
+
+// Get returns the item at pk / sk, or nil.
+func (f *Fake) Get(pk string, sk string) map[string]types.AttributeValue {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return cloneItem(f.items[Key{PK: pk, SK: sk}])
+}
+
+// Delete removes the item at pk / sk, bypassing the API.
+func (f *Fake) Delete(pk string, sk string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.items, Key{PK: pk, SK: sk})
+}
+
+// Keys returns the keys of every stored item, sorted.
+func (f *Fake) Keys() []Key {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	keys := make([]Key, 0, len(f.items))
+	for key := range f.items {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].PK != keys[j].PK {
+			return keys[i].PK < keys[j].PK
+		}
+		return keys[i].SK < keys[j].SK
+	})
+	return keys
+}
+
+func (f *Fake) GetItem(ctx context.Context, in *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	if err := f.before("GetItem", in); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return &dynamodb.GetItemOutput{Item: cloneItem(f.items[keyOf(in.Key)])}, nil
+}
+
+func (f *Fake) PutItem(ctx context.Context, in *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	if err := f.before("PutItem", in); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := keyOf(in.Item)
+	ok, err := f.check(in.ConditionExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues, f.items[key])
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+	}
+	f.items[key] = cloneItem(in.Item)
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (f *Fake) UpdateItem(ctx context.Context, in *dynamodb.UpdateItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	if err := f.before("UpdateItem", in); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := keyOf(in.Key)
+	ok, err := f.check(in.ConditionExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues, f.items[key])
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+	}
+	item, err := applyUpdate(aws.ToString(in.UpdateExpression), in.ExpressionAttributeNames, in.ExpressionAttributeValues, f.items[key], in.Key)
+	if err != nil {
+		return nil, err
+	}
+	f.items[key] = item
+	out := &dynamodb.UpdateItemOutput{}
+	if in.ReturnValues == types.ReturnValueAllNew {
+		out.Attributes = cloneItem(item)
+	}
+	return out, nil
+}
+
+func (f *Fake) DeleteItem(ctx context.Context, in *dynamodb.DeleteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	if err := f.before("DeleteItem", in); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := keyOf(in.Key)
+	ok, err := f.check(in.ConditionExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues, f.items[key])
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+	}
+	delete(f.items, key)
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func (f *Fake) BatchGetItem(ctx context.Context, in *dynamodb.BatchGetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+	if err := f.before("BatchGetItem", in); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := &dynamodb.BatchGetItemOutput{Responses: make(map[string][]map[string]types.AttributeValue)}
+	for table, request := range in.RequestItems {
+		if len(request.Keys) > 100 {
+			return nil, fmt.Errorf("ValidationException: too many items requested for the BatchGetItem call")
+		}
+		keys := request.Keys
+		if f.Unprocessed != nil {
+			unprocessed := f.Unprocessed(keys)
+			if len(unprocessed) > 0 {
+				if out.UnprocessedKeys == nil {
+					out.UnprocessedKeys = make(map[string]types.KeysAndAttributes)
+				}
+				out.UnprocessedKeys[table] = types.KeysAndAttributes{Keys: unprocessed}
+			}
+			skip := make(map[Key]bool, len(unprocessed))
+			for _, key := range unprocessed {
+				skip[keyOf(key)] = true
+			}
+			keys = nil
+			for _, key := range request.Keys {
+				if !skip[keyOf(key)] {
+					keys = append(keys, key)
+				}
+			}
+		}
+		for _, key := range keys {
+			if item, ok := f.items[keyOf(key)]; ok {
+				out.Responses[table] = append(out.Responses[table], cloneItem(item))
+			}
+		}
+	}
+	return out, nil
+}
+
+func (f *Fake) TransactWriteItems(ctx context.Context, in *dynamodb.TransactWriteItemsInput, _ ...func(*dynamodb.Options)) (*dynamodb.TransactWriteItemsOutput, error) {
+	if err := f.before("TransactWriteItems", in); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(in.TransactItems) > MaxTransactItems {
+		return nil, fmt.Errorf("ValidationException: member must have length less than or equal to %d", MaxTransactItems)
+	}
+
+	touched := make(map[Key]bool)
+	reasons := make([]types.CancellationReason, len(in.TransactItems))
+	canceled := false
+	for i, item := range in.TransactItems {
+		var (
+			key       Key
+			condition *string
+			names     map[string]string
+			values    map[string]types.AttributeValue
+		)
+		switch {
+		case item.Put != nil:
+			key, condition, names, values = keyOf(item.Put.Item), item.Put.ConditionExpression, item.Put.ExpressionAttributeNames, item.Put.ExpressionAttributeValues
+		case item.Delete != nil:
+			key, condition, names, values = keyOf(item.Delete.Key), item.Delete.ConditionExpression, item.Delete.ExpressionAttributeNames, item.Delete.ExpressionAttributeValues
+		case item.Update != nil:
+			key, condition, names, values = keyOf(item.Update.Key), item.Update.ConditionExpression, item.Update.ExpressionAttributeNames, item.Update.ExpressionAttributeValues
+		case item.ConditionCheck != nil:
+			key, condition, names, values = keyOf(item.ConditionCheck.Key), item.ConditionCheck.ConditionExpression, item.ConditionCheck.ExpressionAttributeNames, item.ConditionCheck.ExpressionAttributeValues
+		}
+		if touched[key] {
+			return nil, fmt.Errorf("ValidationException: transaction request cannot include multiple operations on one item")
+		}
+		touched[key] = true
+
+		reasons[i].Code = aws.String("None")
+		ok, err := f.check(condition, names, values, f.items[key])
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			reasons[i].Code = aws.String("ConditionalCheckFailed")
+			canceled = true
+		}
+	}
+	if canceled {
+		return nil, &types.TransactionCanceledException{Message: aws.String("Transaction cancelled"), CancellationReasons: reasons}
+	}
+
+	for _, item := range in.TransactItems {
+		switch {
+		case item.Put != nil:
+			f.items[keyOf(item.Put.Item)] = cloneItem(item.Put.Item)
+		case item.Delete != nil:
+			delete(f.items, keyOf(item.Delete.Key))
+		case item.Update != nil:
+			key := keyOf(item.Update.Key)
+			updated, err := applyUpdate(aws.ToString(item.Update.UpdateExpression), item.Update.ExpressionAttributeNames, item.Update.ExpressionAttributeValues, f.items[key], item.Update.Key)
+			if err != nil {
+				return nil, err
+			}
+			f.items[key] = updated
+		}
+	}
+	f.Transactions++
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+func (f *Fake) Query(ctx context.Context, in *dynamodb.QueryInput, _ ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	if err := f.before("Query", in); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	hash, rangeKey := "PK", "SK"
+	if name := aws.ToString(in.IndexName); name != "" {
+		index, ok := f.indexes[name]
+		if !ok {
+			return nil, fmt.Errorf("ValidationException: the table does not have the specified index: %s", name)
+		}
+		hash, rangeKey = index.Hash, index.Range
+	}
+
+	var candidates []map[string]types.AttributeValue
+	for _, item := range f.items {
+		if _, ok := item[hash]; !ok {
+			continue
+		}
+		if _, ok := item[rangeKey]; !ok {
+			continue
+		}
+		ok, err := evaluate(aws.ToString(in.KeyConditionExpression), in.ExpressionAttributeNames, in.ExpressionAttributeValues, item)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			candidates = append(candidates, item)
+		}
+	}
+	sortItems(candidates, hash, rangeKey)
+
+	page, last, scanned, err := f.page(candidates, in.ExclusiveStartKey, in.Limit, in.FilterExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+	out := &dynamodb.QueryOutput{Count: int32(len(page)), ScannedCount: int32(scanned)}
+	if last != nil {
+		out.LastEvaluatedKey = map[string]types.AttributeValue{"PK": last["PK"], "SK": last["SK"]}
+		if hash != "PK" {
+			out.LastEvaluatedKey[hash] = last[hash]
+			out.LastEvaluatedKey[rangeKey] = last[rangeKey]
+		}
+	}
+	if in.Select != types.SelectCount {
+		out.Items = project(page, in.ProjectionExpression)
+	}
+	return out, nil
+}
+
+func (f *Fake) Scan(ctx context.Context, in *dynamodb.ScanInput, _ ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	if err := f.before("Scan", in); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	candidates := make([]map[string]types.AttributeValue, 0, len(f.items))
+	for _, item := range f.items {
+		candidates = append(candidates, item)
+	}
+	sortItems(candidates, "PK", "SK")
+
+	page, last, scanned, err := f.page(candidates, in.ExclusiveStartKey, in.Limit, in.FilterExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+	out := &dynamodb.ScanOutput{Items: project(page, in.ProjectionExpression), Count: int32(len(page)), ScannedCount: int32(scanned)}
+	if last != nil {
+		out.LastEvaluatedKey = map[string]types.AttributeValue{"PK": last["PK"], "SK": last["SK"]}
+	}
+	return out, nil
+}
+
+// page evaluates up to limit candidates after startKey, then filters them.
+func (f *Fake) page(candidates []map[string]types.AttributeValue, startKey map[string]types.AttributeValue, limit *int32, filter *string, names map[string]string, values map[string]types.AttributeValue) ([]map[string]types.AttributeValue, map[string]types.AttributeValue, int, error) {
+	start := 0
+	if startKey != nil {
+		from := keyOf(startKey)
+		for i, item := range candidates {
+			if keyOf(item) == from {
+				start = i + 1
+				break
+			}
+		}
+	}
+	end := len(candidates)
+	if limit != nil && start+int(*limit) < end {
+		end = start + int(*limit)
+	}
+
+	var page []map[string]types.AttributeValue
+	for _, item := range candidates[start:end] {
+		ok, err := f.check(filter, names, values, item)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		if ok {
+			page = append(page, cloneItem(item))
+		}
+	}
+	var last map[string]types.AttributeValue
+	if end < len(candidates) {
+		last = candidates[end-1]
+	}
+	return page, last, end - start, nil
+}
+
+func (f *Fake) check(condition *string, names map[string]string, values map[string]types.AttributeValue, item map[string]types.AttributeValue) (bool, error) {
+	if condition == nil || *condition == "" {
+		return true, nil
+	}
+	return evaluate(*condition, names, values, item)
+}
+
+func keyOf(item map[string]types.AttributeValue) Key {
+	return Key{PK: stringAttr(item, "PK"), SK: stringAttr(item, "SK")}
+}
+
+func stringAttr(item map[string]types.AttributeValue, name string) string {
+	if v, ok := item[name].(*types.AttributeValueMemberS); ok {
+		return v.Value
+	}
+	return ""
+}
+
+func cloneItem(item map[string]types.AttributeValue) map[string]types.AttributeValue {
+	if item == nil {
+		return nil
+	}
+	c := make(map[string]types.AttributeValue, len(item))
+	for name, value := range item {
+		c[name] = value
+	}
+	return c
+}
+
+func sortItems(items []map[string]types.AttributeValue, hash string, rangeKey string) {
+	sort.Slice(items, func(i, j int) bool {
+		for _, name := range []string{hash, rangeKey, "PK", "SK"} {
+			a, b := stringAttr(items[i], name), stringAttr(items[j], name)
+			if a != b {
+				return a < b
+			}
+		}
+		return false
+	})
+}
+
+func project(items []map[string]types.AttributeValue, projection *string) []map[string]types.AttributeValue {
+	if projection == nil {
+		return items
+	}
+	projected := make([]map[string]types.AttributeValue, 0, len(items))
+	for _, item := range items {
+		p := make(map[string]types.AttributeValue)
+		for _, name := range strings.Split(*projection, ",") {
+			if value, ok := item[strings.TrimSpace(name)]; ok {
+				p[strings.TrimSpace(name)] = value
+			}
+		}
+		projected = append(projected, p)
+	}
+	return projected
+}
+
+// --- Expressions ---
+
+// tokenize splits an expression into names, placeholders, operators,
+// parentheses and commas.
+func tokenize(expression string) []string {
+	var tokens []string
+	for i := 0; i < len(expression); {
+		c := rune(expression[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case strings.ContainsRune("(),+-", c):
+			tokens = append(tokens, string(c))
+			i++
+		case strings.ContainsRune("<>=", c):
+			j := i + 1
+			for j < len(expression) && strings.ContainsRune("<>=", rune(expression[j])) {
+				j++
+			}
+			tokens = append(tokens, expression[i:j])
+			i = j
+		default:
+			j := i
+			for j < len(expression) && !unicode.IsSpace(rune(expression[j])) && !strings.ContainsRune("(),+-<>=", rune(expression[j])) {
+				j++
+			}
+			tokens = append(tokens, expression[i:j])
+			i = j
+		}
+	}
+	return tokens
+}
+
+type expressionParser struct {
+	tokens []string
+	pos    int
+	names  map[string]string
+	values map[string]types.AttributeValue
+	item   map[string]types.AttributeValue
+}
+
+func (p *expressionParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *expressionParser) next() string {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+func (p *expressionParser) expect(token string) error {
+	if got := p.next(); got != token {
+		return fmt.Errorf("fake: expected %q, got %q in %v", token, got, p.tokens)
+	}
+	return nil
+}
+
+func (p *expressionParser) name(token string) string {
+	if strings.HasPrefix(token, "#") {
+		return p.names[token]
+	}
+	return token
+}
+
+// operand resolves a name or placeholder to its value, nil when missing.
+func (p *expressionParser) operand(token string) types.AttributeValue {
+	if strings.HasPrefix(token, ":") {
+		return p.values[token]
+	}
+	return p.item[p.name(token)]
+}
+
+func evaluate(expression string, names map[string]string, values map[string]types.AttributeValue, item map[string]types.AttributeValue) (bool, error) {
+	p := &expressionParser{tokens: tokenize(expression), names: names, values: values, item: item}
+	ok, err := p.or()
+	if err == nil && p.pos != len(p.tokens) {
+		err = fmt.Errorf("fake: unexpected %q in %q", p.peek(), expression)
+	}
+	return ok, err
+}
+
+func (p *expressionParser) or() (bool, error) {
+	result, err := p.and()
+	for err == nil && p.peek() == "OR" {
+		p.next()
+		var right bool
+		right, err = p.and()
+		result = result || right
+	}
+	return result, err
+}
+
+func (p *expressionParser) and() (bool, error) {
+	result, err := p.factor()
+	for err == nil && p.peek() == "AND" {
+		p.next()
+		var right bool
+		right, err = p.factor()
+		result = result && right
+	}
+	return result, err
+}
+
+func (p *expressionParser) factor() (bool, error) {
+	token := p.next()
+	switch token {
+	case "NOT":
+		ok, err := p.factor()
+		return !ok, err
+	case "(":
+		ok, err := p.or()
+		if err != nil {
+			return false, err
+		}
+		return ok, p.expect(")")
+	case "attribute_exists", "attribute_not_exists", "begins_with", "contains":
+		if err := p.expect("("); err != nil {
+			return false, err
+		}
+		value := p.operand(p.next())
+		var argument types.AttributeValue
+		if p.peek() == "," {
+			p.next()
+			argument = p.operand(p.next())
+		}
+		if err := p.expect(")"); err != nil {
+			return false, err
+		}
+		switch token {
+		case "attribute_exists":
+			return value != nil, nil
+		case "attribute_not_exists":
+			return value == nil, nil
+		case "begins_with":
+			s, ok := value.(*types.AttributeValueMemberS)
+			prefix, _ := argument.(*types.AttributeValueMemberS)
+			return ok && prefix != nil && strings.HasPrefix(s.Value, prefix.Value), nil
+		default:
+			switch v := value.(type) {
+			case *types.AttributeValueMemberS:
+				needle, _ := argument.(*types.AttributeValueMemberS)
+				return needle != nil && strings.Contains(v.Value, needle.Value), nil
+			case *types.AttributeValueMemberSS:
+				needle, _ := argument.(*types.AttributeValueMemberS)
+				return needle != nil && contains(v.Value, needle.Value), nil
+			}
+			return false, nil
+		}
+	}
+
+	left := p.operand(token)
+	operator := p.next()
+	right := p.operand(p.next())
+	if left == nil || right == nil {
+		return false, nil
+	}
+	c, comparable := compareValues(left, right)
+	switch operator {
+	case "=":
+		return reflect.DeepEqual(left, right), nil
+	case "<>":
+		return !reflect.DeepEqual(left, right), nil
+	case "<":
+		return comparable && c < 0, nil
+	case "<=":
+		return comparable && c <= 0, nil
+	case ">":
+		return comparable && c > 0, nil
+	case ">=":
+		return comparable && c >= 0, nil
+	}
+	return false, fmt.Errorf("fake: unknown operator %q", operator)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func compareValues(a types.AttributeValue, b types.AttributeValue) (int, bool) {
+	switch a := a.(type) {
+	case *types.AttributeValueMemberS:
+		if b, ok := b.(*types.AttributeValueMemberS); ok {
+			return strings.Compare(a.Value, b.Value), true
+		}
+	case *types.AttributeValueMemberN:
+		if b, ok := b.(*types.AttributeValueMemberN); ok {
+			x, _ := strconv.ParseFloat(a.Value, 64)
+			y, _ := strconv.ParseFloat(b.Value, 64)
+			switch {
+			case x < y:
+				return -1, true
+			case x > y:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	return 0, false
+}
+
+// applyUpdate applies the SET and REMOVE clauses of expression to a copy of
+// item, creating it from key when it does not exist.
+func applyUpdate(expression string, names map[string]string, values map[string]types.AttributeValue, item map[string]types.AttributeValue, key map[string]types.AttributeValue) (map[string]types.AttributeValue, error) {
+	updated := cloneItem(item)
+	if updated == nil {
+		updated = cloneItem(key)
+	}
+	p := &expressionParser{tokens: tokenize(expression), names: names, values: values, item: item}
+	clause := ""
+	for p.peek() != "" {
+		switch p.peek() {
+		case "SET", "REMOVE":
+			clause = p.next()
+			continue
+		case ",":
+			p.next()
+			continue
+		}
+		target := p.name(p.next())
+		switch clause {
+		case "REMOVE":
+			delete(updated, target)
+		case "SET":
+			if err := p.expect("="); err != nil {
+				return nil, err
+			}
+			value, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			updated[target] = value
+		default:
+			return nil, fmt.Errorf("fake: unsupported update expression %q", expression)
+		}
+	}
+	return updated, nil
+}
+
+// value parses operand [+|- operand], where an operand may be if_not_exists.
+func (p *expressionParser) value() (types.AttributeValue, error) {
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "+" || p.peek() == "-" {
+		operator := p.next()
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		x, errX := strconv.ParseInt(numberOf(left), 10, 64)
+		y, errY := strconv.ParseInt(numberOf(right), 10, 64)
+		if err := errors.Join(errX, errY); err != nil {
+			return nil, fmt.Errorf("fake: arithmetic on non-numbers: %w", err)
+		}
+		if operator == "-" {
+			y = -y
+		}
+		left = &types.AttributeValueMemberN{Value: strconv.FormatInt(x+y, 10)}
+	}
+	return left, nil
+}
+
+func (p *expressionParser) term() (types.AttributeValue, error) {
+	token := p.next()
+	if token != "if_not_exists" {
+		return p.operand(token), nil
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	value := p.operand(p.next())
+	if err := p.expect(","); err != nil {
+		return nil, err
+	}
+	fallback := p.operand(p.next())
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return fallback, nil
+	}
+	return value, nil
+}
+
+func numberOf(value types.AttributeValue) string {
+	if n, ok := value.(*types.AttributeValueMemberN); ok {
+		return n.Value
+	}
+	return ""
+}
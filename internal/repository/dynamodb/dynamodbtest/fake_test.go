@@ -0,0 +1,307 @@
+package dynamodbtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func s(value string) types.AttributeValue {
+	return &types.AttributeValueMemberS{Value: value}
+}
+
+func n(value string) types.AttributeValue {
+	return &types.AttributeValueMemberN{Value: value}
+}
+
+func TestEvaluate(t *testing.T) {
+	item := map[string]types.AttributeValue{
+		"PK":      s("USER#u1"),
+		"SK":      s("METADATA#u1"),
+		"Name":    s("Alice"),
+		"Version": n("3"),
+		"Tags":    &types.AttributeValueMemberSS{Value: []string{"a", "b"}},
+	}
+	names := map[string]string{"#v": "Version"}
+	values := map[string]types.AttributeValue{
+		":pk":     s("USER#u1"),
+		":prefix": s("USER#"),
+		":lic":    s("lic"),
+		":a":      s("a"),
+		":two":    n("2"),
+		":three":  n("3"),
+		":ten":    n("10"),
+	}
+
+	tests := []struct {
+		expression string
+		want       bool
+		wantErr    bool
+	}{
+		{"PK = :pk", true, false},
+		{"#v = :three", true, false},
+		{"#v <> :three", false, false},
+		// Numbers compare as numbers, not as strings.
+		{"#v < :ten", true, false},
+		{"#v >= :ten", false, false},
+		{"#v > :two", true, false},
+		{"#v <= :two", false, false},
+		// Strings and numbers do not compare.
+		{"Name < :three", false, false},
+		{"Missing = :pk", false, false},
+		{"attribute_exists(PK)", true, false},
+		{"attribute_exists(Missing)", false, false},
+		{"attribute_not_exists(Missing)", true, false},
+		{"begins_with(PK, :prefix)", true, false},
+		{"begins_with(Version, :prefix)", false, false},
+		{"contains(Name, :lic)", true, false},
+		{"contains(Tags, :a)", true, false},
+		{"contains(Tags, :lic)", false, false},
+		{"NOT attribute_exists(PK)", false, false},
+		{"attribute_exists(Missing) OR #v = :three", true, false},
+		{"attribute_exists(PK) AND (#v = :two OR Name = :pk)", false, false},
+		{"attribute_exists(PK) AND NOT (#v = :two OR Name = :pk)", true, false},
+		{"PK = :pk )", false, true},
+		{"PK ~ :pk", false, true},
+		{"(PK = :pk", false, true},
+	}
+	for _, tt := range tests {
+		got, err := evaluate(tt.expression, names, values, item)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v; got %v", tt.expression, tt.wantErr, err)
+			continue
+		}
+		if err == nil && got != tt.want {
+			t.Errorf("%s: expected %v; got %v", tt.expression, tt.want, got)
+		}
+	}
+}
+
+func TestApplyUpdate(t *testing.T) {
+	key := map[string]types.AttributeValue{"PK": s("USER#u1"), "SK": s("METADATA#u1")}
+	item := map[string]types.AttributeValue{"PK": s("USER#u1"), "SK": s("METADATA#u1"), "Name": s("Alice"), "Version": n("3")}
+	names := map[string]string{"#v": "Version"}
+	values := map[string]types.AttributeValue{":name": s("Bob"), ":one": n("1"), ":zero": n("0")}
+
+	tests := []struct {
+		name       string
+		expression string
+		item       map[string]types.AttributeValue
+		want       map[string]types.AttributeValue
+		wantErr    bool
+	}{
+		{"set", "SET Name = :name", item, map[string]types.AttributeValue{"PK": s("USER#u1"), "SK": s("METADATA#u1"), "Name": s("Bob"), "Version": n("3")}, false},
+		{"remove", "REMOVE Name", item, map[string]types.AttributeValue{"PK": s("USER#u1"), "SK": s("METADATA#u1"), "Version": n("3")}, false},
+		{"add", "SET #v = #v + :one", item, map[string]types.AttributeValue{"PK": s("USER#u1"), "SK": s("METADATA#u1"), "Name": s("Alice"), "Version": n("4")}, false},
+		{"several clauses", "SET Name = :name, #v = #v - :one REMOVE Tags", item, map[string]types.AttributeValue{"PK": s("USER#u1"), "SK": s("METADATA#u1"), "Name": s("Bob"), "Version": n("2")}, false},
+		{"create from key", "SET #v = if_not_exists(#v, :zero) + :one", nil, map[string]types.AttributeValue{"PK": s("USER#u1"), "SK": s("METADATA#u1"), "Version": n("1")}, false},
+		{"if_not_exists keeps the value", "SET #v = if_not_exists(#v, :zero)", item, item, false},
+		{"arithmetic on a string", "SET Name = Name + :one", item, nil, true},
+		{"unsupported clause", "ADD #v :one", item, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyUpdate(tt.expression, names, values, tt.item, key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v; got %v", tt.wantErr, err)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v; got %v", tt.want, got)
+			}
+		})
+	}
+	if _, ok := item["Tags"]; ok || len(item) != 4 {
+		t.Errorf("expected the item to be left alone; got %v", item)
+	}
+}
+
+// newPartition stores USER#u1 / ROLE#r1 to ROLE#r5, with r2 and r4 deleted.
+func newPartition() *Fake {
+	f := New(Index{Name: "GSI1", Hash: "SK", Range: "PK"})
+	for i := 1; i <= 5; i++ {
+		item := map[string]types.AttributeValue{"PK": s("USER#u1"), "SK": s(fmt.Sprintf("ROLE#r%d", i))}
+		if i%2 == 0 {
+			item["Deleted"] = n("1")
+		}
+		f.Put(item)
+	}
+	return f
+}
+
+func TestQueryAppliesLimitBeforeFilter(t *testing.T) {
+	f := newPartition()
+	input := &dynamodb.QueryInput{
+		KeyConditionExpression:    aws.String("PK = :pk AND begins_with(SK, :prefix)"),
+		FilterExpression:          aws.String("attribute_not_exists(Deleted)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{":pk": s("USER#u1"), ":prefix": s("ROLE#")},
+		Limit:                     aws.Int32(2),
+	}
+
+	pages := []struct {
+		sks          []string
+		scannedCount int32
+		more         bool
+	}{
+		{[]string{"ROLE#r1"}, 2, true},
+		{[]string{"ROLE#r3"}, 2, true},
+		{[]string{"ROLE#r5"}, 1, false},
+	}
+	for i, page := range pages {
+		out, err := f.Query(context.Background(), input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var sks []string
+		for _, item := range out.Items {
+			sks = append(sks, stringAttr(item, "SK"))
+		}
+		if !reflect.DeepEqual(sks, page.sks) || out.ScannedCount != page.scannedCount || (out.LastEvaluatedKey != nil) != page.more {
+			t.Errorf("page %d: expected %v, %d scanned, more %v; got %v, %d scanned, LastEvaluatedKey %v", i, page.sks, page.scannedCount, page.more, sks, out.ScannedCount, out.LastEvaluatedKey)
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+func TestQueryIndexes(t *testing.T) {
+	f := newPartition()
+	tests := []struct {
+		name      string
+		index     string
+		condition string
+		want      int
+		wantErr   bool
+	}{
+		{"table", "", "PK = :key", 5, false},
+		{"index", "GSI1", "SK = :key", 1, false},
+		{"unknown index", "EntityTypeIndex", "EntityType = :key", 0, true},
+	}
+	keys := map[string]string{"": "USER#u1", "GSI1": "ROLE#r3", "EntityTypeIndex": "USER"}
+	for _, tt := range tests {
+		out, err := f.Query(context.Background(), &dynamodb.QueryInput{
+			IndexName:                 aws.String(tt.index),
+			KeyConditionExpression:    aws.String(tt.condition),
+			ExpressionAttributeValues: map[string]types.AttributeValue{":key": s(keys[tt.index])},
+		})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v; got %v", tt.name, tt.wantErr, err)
+			continue
+		}
+		if err == nil && len(out.Items) != tt.want {
+			t.Errorf("%s: expected %d items; got %d", tt.name, tt.want, len(out.Items))
+		}
+	}
+}
+
+func TestConditionalWrites(t *testing.T) {
+	f := New()
+	item := map[string]types.AttributeValue{"PK": s("USER#u1"), "SK": s("METADATA#u1")}
+	key := map[string]types.AttributeValue{"PK": s("USER#u1"), "SK": s("METADATA#u1")}
+	absent := aws.String("attribute_not_exists(PK)")
+
+	steps := []struct {
+		name string
+		do   func() error
+		want bool // Whether the condition holds
+	}{
+		{"create", func() error {
+			_, err := f.PutItem(context.Background(), &dynamodb.PutItemInput{Item: item, ConditionExpression: absent})
+			return err
+		}, true},
+		{"create again", func() error {
+			_, err := f.PutItem(context.Background(), &dynamodb.PutItemInput{Item: item, ConditionExpression: absent})
+			return err
+		}, false},
+		{"update a missing item", func() error {
+			_, err := f.UpdateItem(context.Background(), &dynamodb.UpdateItemInput{
+				Key:                 map[string]types.AttributeValue{"PK": s("USER#u2"), "SK": s("METADATA#u2")},
+				UpdateExpression:    aws.String("REMOVE Name"),
+				ConditionExpression: aws.String("attribute_exists(PK)"),
+			})
+			return err
+		}, false},
+		{"delete", func() error {
+			_, err := f.DeleteItem(context.Background(), &dynamodb.DeleteItemInput{Key: key, ConditionExpression: aws.String("attribute_exists(PK)")})
+			return err
+		}, true},
+		{"delete again", func() error {
+			_, err := f.DeleteItem(context.Background(), &dynamodb.DeleteItemInput{Key: key, ConditionExpression: aws.String("attribute_exists(PK)")})
+			return err
+		}, false},
+	}
+	for _, step := range steps {
+		err := step.do()
+		var failed *types.ConditionalCheckFailedException
+		if step.want && err != nil || !step.want && !errors.As(err, &failed) {
+			t.Errorf("%s: expected the condition to hold: %v; got %v", step.name, step.want, err)
+		}
+	}
+}
+
+func TestTransactWriteItems(t *testing.T) {
+	put := func(pk string, condition string) types.TransactWriteItem {
+		return types.TransactWriteItem{Put: &types.Put{
+			Item:                map[string]types.AttributeValue{"PK": s(pk), "SK": s("METADATA")},
+			ConditionExpression: aws.String(condition),
+		}}
+	}
+	tooMany := make([]types.TransactWriteItem, MaxTransactItems+1)
+	for i := range tooMany {
+		tooMany[i] = put(fmt.Sprintf("USER#u%d", i), "attribute_not_exists(PK)")
+	}
+
+	tests := []struct {
+		name        string
+		items       []types.TransactWriteItem
+		wantCodes   []string // Cancellation reasons, nil when applied
+		wantInvalid bool
+		wantKeys    int
+	}{
+		{"applied", []types.TransactWriteItem{put("USER#u1", "attribute_not_exists(PK)"), put("USER#u2", "attribute_not_exists(PK)")}, nil, false, 2},
+		{"canceled", []types.TransactWriteItem{put("USER#u2", "attribute_not_exists(PK)"), put("USER#u3", "attribute_exists(PK)")}, []string{"None", "ConditionalCheckFailed"}, false, 0},
+		{"same item twice", []types.TransactWriteItem{put("USER#u2", "attribute_not_exists(PK)"), put("USER#u2", "attribute_not_exists(PK)")}, nil, true, 0},
+		{"too many items", tooMany, nil, true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := New()
+			if tt.name != "applied" {
+				f.Put(map[string]types.AttributeValue{"PK": s("USER#u1"), "SK": s("METADATA")})
+			}
+			before := len(f.Keys())
+			_, err := f.TransactWriteItems(context.Background(), &dynamodb.TransactWriteItemsInput{TransactItems: tt.items})
+
+			var canceled *types.TransactionCanceledException
+			switch {
+			case tt.wantInvalid:
+				if err == nil || errors.As(err, &canceled) {
+					t.Fatalf("expected a validation error; got %v", err)
+				}
+			case tt.wantCodes != nil:
+				if !errors.As(err, &canceled) {
+					t.Fatalf("expected TransactionCanceledException; got %v", err)
+				}
+				var codes []string
+				for _, reason := range canceled.CancellationReasons {
+					codes = append(codes, aws.ToString(reason.Code))
+				}
+				if !reflect.DeepEqual(codes, tt.wantCodes) {
+					t.Errorf("expected reasons %v; got %v", tt.wantCodes, codes)
+				}
+			case err != nil:
+				t.Fatal(err)
+			}
+			if got := len(f.Keys()) - before; got != tt.wantKeys {
+				t.Errorf("expected %d new items; got %d", tt.wantKeys, got)
+			}
+			if want := min(tt.wantKeys, 1); f.Transactions != want {
+				t.Errorf("expected %d applied transactions; got %d", want, f.Transactions)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package dynamodbtest
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// The table description is kept apart from the items: creating a table or
+// index with a definition that differs from the indexes given to New changes
+// what DescribeTable reports, not how queries are answered.
+
+// DescribeTable describes the table created with CreateTable, or fails with a
+// ResourceNotFoundException until there is one. Tables and indexes are active
+// as soon as they are created.
+func (f *Fake) DescribeTable(ctx context.Context, in *dynamodb.DescribeTableInput, _ ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	if err := f.before("DescribeTable", in); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.table == nil || aws.ToString(f.table.TableName) != aws.ToString(in.TableName) {
+		return nil, &types.ResourceNotFoundException{Message: aws.String(fmt.Sprintf("Requested resource not found: Table: %s not found", aws.ToString(in.TableName)))}
+	}
+	table := *f.table
+	table.GlobalSecondaryIndexes = append([]types.GlobalSecondaryIndexDescription(nil), f.table.GlobalSecondaryIndexes...)
+	return &dynamodb.DescribeTableOutput{Table: &table}, nil
+}
+
+func (f *Fake) CreateTable(ctx context.Context, in *dynamodb.CreateTableInput, _ ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error) {
+	if err := f.before("CreateTable", in); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.table != nil {
+		return nil, &types.ResourceInUseException{Message: aws.String("Table already exists: " + aws.ToString(in.TableName))}
+	}
+	f.table = &types.TableDescription{
+		TableName:            in.TableName,
+		TableStatus:          types.TableStatusActive,
+		KeySchema:            in.KeySchema,
+		AttributeDefinitions: in.AttributeDefinitions,
+		BillingModeSummary:   &types.BillingModeSummary{BillingMode: in.BillingMode},
+	}
+	for _, index := range in.GlobalSecondaryIndexes {
+		f.table.GlobalSecondaryIndexes = append(f.table.GlobalSecondaryIndexes, types.GlobalSecondaryIndexDescription{
+			IndexName:   index.IndexName,
+			IndexStatus: types.IndexStatusActive,
+			KeySchema:   index.KeySchema,
+			Projection:  index.Projection,
+		})
+	}
+	return &dynamodb.CreateTableOutput{TableDescription: f.table}, nil
+}
+
+// UpdateTable creates the indexes of in. Like DynamoDB, it accepts a single
+// index creation per call.
+func (f *Fake) UpdateTable(ctx context.Context, in *dynamodb.UpdateTableInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateTableOutput, error) {
+	if err := f.before("UpdateTable", in); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.table == nil {
+		return nil, &types.ResourceNotFoundException{Message: aws.String("Requested resource not found")}
+	}
+	if len(in.GlobalSecondaryIndexUpdates) != 1 || in.GlobalSecondaryIndexUpdates[0].Create == nil {
+		return nil, fmt.Errorf("fake: UpdateTable only supports creating one index per call")
+	}
+	create := in.GlobalSecondaryIndexUpdates[0].Create
+	for _, index := range f.table.GlobalSecondaryIndexes {
+		if aws.ToString(index.IndexName) == aws.ToString(create.IndexName) {
+			return nil, &types.ResourceInUseException{Message: aws.String("Index already exists: " + aws.ToString(create.IndexName))}
+		}
+	}
+	f.table.AttributeDefinitions = in.AttributeDefinitions
+	f.table.GlobalSecondaryIndexes = append(f.table.GlobalSecondaryIndexes, types.GlobalSecondaryIndexDescription{
+		IndexName:   create.IndexName,
+		IndexStatus: types.IndexStatusActive,
+		KeySchema:   create.KeySchema,
+		Projection:  create.Projection,
+	})
+	return &dynamodb.UpdateTableOutput{TableDescription: f.table}, nil
+}
+
+func (f *Fake) DescribeTimeToLive(ctx context.Context, in *dynamodb.DescribeTimeToLiveInput, _ ...func(*dynamodb.Options)) (*dynamodb.DescribeTimeToLiveOutput, error) {
+	if err := f.before("DescribeTimeToLive", in); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.table == nil {
+		return nil, &types.ResourceNotFoundException{Message: aws.String("Requested resource not found")}
+	}
+	ttl := types.TimeToLiveDescription{TimeToLiveStatus: types.TimeToLiveStatusDisabled}
+	if f.ttl != nil {
+		ttl = *f.ttl
+	}
+	return &dynamodb.DescribeTimeToLiveOutput{TimeToLiveDescription: &ttl}, nil
+}
+
+func (f *Fake) UpdateTimeToLive(ctx context.Context, in *dynamodb.UpdateTimeToLiveInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateTimeToLiveOutput, error) {
+	if err := f.before("UpdateTimeToLive", in); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.table == nil {
+		return nil, &types.ResourceNotFoundException{Message: aws.String("Requested resource not found")}
+	}
+	spec := in.TimeToLiveSpecification
+	status := types.TimeToLiveStatusDisabled
+	if aws.ToBool(spec.Enabled) {
+		status = types.TimeToLiveStatusEnabled
+	}
+	f.ttl = &types.TimeToLiveDescription{AttributeName: spec.AttributeName, TimeToLiveStatus: status}
+	return &dynamodb.UpdateTimeToLiveOutput{TimeToLiveSpecification: spec}, nil
+}
@@ -0,0 +1,100 @@
+package dynamodbtest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestSchema(t *testing.T) {
+	ctx := context.Background()
+	f := New()
+	table := aws.String("rbac")
+	key := []types.KeySchemaElement{{AttributeName: aws.String("PK"), KeyType: types.KeyTypeHash}}
+
+	var notFound *types.ResourceNotFoundException
+	if _, err := f.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: table}); !errors.As(err, &notFound) {
+		t.Fatalf("expected ResourceNotFoundException before the table is created; got %v", err)
+	}
+
+	_, err := f.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName: table,
+		KeySchema: key,
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{
+			{IndexName: aws.String("A"), KeySchema: key},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var inUse *types.ResourceInUseException
+	if _, err := f.CreateTable(ctx, &dynamodb.CreateTableInput{TableName: table}); !errors.As(err, &inUse) {
+		t.Errorf("expected ResourceInUseException for a second table; got %v", err)
+	}
+
+	create := func(name string) error {
+		_, err := f.UpdateTable(ctx, &dynamodb.UpdateTableInput{
+			TableName:                   table,
+			GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{{Create: &types.CreateGlobalSecondaryIndexAction{IndexName: aws.String(name), KeySchema: key}}},
+		})
+		return err
+	}
+	if err := create("B"); err != nil {
+		t.Fatal(err)
+	}
+	if err := create("A"); !errors.As(err, &inUse) {
+		t.Errorf("expected ResourceInUseException for an existing index; got %v", err)
+	}
+
+	out, err := f.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: table})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Table.TableStatus != types.TableStatusActive || len(out.Table.GlobalSecondaryIndexes) != 2 {
+		t.Fatalf("expected an active table with both indexes; got %+v", out.Table)
+	}
+	for _, index := range out.Table.GlobalSecondaryIndexes {
+		if index.IndexStatus != types.IndexStatusActive {
+			t.Errorf("expected index %s to be active; got %s", aws.ToString(index.IndexName), index.IndexStatus)
+		}
+	}
+	if _, err := f.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String("other")}); !errors.As(err, &notFound) {
+		t.Errorf("expected ResourceNotFoundException for another table; got %v", err)
+	}
+}
+
+func TestTimeToLive(t *testing.T) {
+	ctx := context.Background()
+	f := New()
+	table := aws.String("rbac")
+	if _, err := f.CreateTable(ctx, &dynamodb.CreateTableInput{TableName: table}); err != nil {
+		t.Fatal(err)
+	}
+
+	describe := func() *types.TimeToLiveDescription {
+		t.Helper()
+		out, err := f.DescribeTimeToLive(ctx, &dynamodb.DescribeTimeToLiveInput{TableName: table})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out.TimeToLiveDescription
+	}
+	if ttl := describe(); ttl.TimeToLiveStatus != types.TimeToLiveStatusDisabled {
+		t.Errorf("expected TTL to start disabled; got %s", ttl.TimeToLiveStatus)
+	}
+
+	_, err := f.UpdateTimeToLive(ctx, &dynamodb.UpdateTimeToLiveInput{
+		TableName:               table,
+		TimeToLiveSpecification: &types.TimeToLiveSpecification{AttributeName: aws.String("TTL"), Enabled: aws.Bool(true)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ttl := describe(); ttl.TimeToLiveStatus != types.TimeToLiveStatusEnabled || aws.ToString(ttl.AttributeName) != "TTL" {
+		t.Errorf("expected TTL enabled on TTL; got %s on %s", ttl.TimeToLiveStatus, aws.ToString(ttl.AttributeName))
+	}
+}
@@ -0,0 +1,120 @@
+package dynamodb
+
+import (
+	"aws-dynamodb-store/internal/domain"
+	"aws-dynamodb-store/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// SetEffectivePermissions stores permissions on the user's metadata item as
+// string sets, without touching its Version. A nil permissions removes them,
+// so checks evaluate the user's grants in full. It fails with
+// repository.ErrConflict if the user does not exist or permissions computed
+// later are already stored.
+func (r *DynamoDBUserRepository) SetEffectivePermissions(ctx context.Context, id domain.UserID, permissions *domain.EffectivePermissions) error {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return err
+	}
+
+	names := map[string]string{
+		"#allowed":     "EffectivePermissions",
+		"#denied":      "DeniedPermissions",
+		"#conditional": "ConditionalPermissions",
+		"#computedAt":  "PermissionsComputedAt",
+		"#validUntil":  "PermissionsValidUntil",
+	}
+	values := map[string]types.AttributeValue{}
+	condition := "attribute_exists(PK)"
+	var sets, removes []string
+
+	if permissions == nil {
+		removes = []string{"#allowed", "#denied", "#conditional", "#computedAt", "#validUntil"}
+	} else {
+		// Computations started earlier must not overwrite later ones.
+		condition += " AND (attribute_not_exists(#computedAt) OR #computedAt <= :computedAt)"
+		values[":computedAt"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(permissions.ComputedAt.UnixNano(), 10)}
+		sets = append(sets, "#computedAt = :computedAt")
+
+		for name, ids := range map[string][]domain.PermissionID{
+			"#allowed":     permissions.Allowed,
+			"#denied":      permissions.Denied,
+			"#conditional": permissions.Conditional,
+		} {
+			// DynamoDB sets cannot be empty.
+			if len(ids) == 0 {
+				removes = append(removes, name)
+				continue
+			}
+			value := ":" + name[1:]
+			values[value] = &types.AttributeValueMemberSS{Value: permissionStrings(ids)}
+			sets = append(sets, name+" = "+value)
+		}
+
+		if permissions.ValidUntil != nil {
+			values[":validUntil"] = &types.AttributeValueMemberS{Value: permissions.ValidUntil.UTC().Format(time.RFC3339Nano)}
+			sets = append(sets, "#validUntil = :validUntil")
+		} else {
+			removes = append(removes, "#validUntil")
+		}
+	}
+
+	var updateExpression []string
+	if len(sets) > 0 {
+		updateExpression = append(updateExpression, "SET "+strings.Join(sets, ", "))
+	}
+	if len(removes) > 0 {
+		updateExpression = append(updateExpression, "REMOVE "+strings.Join(removes, ", "))
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                aws.String(r.config.TableName),
+		Key:                      k.metadata(UserPrefix, string(id)).attributes(),
+		UpdateExpression:         aws.String(strings.Join(updateExpression, " ")),
+		ConditionExpression:      aws.String(condition),
+		ExpressionAttributeNames: names,
+	}
+	if len(values) > 0 {
+		input.ExpressionAttributeValues = values
+	}
+
+	if _, err := r.client.UpdateItem(ctx, input); err != nil {
+		var condCheckFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &condCheckFailed) {
+			return repository.ErrConflict
+		}
+		return fmt.Errorf("failed to set effective permissions: %w", err)
+	}
+	return nil
+}
+
+func permissionStrings(ids []domain.PermissionID) []string {
+	if len(ids) == 0 {
+		return nil
+	}
+	result := make([]string, len(ids))
+	for i, id := range ids {
+		result[i] = string(id)
+	}
+	return result
+}
+
+func permissionIDs(values []string) []domain.PermissionID {
+	if len(values) == 0 {
+		return nil
+	}
+	result := make([]domain.PermissionID, len(values))
+	for i, value := range values {
+		result[i] = domain.PermissionID(value)
+	}
+	return result
+}
@@ -0,0 +1,33 @@
+package dynamodb
+
+import (
+	"testing"
+
+	"aws-dynamodb-store/internal/repository/dynamodb/dynamodbtest"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// fakeDynamoDB is the in-memory table the request logic is tested against.
+type fakeDynamoDB = dynamodbtest.Fake
+
+const fakeEntityTypeIndex = "EntityTypeIndex"
+
+// newFakeDynamoDB returns an empty table with the indexes of TableDefinition.
+func newFakeDynamoDB() *fakeDynamoDB {
+	return dynamodbtest.New(
+		dynamodbtest.Index{Name: GSI1Name, Hash: "SK", Range: "PK"},
+		dynamodbtest.Index{Name: fakeEntityTypeIndex, Hash: "EntityType", Range: "EntityID"},
+	)
+}
+
+// attributeMap marshals v, an item struct of the package, for Put.
+func attributeMap(t *testing.T, v any) map[string]types.AttributeValue {
+	t.Helper()
+	item, err := attributevalue.MarshalMap(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return item
+}
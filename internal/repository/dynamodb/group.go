@@ -0,0 +1,418 @@
+package dynamodb
+
+import (
+	"aws-dynamodb-store/internal/config"
+	"aws-dynamodb-store/internal/domain"
+	"aws-dynamodb-store/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Groups live under GROUP#groupID / METADATA#groupID. Memberships are stored
+// as USER#userID / GROUP#groupID edges and roles granted to a group as
+// GROUP#groupID / ROLE#roleID edges, GSI1 serves the reverse lookups.
+
+type groupItem struct {
+	baseItem
+	ID          domain.GroupID `dynamodbav:"EntityID"`
+	DisplayName string         `dynamodbav:"DisplayName"`
+	Description string         `dynamodbav:"Description,omitempty"`
+	MemberCount int            `dynamodbav:"MemberCount,omitempty"`
+	CreatedAt   time.Time      `dynamodbav:"CreatedAt"`
+	UpdatedAt   time.Time      `dynamodbav:"UpdatedAt"`
+}
+
+type DynamoDBGroupRepository struct {
+	client DynamoDBAPI
+	config config.DynamoDBConfig
+}
+
+func NewDynamoDBGroupRepository(client DynamoDBAPI, config config.DynamoDBConfig) repository.GroupRepository {
+	return &DynamoDBGroupRepository{client: client, config: config}
+}
+
+func groupToItem(k tenantKeys, group *domain.Group) *groupItem {
+	key := k.metadata(GroupPrefix, string(group.ID))
+	return &groupItem{
+		baseItem: baseItem{
+			PK:         key.PK,
+			SK:         key.SK,
+			EntityType: k.entityType(EntityTypeGroup),
+		},
+		ID:          group.ID,
+		DisplayName: group.DisplayName,
+		Description: group.Description,
+		CreatedAt:   group.CreatedAt,
+		UpdatedAt:   group.UpdatedAt,
+	}
+}
+
+func itemToGroup(item *groupItem) *domain.Group {
+	return &domain.Group{
+		ID:          item.ID,
+		DisplayName: item.DisplayName,
+		Description: item.Description,
+		MemberCount: item.MemberCount,
+		CreatedAt:   item.CreatedAt,
+		UpdatedAt:   item.UpdatedAt,
+	}
+}
+
+func (r *DynamoDBGroupRepository) CreateGroup(ctx context.Context, group *domain.Group) error {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return err
+	}
+
+	group.CreatedAt = time.Now().UTC()
+	group.UpdatedAt = group.CreatedAt
+	item := groupToItem(k, group)
+
+	return createItem(ctx, r.client, r.config.TableName, item)
+}
+
+func (r *DynamoDBGroupRepository) GetGroupByID(ctx context.Context, id domain.GroupID) (*domain.Group, error) {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pk := k.key(GroupPrefix, string(id))
+	sk := k.key(MetadataPrefix, string(id))
+
+	out, err := getItemById(ctx, r.client, r.config.TableName, pk, sk)
+	if err != nil {
+		return nil, err
+	}
+
+	var groupItem groupItem
+	if err := attributevalue.UnmarshalMap(out.Item, &groupItem); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal group item: %w", err)
+	}
+	if groupItem.DeletedAt != nil {
+		return nil, repository.ErrNotFound
+	}
+	return itemToGroup(&groupItem), nil
+}
+
+// DeleteGroup removes the group, its role assignments and its memberships.
+func (r *DynamoDBGroupRepository) DeleteGroup(ctx context.Context, id domain.GroupID) error {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return err
+	}
+
+	return deleteEntity(ctx, r.client, r.config.TableName, k, GroupPrefix, string(id))
+}
+
+func (r *DynamoDBGroupRepository) ListAllGroups(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.Group], error) {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	queryInput := &dynamodb.QueryInput{
+		TableName:              aws.String(r.config.TableName),
+		IndexName:              aws.String(r.config.EntityTypeIndex),
+		KeyConditionExpression: aws.String("EntityType = :entityTypeVal"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":entityTypeVal": &types.AttributeValueMemberS{Value: k.entityType(EntityTypeGroup)},
+		},
+	}
+
+	items, nextCursor, err := queryPage(ctx, r.client, queryInput, page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query groups: %w", err)
+	}
+
+	keys := make([]itemKey, 0, len(items))
+	for _, item := range items {
+		var partialGroup groupItem
+		if err := attributevalue.UnmarshalMap(item, &partialGroup); err != nil {
+			log.Print(err.Error())
+			continue
+		}
+		keys = append(keys, k.metadata(GroupPrefix, string(partialGroup.ID)))
+	}
+
+	groups, err := hydrate(ctx, r.client, r.config.TableName, keys, itemToGroup)
+	if err != nil {
+		return nil, err
+	}
+	return &repository.Page[*domain.Group]{Items: groups, NextCursor: nextCursor}, nil
+}
+
+func (r *DynamoDBGroupRepository) AddUserToGroup(ctx context.Context, userID domain.UserID, groupID domain.GroupID) error {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := putEdge(ctx, r.client, r.config.TableName, k.key(UserPrefix, string(userID)), k.key(GroupPrefix, string(groupID)), EntityTypeGroupMembership); err != nil {
+		return fmt.Errorf("failed to add user to group: %w", err)
+	}
+	return nil
+}
+
+func (r *DynamoDBGroupRepository) RemoveUserFromGroup(ctx context.Context, userID domain.UserID, groupID domain.GroupID) error {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := deleteEdge(ctx, r.client, r.config.TableName, k.key(UserPrefix, string(userID)), k.key(GroupPrefix, string(groupID))); err != nil {
+		return fmt.Errorf("failed to remove user from group: %w", err)
+	}
+	return nil
+}
+
+func (r *DynamoDBGroupRepository) GetUserGroups(ctx context.Context, userID domain.UserID, page repository.PageRequest) (*repository.Page[*domain.Group], error) {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	queryInput := &dynamodb.QueryInput{
+		TableName:              aws.String(r.config.TableName),
+		KeyConditionExpression: aws.String("PK = :pkVal AND begins_with(SK, :skPrefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pkVal":    &types.AttributeValueMemberS{Value: k.key(UserPrefix, string(userID))},
+			":skPrefix": &types.AttributeValueMemberS{Value: k.key(GroupPrefix, "")},
+		},
+	}
+
+	items, nextCursor, err := queryPage(ctx, r.client, queryInput, page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user groups: %w", err)
+	}
+
+	keys := make([]itemKey, 0, len(items))
+	for _, item := range items {
+		var base baseItem
+		if err := attributevalue.UnmarshalMap(item, &base); err != nil {
+			continue
+		}
+		// SK should be like GROUP#group-id
+		keys = append(keys, k.metadata(GroupPrefix, keyID(base.SK, GroupPrefix)))
+	}
+
+	groups, err := hydrate(ctx, r.client, r.config.TableName, keys, itemToGroup)
+	if err != nil {
+		return nil, err
+	}
+	return &repository.Page[*domain.Group]{Items: groups, NextCursor: nextCursor}, nil
+}
+
+func (r *DynamoDBGroupRepository) ListGroupMembers(ctx context.Context, groupID domain.GroupID, page repository.PageRequest) (*repository.Page[*domain.User], error) {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	queryInput := &dynamodb.QueryInput{
+		TableName:              aws.String(r.config.TableName),
+		IndexName:              aws.String(GSI1Name), // GSI1PK = SK, GSI1SK = PK
+		KeyConditionExpression: aws.String("SK = :skVal AND begins_with(PK, :pkPrefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":skVal":    &types.AttributeValueMemberS{Value: k.key(GroupPrefix, string(groupID))},
+			":pkPrefix": &types.AttributeValueMemberS{Value: k.key(UserPrefix, "")},
+		},
+	}
+
+	items, nextCursor, err := queryPage(ctx, r.client, queryInput, page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query group members using GSI1: %w", err)
+	}
+
+	keys := make([]itemKey, 0, len(items))
+	for _, itemMap := range items {
+		var base baseItem
+		if err := attributevalue.UnmarshalMap(itemMap, &base); err != nil {
+			continue
+		}
+		// PK should be USER#userID
+		keys = append(keys, k.metadata(UserPrefix, keyID(base.PK, UserPrefix)))
+	}
+
+	users, err := hydrate(ctx, r.client, r.config.TableName, keys, itemToUser)
+	if err != nil {
+		return nil, err
+	}
+	return &repository.Page[*domain.User]{Items: users, NextCursor: nextCursor}, nil
+}
+
+// RecountGroupMembers counts the members of the group and stores the count on
+// its metadata item.
+func (r *DynamoDBGroupRepository) RecountGroupMembers(ctx context.Context, groupID domain.GroupID) (int, error) {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	paginator := dynamodb.NewQueryPaginator(r.client, &dynamodb.QueryInput{
+		TableName:              aws.String(r.config.TableName),
+		IndexName:              aws.String(GSI1Name), // GSI1PK = SK, GSI1SK = PK
+		KeyConditionExpression: aws.String("SK = :skVal AND begins_with(PK, :pkPrefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":skVal":    &types.AttributeValueMemberS{Value: k.key(GroupPrefix, string(groupID))},
+			":pkPrefix": &types.AttributeValueMemberS{Value: k.key(UserPrefix, "")},
+		},
+		Select: types.SelectCount,
+	})
+	count := 0
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("failed to count group members using GSI1: %w", err)
+		}
+		count += int(page.Count)
+	}
+
+	_, err = r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(r.config.TableName),
+		Key:                 k.metadata(GroupPrefix, string(groupID)).attributes(),
+		UpdateExpression:    aws.String("SET MemberCount = :count"),
+		ConditionExpression: aws.String("attribute_exists(PK)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":count": &types.AttributeValueMemberN{Value: strconv.Itoa(count)},
+		},
+	})
+	if err != nil {
+		var condCheckFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &condCheckFailed) {
+			return 0, repository.ErrNotFound
+		}
+		return 0, fmt.Errorf("failed to store group member count: %w", err)
+	}
+	return count, nil
+}
+
+// ListGroupsWithRole returns the groups roleID is assigned to.
+func (r *DynamoDBGroupRepository) ListGroupsWithRole(ctx context.Context, roleID domain.RoleID, page repository.PageRequest) (*repository.Page[*domain.Group], error) {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	queryInput := &dynamodb.QueryInput{
+		TableName:              aws.String(r.config.TableName),
+		IndexName:              aws.String(GSI1Name), // GSI1PK = SK, GSI1SK = PK
+		KeyConditionExpression: aws.String("SK = :skVal AND begins_with(PK, :pkPrefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":skVal":    &types.AttributeValueMemberS{Value: k.key(RolePrefix, string(roleID))},
+			":pkPrefix": &types.AttributeValueMemberS{Value: k.key(GroupPrefix, "")},
+		},
+	}
+
+	items, nextCursor, err := queryPage(ctx, r.client, queryInput, page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query groups with role using GSI1: %w", err)
+	}
+
+	keys := make([]itemKey, 0, len(items))
+	for _, item := range items {
+		var base baseItem
+		if err := attributevalue.UnmarshalMap(item, &base); err != nil {
+			continue
+		}
+		// PK should be GROUP#groupID
+		keys = append(keys, k.metadata(GroupPrefix, keyID(base.PK, GroupPrefix)))
+	}
+
+	groups, err := hydrate(ctx, r.client, r.config.TableName, keys, itemToGroup)
+	if err != nil {
+		return nil, err
+	}
+	return &repository.Page[*domain.Group]{Items: groups, NextCursor: nextCursor}, nil
+}
+
+func (r *DynamoDBGroupRepository) AssignRoleToGroup(ctx context.Context, groupID domain.GroupID, roleID domain.RoleID) error {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := putEdge(ctx, r.client, r.config.TableName, k.key(GroupPrefix, string(groupID)), k.key(RolePrefix, string(roleID)), EntityTypeGroupRoleAssignment); err != nil {
+		return fmt.Errorf("failed to assign role to group: %w", err)
+	}
+	return nil
+}
+
+func (r *DynamoDBGroupRepository) RemoveRoleFromGroup(ctx context.Context, groupID domain.GroupID, roleID domain.RoleID) error {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := deleteEdge(ctx, r.client, r.config.TableName, k.key(GroupPrefix, string(groupID)), k.key(RolePrefix, string(roleID))); err != nil {
+		return fmt.Errorf("failed to remove role from group: %w", err)
+	}
+	return nil
+}
+
+func (r *DynamoDBGroupRepository) GetGroupRoles(ctx context.Context, groupID domain.GroupID, page repository.PageRequest) (*repository.Page[*domain.Role], error) {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	queryInput := &dynamodb.QueryInput{
+		TableName:              aws.String(r.config.TableName),
+		KeyConditionExpression: aws.String("PK = :pkVal AND begins_with(SK, :skPrefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pkVal":    &types.AttributeValueMemberS{Value: k.key(GroupPrefix, string(groupID))},
+			":skPrefix": &types.AttributeValueMemberS{Value: k.key(RolePrefix, "")},
+		},
+	}
+
+	items, nextCursor, err := queryPage(ctx, r.client, queryInput, page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query group roles: %w", err)
+	}
+
+	keys := make([]itemKey, 0, len(items))
+	for _, item := range items {
+		var base baseItem
+		if err := attributevalue.UnmarshalMap(item, &base); err != nil {
+			continue
+		}
+		// SK should be like ROLE#role-id
+		keys = append(keys, k.metadata(RolePrefix, keyID(base.SK, RolePrefix)))
+	}
+
+	roles, err := hydrate(ctx, r.client, r.config.TableName, keys, itemToRole)
+	if err != nil {
+		return nil, err
+	}
+	return &repository.Page[*domain.Role]{Items: roles, NextCursor: nextCursor}, nil
+}
+
+func putEdge(ctx context.Context, client DynamoDBAPI, tableName string, pk string, sk string, entityType string) error {
+	_, err := client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(tableName),
+		Item: map[string]types.AttributeValue{
+			"PK":         &types.AttributeValueMemberS{Value: pk},
+			"SK":         &types.AttributeValueMemberS{Value: sk},
+			"EntityType": &types.AttributeValueMemberS{Value: entityType},
+			"AssignedAt": &types.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339)},
+		},
+	})
+	return err
+}
+
+func deleteEdge(ctx context.Context, client DynamoDBAPI, tableName string, pk string, sk string) error {
+	_, err := client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(tableName),
+		Key:       itemKey{PK: pk, SK: sk}.attributes(),
+	})
+	return err
+}
@@ -0,0 +1,271 @@
+package dynamodb
+
+import (
+	"aws-dynamodb-store/internal/domain"
+	"aws-dynamodb-store/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// legacyPrefixes lead the PKs of the items written before keys were
+// tenant-scoped. Resource binding partitions (USER#u#RESOURCE#path) are
+// covered by USER#.
+var legacyPrefixes = []string{UserPrefix, RolePrefix, PermissionPrefix, GroupPrefix}
+
+// MigrationAction moves a single item written before keys were tenant-scoped
+// to the keys of a tenant.
+type MigrationAction struct {
+	PK            string
+	SK            string
+	EntityType    string
+	NewPK         string
+	NewSK         string
+	NewEntityType string
+	Reason        string // Why a metadata item is repaired on the way, if it is
+
+	item map[string]types.AttributeValue
+}
+
+func (a MigrationAction) String() string {
+	if a.Reason != "" {
+		return fmt.Sprintf("%s / %s (%s) -> %s / %s (%s): %s", a.PK, a.SK, a.EntityType, a.NewPK, a.NewSK, a.NewEntityType, a.Reason)
+	}
+	return fmt.Sprintf("%s / %s (%s) -> %s / %s (%s)", a.PK, a.SK, a.EntityType, a.NewPK, a.NewSK, a.NewEntityType)
+}
+
+// MigrationReport is what MigrateTenant and RepairTable found and applied.
+type MigrationReport struct {
+	Scanned int
+	Actions []MigrationAction
+	// Skipped lists the unscoped items that are not part of the RBAC layout,
+	// as "PK / SK". They are left in place.
+	Skipped []string
+	// Unclassified lists the metadata items under ROLE# keys that are both a
+	// role and a permission, as "PK / SK: reason". They are left in place to
+	// be fixed by hand; running the migration again moves them.
+	Unclassified []string
+	Applied      int
+}
+
+// MigrateTenant moves every item written before keys were tenant-scoped into
+// tenantID: PK and SK get the TENANT#tenantID# prefix, and so does the
+// EntityType of metadata items, which keeps EntityTypeIndex listings scoped.
+// Edges keep their EntityType. Items that are already tenant-scoped are left
+// alone. When dryRun is set only the report is produced.
+//
+// Older builds stored permission metadata under ROLE# keys and tagged roles
+// and permissions as USER. Metadata items are moved to the canonical layout
+// planRepair finds for them, and left in place when it cannot tell.
+//
+// Each item is moved with a transactional put+delete, so running the
+// migration again after an interruption picks up where it left off.
+func MigrateTenant(ctx context.Context, client DynamoDBAPI, tableName string, tenantID domain.TenantID, dryRun bool) (*MigrationReport, error) {
+	k, err := keysFor(repository.WithTenant(ctx, tenantID))
+	if err != nil || tenantID == "" {
+		return nil, fmt.Errorf("invalid tenant %q: %w", tenantID, repository.ErrInvalidTenant)
+	}
+
+	report, scan, err := scanLegacy(ctx, client, tableName)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range scan.edges {
+		report.Actions = append(report.Actions, planMigration(k, item))
+	}
+	for _, item := range scan.metadata {
+		if action, ok := report.planMetadata(k, item, scan.evidence); ok {
+			report.Actions = append(report.Actions, action)
+		}
+	}
+
+	if dryRun {
+		return report, nil
+	}
+	return report, report.apply(ctx, client, tableName)
+}
+
+// RepairTable rewrites the metadata items written before keys were
+// tenant-scoped to the canonical ROLE#/PERMISSION# layout planRepair finds for
+// them, without moving anything into a tenant. Only the inconsistent items
+// are reported as actions. Edges, canonical items and tenant-scoped items,
+// which are always written in the canonical layout, are left alone. When
+// dryRun is set only the report is produced.
+//
+// Items that change key are moved with a transactional put+delete, the others
+// are rewritten with a conditional put, so running the repair again after an
+// interruption picks up where it left off.
+func RepairTable(ctx context.Context, client DynamoDBAPI, tableName string, dryRun bool) (*MigrationReport, error) {
+	report, scan, err := scanLegacy(ctx, client, tableName)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range scan.metadata {
+		if action, ok := report.planMetadata("", item, scan.evidence); ok && action.Reason != "" {
+			report.Actions = append(report.Actions, action)
+		}
+	}
+
+	if dryRun {
+		return report, nil
+	}
+	return report, report.apply(ctx, client, tableName)
+}
+
+// legacyScan holds the items written before keys were tenant-scoped.
+type legacyScan struct {
+	edges    []map[string]types.AttributeValue
+	metadata []map[string]types.AttributeValue // Planned once every edge has been seen
+	evidence legacyEvidence
+}
+
+// scanLegacy scans the whole table, counting the items and reporting the
+// unscoped ones that are not part of the RBAC layout as skipped.
+func scanLegacy(ctx context.Context, client DynamoDBAPI, tableName string) (*MigrationReport, *legacyScan, error) {
+	report := &MigrationReport{}
+	scan := &legacyScan{evidence: newLegacyEvidence()}
+
+	paginator := dynamodb.NewScanPaginator(client, &dynamodb.ScanInput{
+		TableName: aws.String(tableName),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to scan table: %w", err)
+		}
+		for _, item := range page.Items {
+			report.Scanned++
+			pk, sk := stringAttr(item, "PK"), stringAttr(item, "SK")
+			switch {
+			case strings.HasPrefix(pk, TenantPrefix):
+			case !isLegacyKey(pk):
+				report.Skipped = append(report.Skipped, pk+" / "+sk)
+			case strings.HasPrefix(sk, MetadataPrefix):
+				scan.metadata = append(scan.metadata, item)
+			default:
+				scan.evidence.add(pk, sk)
+				scan.edges = append(scan.edges, item)
+			}
+		}
+	}
+	return report, scan, nil
+}
+
+// planMetadata plans the move of a metadata item to the canonical layout
+// under k, recording it as unclassified when planRepair cannot tell.
+func (report *MigrationReport) planMetadata(k tenantKeys, item map[string]types.AttributeValue, evidence legacyEvidence) (MigrationAction, bool) {
+	repair, err := planRepair(item, evidence)
+	if err != nil {
+		report.Unclassified = append(report.Unclassified, fmt.Sprintf("%s / %s: %v", stringAttr(item, "PK"), stringAttr(item, "SK"), err))
+		return MigrationAction{}, false
+	}
+	action := planMigration(k, item)
+	action.NewPK = string(k) + repair.PK
+	action.NewSK = string(k) + repair.SK
+	action.NewEntityType = k.entityType(repair.EntityType)
+	action.Reason = repair.Reason
+	return action, true
+}
+
+func (report *MigrationReport) apply(ctx context.Context, client DynamoDBAPI, tableName string) error {
+	for _, action := range report.Actions {
+		if err := applyMigration(ctx, client, tableName, action); err != nil {
+			return fmt.Errorf("failed to migrate %s / %s: %w", action.PK, action.SK, err)
+		}
+		report.Applied++
+	}
+	return nil
+}
+
+func isLegacyKey(pk string) bool {
+	for _, prefix := range legacyPrefixes {
+		if strings.HasPrefix(pk, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func planMigration(k tenantKeys, item map[string]types.AttributeValue) MigrationAction {
+	pk, sk := stringAttr(item, "PK"), stringAttr(item, "SK")
+	entityType := stringAttr(item, "EntityType")
+
+	return MigrationAction{
+		PK:            pk,
+		SK:            sk,
+		EntityType:    entityType,
+		NewPK:         string(k) + pk,
+		NewSK:         string(k) + sk,
+		NewEntityType: entityType,
+		item:          item,
+	}
+}
+
+func applyMigration(ctx context.Context, client DynamoDBAPI, tableName string, action MigrationAction) error {
+	newItem := make(map[string]types.AttributeValue, len(action.item))
+	for k, v := range action.item {
+		newItem[k] = v
+	}
+	newItem["PK"] = &types.AttributeValueMemberS{Value: action.NewPK}
+	newItem["SK"] = &types.AttributeValueMemberS{Value: action.NewSK}
+	if action.NewEntityType != "" {
+		newItem["EntityType"] = &types.AttributeValueMemberS{Value: action.NewEntityType}
+	}
+
+	// Guards against moving an item that changed since it was scanned.
+	unchanged := aws.String("attribute_exists(PK) AND attribute_not_exists(EntityType)")
+	var unchangedValues map[string]types.AttributeValue
+	if action.EntityType != "" {
+		unchanged = aws.String("attribute_exists(PK) AND EntityType = :oldEntityType")
+		unchangedValues = map[string]types.AttributeValue{
+			":oldEntityType": &types.AttributeValueMemberS{Value: action.EntityType},
+		}
+	}
+
+	if action.NewPK == action.PK && action.NewSK == action.SK {
+		_, err := client.PutItem(ctx, &dynamodb.PutItemInput{
+			TableName:                 aws.String(tableName),
+			Item:                      newItem,
+			ConditionExpression:       unchanged,
+			ExpressionAttributeValues: unchangedValues,
+		})
+		var condCheckFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &condCheckFailed) {
+			return fmt.Errorf("item changed since it was scanned: %w", err)
+		}
+		return err
+	}
+
+	_, err := client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{
+				Put: &types.Put{
+					TableName:           aws.String(tableName),
+					Item:                newItem,
+					ConditionExpression: aws.String("attribute_not_exists(PK)"),
+				},
+			},
+			{
+				Delete: &types.Delete{
+					TableName: aws.String(tableName),
+					Key: map[string]types.AttributeValue{
+						"PK": &types.AttributeValueMemberS{Value: action.PK},
+						"SK": &types.AttributeValueMemberS{Value: action.SK},
+					},
+					ConditionExpression:       unchanged,
+					ExpressionAttributeValues: unchangedValues,
+				},
+			},
+		},
+	})
+	var canceled *types.TransactionCanceledException
+	if errors.As(err, &canceled) {
+		return fmt.Errorf("target %s / %s already exists or source changed: %w", action.NewPK, action.NewSK, err)
+	}
+	return err
+}
@@ -0,0 +1,313 @@
+package dynamodb
+
+import (
+	"aws-dynamodb-store/internal/config"
+	"aws-dynamodb-store/internal/domain"
+	"aws-dynamodb-store/internal/repository"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func newFakeRepository(client *fakeDynamoDB) repository.Repository {
+	cfg := config.DynamoDBConfig{TableName: "rbac", EntityTypeIndex: fakeEntityTypeIndex, SoftDeleteRetentionDays: 1}
+	return repository.Repository{
+		User:       NewDynamoDBUserRepository(client, cfg),
+		Role:       NewDynamoDBRoleRepository(client, cfg),
+		Permission: NewDynamoDBPermissionRepository(client, cfg),
+		Group:      NewDynamoDBGroupRepository(client, cfg),
+	}
+}
+
+// unscope strips the tenant prefix from every key and EntityType of the
+// table, turning it into the layout written before keys were tenant-scoped.
+func unscope(client *fakeDynamoDB, k tenantKeys) {
+	for _, key := range client.Keys() {
+		item := client.Get(key.PK, key.SK)
+		client.Delete(key.PK, key.SK)
+		for _, name := range []string{"PK", "SK", "EntityType"} {
+			if value := stringAttr(item, name); value != "" {
+				item[name] = &types.AttributeValueMemberS{Value: strings.TrimPrefix(value, string(k))}
+			}
+		}
+		client.Put(item)
+	}
+}
+
+// newLegacyTable stores a user with a global and a resource-scoped role, a
+// granted permission and a group membership in the unscoped layout, next to
+// an item that is not part of it.
+func newLegacyTable(t *testing.T) *fakeDynamoDB {
+	t.Helper()
+	client := newFakeDynamoDB()
+	repo := newFakeRepository(client)
+	ctx := repository.WithTenant(context.Background(), "legacy")
+
+	steps := []error{
+		repo.User.CreateUser(ctx, &domain.User{ID: "u1", DisplayName: "Alice"}),
+		repo.Role.CreateRole(ctx, &domain.Role{ID: "role-editor", DisplayName: "Editor"}),
+		repo.Permission.CreatePermission(ctx, &domain.Permission{ID: "document:read", DisplayName: "Read"}),
+		repo.Role.AssignPermissionToRole(ctx, &domain.PermissionGrant{RoleID: "role-editor", PermissionID: "document:read"}),
+		repo.User.AssignRoleToUser(ctx, &domain.RoleAssignment{UserID: "u1", RoleID: "role-editor"}),
+		repo.User.AssignRoleToUser(ctx, &domain.RoleAssignment{UserID: "u1", RoleID: "role-editor", ResourceID: "org/acme"}),
+		repo.Group.CreateGroup(ctx, &domain.Group{ID: "g1", DisplayName: "Staff"}),
+		repo.Group.AddUserToGroup(ctx, "u1", "g1"),
+	}
+	for _, err := range steps {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	unscope(client, "TENANT#legacy#")
+	client.Put(map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: "CONFIG#flags"},
+		"SK": &types.AttributeValueMemberS{Value: "v1"},
+	})
+	return client
+}
+
+func TestMigrateTenant(t *testing.T) {
+	client := newLegacyTable(t)
+	before := client.Keys()
+
+	report, err := MigrateTenant(context.Background(), client, "rbac", "acme", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Actions) != len(before)-1 || report.Applied != 0 {
+		t.Errorf("expected %d planned and no applied moves; got %d and %d", len(before)-1, len(report.Actions), report.Applied)
+	}
+	if want := []string{"CONFIG#flags / v1"}; !reflect.DeepEqual(report.Skipped, want) {
+		t.Errorf("expected skipped %v; got %v", want, report.Skipped)
+	}
+	if after := client.Keys(); !reflect.DeepEqual(after, before) {
+		t.Errorf("expected a dry run to leave the table alone")
+	}
+
+	report, err = MigrateTenant(context.Background(), client, "rbac", "acme", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Applied != len(report.Actions) {
+		t.Errorf("expected all %d moves to be applied; got %d", len(report.Actions), report.Applied)
+	}
+
+	repo := newFakeRepository(client)
+	ctx := repository.WithTenant(context.Background(), "acme")
+	page := repository.PageRequest{Limit: 10}
+	if _, err := repo.User.GetUserByID(ctx, "u1"); err != nil {
+		t.Errorf("GetUserByID: %v", err)
+	}
+	if roles, err := repo.Role.ListAllRoles(ctx, page); err != nil || len(roles.Items) != 1 {
+		t.Errorf("ListAllRoles: expected the role; got %v, %v", roles, err)
+	}
+	if roles, err := repo.User.GetUserRoles(ctx, "u1", page); err != nil || len(roles.Items) != 1 {
+		t.Errorf("GetUserRoles: expected the role; got %v, %v", roles, err)
+	}
+	if permissions, err := repo.Role.GetRolePermissions(ctx, "role-editor", page); err != nil || len(permissions.Items) != 1 {
+		t.Errorf("GetRolePermissions: expected the permission; got %v, %v", permissions, err)
+	}
+	if resources, err := repo.User.ListUserResources(ctx, "u1", "", page); err != nil || !reflect.DeepEqual(resources.Items, []string{"org/acme"}) {
+		t.Errorf("ListUserResources: expected org/acme; got %v, %v", resources, err)
+	}
+	if groups, err := repo.Group.GetUserGroups(ctx, "u1", page); err != nil || len(groups.Items) != 1 {
+		t.Errorf("GetUserGroups: expected the group; got %v, %v", groups, err)
+	}
+	if client.Get("CONFIG#flags", "v1") == nil {
+		t.Errorf("expected the unknown item to stay in place")
+	}
+
+	// Running it again finds nothing left to move.
+	report, err = MigrateTenant(context.Background(), client, "rbac", "acme", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Actions) != 0 {
+		t.Errorf("expected nothing left to migrate; got %v", report.Actions)
+	}
+}
+
+func TestMigrateTenantRepairsLegacyMetadata(t *testing.T) {
+	client := newLegacyTable(t)
+	// A permission written under a ROLE# key and tagged as USER, which the
+	// grant of role-editor identifies, and a generated role ID that is granted
+	// as a permission, which is both.
+	client.Delete("PERMISSION#document:read", "METADATA#document:read")
+	client.Put(metadataItem("ROLE#document:read", "METADATA#document:read", EntityTypeUser, "document:read"))
+	client.Put(metadataItem("ROLE#role-report", "METADATA#role-report", EntityTypeUser, "role-report"))
+	client.Put(map[string]types.AttributeValue{
+		"PK":         &types.AttributeValueMemberS{Value: "ROLE#role-editor"},
+		"SK":         &types.AttributeValueMemberS{Value: "PERMISSION#role-report"},
+		"EntityType": &types.AttributeValueMemberS{Value: EntityTypeRolePermissionAssignment},
+	})
+
+	report, err := MigrateTenant(context.Background(), client, "rbac", "acme", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Unclassified) != 1 || !strings.HasPrefix(report.Unclassified[0], "ROLE#role-report / METADATA#role-report: ") {
+		t.Errorf("expected ROLE#role-report to be unclassified; got %v", report.Unclassified)
+	}
+	if client.Get("ROLE#role-report", "METADATA#role-report") == nil {
+		t.Errorf("expected the unclassified item to stay in place")
+	}
+
+	repo := newFakeRepository(client)
+	ctx := repository.WithTenant(context.Background(), "acme")
+	if _, err := repo.Permission.GetPermissionByID(ctx, "document:read"); err != nil {
+		t.Errorf("expected the permission under its canonical key; got %v", err)
+	}
+	permissions, err := repo.Permission.ListAllPermissions(ctx, repository.PageRequest{Limit: 10})
+	if err != nil || len(permissions.Items) != 1 {
+		t.Errorf("expected the permission to be listed; got %v, %v", permissions, err)
+	}
+	if _, err := repo.Role.GetRoleByID(ctx, "role-report"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("expected the unclassified item not to become a role; got %v", err)
+	}
+}
+
+// baselineItem is a metadata item as the first builds wrote it: users under
+// USER# keys, and both roles and permissions under ROLE# keys, all tagged as
+// USER.
+func baselineItem(prefix string, id string) map[string]types.AttributeValue {
+	item := metadataItem(prefix+id, MetadataPrefix+id, EntityTypeUser, id)
+	item["DisplayName"] = &types.AttributeValueMemberS{Value: id}
+	return item
+}
+
+// newBaselineTable stores a user assigned a generated role, and a permission,
+// as the first builds wrote them. Those builds stored no grants.
+func newBaselineTable() *fakeDynamoDB {
+	client := newFakeDynamoDB()
+	client.Put(baselineItem(UserPrefix, "u1"))
+	client.Put(baselineItem(RolePrefix, "role-editor"))
+	client.Put(baselineItem(RolePrefix, "document:create"))
+	client.Put(map[string]types.AttributeValue{
+		"PK":         &types.AttributeValueMemberS{Value: "USER#u1"},
+		"SK":         &types.AttributeValueMemberS{Value: "ROLE#role-editor"},
+		"EntityType": &types.AttributeValueMemberS{Value: EntityTypeUserRoleAssignment},
+	})
+	return client
+}
+
+func TestMigrateTenantRepairsBaselineTables(t *testing.T) {
+	client := newBaselineTable()
+
+	report, err := MigrateTenant(context.Background(), client, "rbac", "acme", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Unclassified) != 0 || report.Applied != 4 {
+		t.Errorf("expected all 4 items to be moved; got %d applied, unclassified %v", report.Applied, report.Unclassified)
+	}
+
+	tests := []struct {
+		pk         string
+		sk         string
+		entityType string
+	}{
+		{"TENANT#acme#USER#u1", "TENANT#acme#METADATA#u1", "TENANT#acme#" + EntityTypeUser},
+		{"TENANT#acme#ROLE#role-editor", "TENANT#acme#METADATA#role-editor", "TENANT#acme#" + EntityTypeRole},
+		{"TENANT#acme#PERMISSION#document:create", "TENANT#acme#METADATA#document:create", "TENANT#acme#" + EntityTypePermission},
+	}
+	for _, tt := range tests {
+		item := client.Get(tt.pk, tt.sk)
+		if item == nil {
+			t.Errorf("expected %s / %s", tt.pk, tt.sk)
+			continue
+		}
+		if got := stringAttr(item, "EntityType"); got != tt.entityType {
+			t.Errorf("%s: expected EntityType %s; got %s", tt.pk, tt.entityType, got)
+		}
+	}
+
+	repo := newFakeRepository(client)
+	ctx := repository.WithTenant(context.Background(), "acme")
+	page := repository.PageRequest{Limit: 10}
+	if roles, err := repo.Role.ListAllRoles(ctx, page); err != nil || len(roles.Items) != 1 || roles.Items[0].ID != "role-editor" {
+		t.Errorf("ListAllRoles: expected role-editor; got %v, %v", roles, err)
+	}
+	if permissions, err := repo.Permission.ListAllPermissions(ctx, page); err != nil || len(permissions.Items) != 1 || permissions.Items[0].ID != "document:create" {
+		t.Errorf("ListAllPermissions: expected document:create; got %v, %v", permissions, err)
+	}
+	if roles, err := repo.User.GetUserRoles(ctx, "u1", page); err != nil || len(roles.Items) != 1 {
+		t.Errorf("GetUserRoles: expected the role; got %v, %v", roles, err)
+	}
+}
+
+func TestRepairTable(t *testing.T) {
+	client := newBaselineTable()
+	before := client.Keys()
+
+	report, err := RepairTable(context.Background(), client, "rbac", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, action := range report.Actions {
+		got = append(got, action.String())
+	}
+	want := []string{
+		"ROLE#document:create / METADATA#document:create (USER) -> PERMISSION#document:create / METADATA#document:create (PERMISSION): permission metadata stored under the wrong key",
+		"ROLE#role-editor / METADATA#role-editor (USER) -> ROLE#role-editor / METADATA#role-editor (ROLE): EntityType should be ROLE",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected actions\n%v\ngot\n%v", want, got)
+	}
+	if after := client.Keys(); !reflect.DeepEqual(after, before) {
+		t.Errorf("expected a dry run to leave the table alone")
+	}
+
+	report, err = RepairTable(context.Background(), client, "rbac", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Applied != len(want) {
+		t.Errorf("expected %d repairs; got %d", len(want), report.Applied)
+	}
+	tests := []struct {
+		pk         string
+		sk         string
+		entityType string
+	}{
+		{"USER#u1", "METADATA#u1", EntityTypeUser},
+		{"ROLE#role-editor", "METADATA#role-editor", EntityTypeRole},
+		{"PERMISSION#document:create", "METADATA#document:create", EntityTypePermission},
+		{"USER#u1", "ROLE#role-editor", EntityTypeUserRoleAssignment},
+	}
+	for _, tt := range tests {
+		item := client.Get(tt.pk, tt.sk)
+		if item == nil {
+			t.Errorf("expected %s / %s", tt.pk, tt.sk)
+			continue
+		}
+		if got := stringAttr(item, "EntityType"); got != tt.entityType {
+			t.Errorf("%s / %s: expected EntityType %s; got %s", tt.pk, tt.sk, tt.entityType, got)
+		}
+	}
+	if client.Get("ROLE#document:create", "METADATA#document:create") != nil {
+		t.Errorf("expected the permission to leave its ROLE# key")
+	}
+
+	// Running it again finds nothing left to repair.
+	report, err = RepairTable(context.Background(), client, "rbac", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Actions) != 0 {
+		t.Errorf("expected nothing left to repair; got %v", report.Actions)
+	}
+}
+
+func TestMigrateTenantRejectsInvalidTenants(t *testing.T) {
+	for _, tenant := range []domain.TenantID{"", "a#b"} {
+		_, err := MigrateTenant(context.Background(), newFakeDynamoDB(), "rbac", tenant, true)
+		if !errors.Is(err, repository.ErrInvalidTenant) {
+			t.Errorf("tenant %q: expected ErrInvalidTenant; got %v", tenant, err)
+		}
+	}
+}
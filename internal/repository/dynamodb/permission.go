@@ -6,10 +6,13 @@ import (
 	"aws-dynamodb-store/internal/repository"
 	"context"
 	"fmt"
+	"log"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
 type permissionItem struct {
@@ -19,30 +22,32 @@ type permissionItem struct {
 	Description string              `dynamodbav:"Description,omitempty"`
 	CreatedAt   time.Time           `dynamodbav:"CreatedAt"`
 	UpdatedAt   time.Time           `dynamodbav:"UpdatedAt"`
+	Version     int64               `dynamodbav:"Version"`
 }
 
 type DynamoDBPermissionRepository struct {
-	client *dynamodb.Client
+	client DynamoDBAPI
 	config config.DynamoDBConfig
 }
 
-func NewDynamoDBPermissionRepository(client *dynamodb.Client, config config.DynamoDBConfig) repository.PermissionRepository {
+func NewDynamoDBPermissionRepository(client DynamoDBAPI, config config.DynamoDBConfig) repository.PermissionRepository {
 	return &DynamoDBPermissionRepository{client: client, config: config}
 }
 
-func permissionToItem(permission *domain.Permission) *permissionItem {
-	pk := RolePrefix + string(permission.ID)
+func permissionToItem(k tenantKeys, permission *domain.Permission) *permissionItem {
+	key := k.metadata(PermissionPrefix, string(permission.ID))
 	return &permissionItem{
 		baseItem: baseItem{
-			PK:         pk,
-			SK:         MetadataPrefix + string(permission.ID),
-			EntityType: EntityTypeUser,
+			PK:         key.PK,
+			SK:         key.SK,
+			EntityType: k.entityType(EntityTypePermission),
 		},
 		ID:          permission.ID,
 		DisplayName: permission.DisplayName,
 		Description: permission.Description,
 		CreatedAt:   permission.CreatedAt,
 		UpdatedAt:   permission.UpdatedAt,
+		Version:     permission.Version,
 	}
 }
 
@@ -53,20 +58,32 @@ func itemToPermission(item *permissionItem) *domain.Permission {
 		Description: item.Description,
 		CreatedAt:   item.CreatedAt,
 		UpdatedAt:   item.UpdatedAt,
+		Version:     item.Version,
 	}
 }
 
 func (r *DynamoDBPermissionRepository) CreatePermission(ctx context.Context, permission *domain.Permission) error {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return err
+	}
+
 	permission.CreatedAt = time.Now().UTC()
 	permission.UpdatedAt = permission.CreatedAt
-	item := permissionToItem(permission)
+	permission.Version = 1
+	item := permissionToItem(k, permission)
 
 	return createItem(ctx, r.client, r.config.TableName, item)
 }
 
 func (r *DynamoDBPermissionRepository) GetPermissionByID(ctx context.Context, id domain.PermissionID) (*domain.Permission, error) {
-	pk := PermissionPrefix + string(id)
-	sk := MetadataPrefix + string(id)
+	k, err := keysFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pk := k.key(PermissionPrefix, string(id))
+	sk := k.key(MetadataPrefix, string(id))
 
 	out, err := getItemById(ctx, r.client, r.config.TableName, pk, sk)
 	if err != nil {
@@ -75,17 +92,107 @@ func (r *DynamoDBPermissionRepository) GetPermissionByID(ctx context.Context, id
 
 	var permissionItem permissionItem
 	if err := attributevalue.UnmarshalMap(out.Item, &permissionItem); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal user item: %w", err)
+		return nil, fmt.Errorf("failed to unmarshal permission item: %w", err)
+	}
+	if permissionItem.DeletedAt != nil {
+		return nil, repository.ErrNotFound
 	}
 	return itemToPermission(&permissionItem), nil
 }
 
-// TODO
-func (r *DynamoDBPermissionRepository) ListAllPermissions(ctx context.Context) ([]*domain.Permission, error) {
-	return []*domain.Permission{}, nil
+func (r *DynamoDBPermissionRepository) UpdatePermission(ctx context.Context, permission *domain.Permission) error {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return err
+	}
+
+	current, err := r.GetPermissionByID(ctx, permission.ID)
+	if err != nil {
+		return err
+	}
+	if current.Version != permission.Version {
+		return repository.ErrConflict
+	}
+
+	changes := map[string]interface{}{}
+	setIfChanged(changes, "DisplayName", current.DisplayName, permission.DisplayName)
+	setIfChanged(changes, "Description", current.Description, permission.Description)
+	if len(changes) == 0 {
+		*permission = *current
+		return nil
+	}
+
+	var updated permissionItem
+	if err := updateItem(ctx, r.client, r.config.TableName, k.metadata(PermissionPrefix, string(permission.ID)), permission.Version, changes, &updated); err != nil {
+		return err
+	}
+	*permission = *itemToPermission(&updated)
+	return nil
+}
+
+// DeletePermission removes the permission and unassigns it from every role.
+func (r *DynamoDBPermissionRepository) DeletePermission(ctx context.Context, id domain.PermissionID) error {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return err
+	}
+
+	return deleteEntity(ctx, r.client, r.config.TableName, k, PermissionPrefix, string(id))
 }
 
-// --- DynamoDBPermissionRepository ---
-// (Similar structure)
-// - CreatePermission, GetPermissionByID, UpdatePermission, DeletePermission
-// - ListAllPermissions (Query for EntityType=PERMISSION on GSI or scan)
+// SoftDeletePermission hides the permission until it is restored or its retention window
+// passes.
+func (r *DynamoDBPermissionRepository) SoftDeletePermission(ctx context.Context, id domain.PermissionID) error {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return err
+	}
+
+	return softDeleteEntity(ctx, r.client, r.config.TableName, k, PermissionPrefix, string(id), retentionWindow(r.config))
+}
+
+func (r *DynamoDBPermissionRepository) RestorePermission(ctx context.Context, id domain.PermissionID) error {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return err
+	}
+
+	return restoreEntity(ctx, r.client, r.config.TableName, k, PermissionPrefix, string(id))
+}
+
+func (r *DynamoDBPermissionRepository) ListAllPermissions(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.Permission], error) {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	queryInput := &dynamodb.QueryInput{
+		TableName:              aws.String(r.config.TableName),
+		IndexName:              aws.String(r.config.EntityTypeIndex),
+		KeyConditionExpression: aws.String("EntityType = :entityTypeVal"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":entityTypeVal": &types.AttributeValueMemberS{Value: k.entityType(EntityTypePermission)},
+		},
+	}
+
+	items, nextCursor, err := queryPage(ctx, r.client, queryInput, page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query permissions: %w", err)
+	}
+
+	keys := make([]itemKey, 0, len(items))
+	for _, item := range items {
+		var partialPermission permissionItem
+		if err := attributevalue.UnmarshalMap(item, &partialPermission); err != nil {
+			log.Print(err.Error())
+			continue
+		}
+		keys = append(keys, k.metadata(PermissionPrefix, string(partialPermission.ID)))
+	}
+
+	permissions, err := hydrate(ctx, r.client, r.config.TableName, keys, itemToPermission)
+	if err != nil {
+		return nil, err
+	}
+	return &repository.Page[*domain.Permission]{Items: permissions, NextCursor: nextCursor}, nil
+}
@@ -0,0 +1,110 @@
+package dynamodb
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Role IDs generated by the service are "role-" + display name. Older builds
+// wrote both roles and permissions under ROLE# keys tagged as USER, so the
+// prefix tells the two apart again.
+const legacyRoleIDPrefix = "role-"
+
+// errUnclassifiable is returned for ROLE#-keyed metadata items whose edges
+// make them both a role and a permission.
+var errUnclassifiable = errors.New("cannot tell whether the item is a role or a permission")
+
+// legacyEvidence collects what the edges of an unscoped table say about the
+// IDs stored under ROLE# keys.
+type legacyEvidence struct {
+	roles       map[string]bool // Assigned to users or groups, inherited from, or holding grants
+	permissions map[string]bool // Granted by a role
+}
+
+func newLegacyEvidence() legacyEvidence {
+	return legacyEvidence{roles: make(map[string]bool), permissions: make(map[string]bool)}
+}
+
+// add records the edge pk / sk.
+func (e legacyEvidence) add(pk string, sk string) {
+	switch {
+	case strings.HasPrefix(sk, RolePrefix):
+		// USER#u / ROLE#r, USER#u#RESOURCE#path / ROLE#r and GROUP#g / ROLE#r
+		e.roles[sk[len(RolePrefix):]] = true
+	case strings.HasPrefix(sk, ParentPrefix):
+		e.roles[sk[len(ParentPrefix):]] = true
+	}
+	if strings.HasPrefix(pk, RolePrefix) {
+		e.roles[pk[len(RolePrefix):]] = true
+		if strings.HasPrefix(sk, PermissionPrefix) {
+			e.permissions[sk[len(PermissionPrefix):]] = true
+		}
+	}
+}
+
+// metadataRepair is the canonical unscoped layout of a legacy metadata item.
+type metadataRepair struct {
+	PK         string
+	SK         string
+	EntityType string
+	Reason     string // Empty when the item already is canonical
+}
+
+// planRepair returns the canonical PERMISSION#/ROLE# layout and EntityType of
+// a metadata item written before keys were tenant-scoped. An item under a
+// ROLE# key is a role when it has EntityType ROLE, edges of its own or
+// pointing at it, or a generated role ID. Older builds generated every role ID
+// and wrote no grants, so any other item is permission metadata. Items that
+// are both a role and granted as a permission return errUnclassifiable.
+func planRepair(item map[string]types.AttributeValue, evidence legacyEvidence) (metadataRepair, error) {
+	pk, sk := stringAttr(item, "PK"), stringAttr(item, "SK")
+	entityType := stringAttr(item, "EntityType")
+	id := stringAttr(item, "EntityID")
+	if id == "" {
+		id = sk[len(MetadataPrefix):]
+	}
+
+	repair := metadataRepair{PK: pk, SK: MetadataPrefix + id}
+
+	switch {
+	case strings.HasPrefix(pk, UserPrefix):
+		repair.EntityType = EntityTypeUser
+	case strings.HasPrefix(pk, PermissionPrefix):
+		repair.EntityType = EntityTypePermission
+	case strings.HasPrefix(pk, GroupPrefix):
+		repair.EntityType = EntityTypeGroup
+	case strings.HasPrefix(pk, RolePrefix):
+		isRole := entityType == EntityTypeRole || evidence.roles[id] || strings.HasPrefix(id, legacyRoleIDPrefix)
+		isPermission := entityType == EntityTypePermission || evidence.permissions[id]
+		switch {
+		case isRole && isPermission:
+			return repair, fmt.Errorf("%w: conflicting evidence", errUnclassifiable)
+		case isRole:
+			repair.EntityType = EntityTypeRole
+		default:
+			// Permission metadata written under a ROLE# key.
+			repair.PK = PermissionPrefix + id
+			repair.EntityType = EntityTypePermission
+		}
+	default:
+		return repair, fmt.Errorf("unknown key %s", pk)
+	}
+
+	switch {
+	case repair.PK != pk || repair.SK != sk:
+		repair.Reason = fmt.Sprintf("%s metadata stored under the wrong key", strings.ToLower(repair.EntityType))
+	case entityType != repair.EntityType:
+		repair.Reason = fmt.Sprintf("EntityType should be %s", repair.EntityType)
+	}
+	return repair, nil
+}
+
+func stringAttr(item map[string]types.AttributeValue, name string) string {
+	if v, ok := item[name].(*types.AttributeValueMemberS); ok {
+		return v.Value
+	}
+	return ""
+}
@@ -0,0 +1,66 @@
+package dynamodb
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func metadataItem(pk, sk, entityType, id string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"PK":         &types.AttributeValueMemberS{Value: pk},
+		"SK":         &types.AttributeValueMemberS{Value: sk},
+		"EntityType": &types.AttributeValueMemberS{Value: entityType},
+		"EntityID":   &types.AttributeValueMemberS{Value: id},
+	}
+}
+
+func TestPlanRepair(t *testing.T) {
+	evidence := newLegacyEvidence()
+	evidence.add("USER#u1", "ROLE#admin")
+	evidence.add("ROLE#admin", "PERMISSION#document:read")
+	evidence.add("ROLE#admin", "PERMISSION#both")
+	evidence.add("GROUP#g1", "ROLE#both")
+
+	tests := []struct {
+		name           string
+		item           map[string]types.AttributeValue
+		wantRepair     bool
+		wantPK         string
+		wantEntityType string
+		wantErr        error
+	}{
+		{"canonical user", metadataItem("USER#u1", "METADATA#u1", EntityTypeUser, "u1"), false, "USER#u1", EntityTypeUser, nil},
+		{"canonical role", metadataItem("ROLE#r1", "METADATA#r1", EntityTypeRole, "r1"), false, "ROLE#r1", EntityTypeRole, nil},
+		{"role tagged as user", metadataItem("ROLE#role-editor", "METADATA#role-editor", EntityTypeUser, "role-editor"), true, "ROLE#role-editor", EntityTypeRole, nil},
+		{"assigned role tagged as user", metadataItem("ROLE#admin", "METADATA#admin", EntityTypeUser, "admin"), true, "ROLE#admin", EntityTypeRole, nil},
+		{"granted permission under role key", metadataItem("ROLE#document:read", "METADATA#document:read", EntityTypeUser, "document:read"), true, "PERMISSION#document:read", EntityTypePermission, nil},
+		{"permission tagged as permission under role key", metadataItem("ROLE#p2", "METADATA#p2", EntityTypePermission, "p2"), true, "PERMISSION#p2", EntityTypePermission, nil},
+		{"permission tagged as user", metadataItem("PERMISSION#p1", "METADATA#p1", EntityTypeUser, "p1"), true, "PERMISSION#p1", EntityTypePermission, nil},
+		// Written by the baseline permissionToItem, before grants were stored.
+		{"ungranted permission under role key", metadataItem("ROLE#document:create", "METADATA#document:create", EntityTypeUser, "document:create"), true, "PERMISSION#document:create", EntityTypePermission, nil},
+		{"conflicting evidence", metadataItem("ROLE#both", "METADATA#both", EntityTypeUser, "both"), false, "", "", errUnclassifiable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repair, err := planRepair(tt.item, evidence)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v; got %v", tt.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if got := repair.Reason != ""; got != tt.wantRepair {
+				t.Fatalf("expected repair=%v; got %v (%s)", tt.wantRepair, got, repair.Reason)
+			}
+			if repair.PK != tt.wantPK {
+				t.Errorf("expected PK %s; got %s", tt.wantPK, repair.PK)
+			}
+			if repair.EntityType != tt.wantEntityType {
+				t.Errorf("expected EntityType %s; got %s", tt.wantEntityType, repair.EntityType)
+			}
+		})
+	}
+}
@@ -6,10 +6,13 @@ import (
 	"aws-dynamodb-store/internal/repository"
 	"context"
 	"fmt"
+	"log"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
 type roleItem struct {
@@ -19,30 +22,68 @@ type roleItem struct {
 	Description string        `dynamodbav:"Description,omitempty"`
 	CreatedAt   time.Time     `dynamodbav:"CreatedAt"`
 	UpdatedAt   time.Time     `dynamodbav:"UpdatedAt"`
+	Version     int64         `dynamodbav:"Version"`
 }
 
 type DynamoDBRoleRepository struct {
-	client *dynamodb.Client
+	client DynamoDBAPI
 	config config.DynamoDBConfig
 }
 
-func NewDynamoDBRoleRepository(client *dynamodb.Client, config config.DynamoDBConfig) repository.RoleRepository {
+// rolePermissionItem is the ROLE#roleID / PERMISSION#permissionID edge. Edges
+// written before deny grants existed have no Effect and allow.
+type rolePermissionItem struct {
+	baseItem
+	AssignedAt time.Time     `dynamodbav:"AssignedAt"`
+	Effect     domain.Effect `dynamodbav:"Effect,omitempty"`
+	Condition  string        `dynamodbav:"Condition,omitempty"`
+}
+
+func NewDynamoDBRoleRepository(client DynamoDBAPI, config config.DynamoDBConfig) repository.RoleRepository {
 	return &DynamoDBRoleRepository{client: client, config: config}
 }
 
-func roleToItem(role *domain.Role) *roleItem {
-	pk := RolePrefix + string(role.ID)
+func roleToItem(k tenantKeys, role *domain.Role) *roleItem {
+	key := k.metadata(RolePrefix, string(role.ID))
 	return &roleItem{
 		baseItem: baseItem{
-			PK:         pk,
-			SK:         MetadataPrefix + string(role.ID),
-			EntityType: EntityTypeUser,
+			PK:         key.PK,
+			SK:         key.SK,
+			EntityType: k.entityType(EntityTypeRole),
 		},
 		ID:          role.ID,
 		DisplayName: role.DisplayName,
 		Description: role.Description,
 		CreatedAt:   role.CreatedAt,
 		UpdatedAt:   role.UpdatedAt,
+		Version:     role.Version,
+	}
+}
+
+func grantToItem(k tenantKeys, grant *domain.PermissionGrant) *rolePermissionItem {
+	return &rolePermissionItem{
+		baseItem: baseItem{
+			PK:         k.key(RolePrefix, string(grant.RoleID)),
+			SK:         k.key(PermissionPrefix, string(grant.PermissionID)),
+			EntityType: EntityTypeRolePermissionAssignment,
+		},
+		AssignedAt: grant.AssignedAt,
+		Effect:     grant.Effect,
+		Condition:  string(grant.Condition),
+	}
+}
+
+func itemToGrant(item *rolePermissionItem) *domain.PermissionGrant {
+	effect := item.Effect
+	if effect == "" {
+		effect = domain.EffectAllow
+	}
+	return &domain.PermissionGrant{
+		RoleID:       domain.RoleID(keyID(item.PK, RolePrefix)),
+		PermissionID: domain.PermissionID(keyID(item.SK, PermissionPrefix)),
+		Effect:       effect,
+		Condition:    domain.Condition(item.Condition),
+		AssignedAt:   item.AssignedAt,
 	}
 }
 
@@ -53,21 +94,33 @@ func itemToRole(item *roleItem) *domain.Role {
 		Description: item.Description,
 		CreatedAt:   item.CreatedAt,
 		UpdatedAt:   item.UpdatedAt,
+		Version:     item.Version,
 	}
 }
 
 func (r *DynamoDBRoleRepository) CreateRole(ctx context.Context, role *domain.Role) error {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return err
+	}
+
 	role.CreatedAt = time.Now().UTC()
 	role.UpdatedAt = role.CreatedAt
-	item := roleToItem(role)
+	role.Version = 1
+	item := roleToItem(k, role)
 
 	return createItem(ctx, r.client, r.config.TableName, item)
 
 }
 
 func (r *DynamoDBRoleRepository) GetRoleByID(ctx context.Context, id domain.RoleID) (*domain.Role, error) {
-	pk := RolePrefix + string(id)
-	sk := MetadataPrefix + string(id)
+	k, err := keysFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pk := k.key(RolePrefix, string(id))
+	sk := k.key(MetadataPrefix, string(id))
 
 	out, err := getItemById(ctx, r.client, r.config.TableName, pk, sk)
 	if err != nil {
@@ -76,31 +129,385 @@ func (r *DynamoDBRoleRepository) GetRoleByID(ctx context.Context, id domain.Role
 
 	var roleItem roleItem
 	if err := attributevalue.UnmarshalMap(out.Item, &roleItem); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal user item: %w", err)
+		return nil, fmt.Errorf("failed to unmarshal role item: %w", err)
+	}
+	if roleItem.DeletedAt != nil {
+		return nil, repository.ErrNotFound
 	}
 	return itemToRole(&roleItem), nil
 }
 
-// TODO
-func (r *DynamoDBRoleRepository) ListAllRoles(ctx context.Context) ([]*domain.Role, error) {
-	return []*domain.Role{}, nil
+func (r *DynamoDBRoleRepository) UpdateRole(ctx context.Context, role *domain.Role) error {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return err
+	}
+
+	current, err := r.GetRoleByID(ctx, role.ID)
+	if err != nil {
+		return err
+	}
+	if current.Version != role.Version {
+		return repository.ErrConflict
+	}
+
+	changes := map[string]interface{}{}
+	setIfChanged(changes, "DisplayName", current.DisplayName, role.DisplayName)
+	setIfChanged(changes, "Description", current.Description, role.Description)
+	if len(changes) == 0 {
+		*role = *current
+		return nil
+	}
+
+	var updated roleItem
+	if err := updateItem(ctx, r.client, r.config.TableName, k.metadata(RolePrefix, string(role.ID)), role.Version, changes, &updated); err != nil {
+		return err
+	}
+	*role = *itemToRole(&updated)
+	return nil
+}
+
+// DeleteRole removes the role together with its permission assignments and its assignments to users.
+func (r *DynamoDBRoleRepository) DeleteRole(ctx context.Context, id domain.RoleID) error {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return err
+	}
+
+	return deleteEntity(ctx, r.client, r.config.TableName, k, RolePrefix, string(id))
+}
+
+// SoftDeleteRole hides the role until it is restored or its retention window
+// passes.
+func (r *DynamoDBRoleRepository) SoftDeleteRole(ctx context.Context, id domain.RoleID) error {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return err
+	}
+
+	return softDeleteEntity(ctx, r.client, r.config.TableName, k, RolePrefix, string(id), retentionWindow(r.config))
+}
+
+func (r *DynamoDBRoleRepository) RestoreRole(ctx context.Context, id domain.RoleID) error {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return err
+	}
+
+	return restoreEntity(ctx, r.client, r.config.TableName, k, RolePrefix, string(id))
+}
+
+func (r *DynamoDBRoleRepository) ListAllRoles(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.Role], error) {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	queryInput := &dynamodb.QueryInput{
+		TableName:              aws.String(r.config.TableName),
+		IndexName:              aws.String(r.config.EntityTypeIndex),
+		KeyConditionExpression: aws.String("EntityType = :entityTypeVal"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":entityTypeVal": &types.AttributeValueMemberS{Value: k.entityType(EntityTypeRole)},
+		},
+	}
+
+	items, nextCursor, err := queryPage(ctx, r.client, queryInput, page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query roles: %w", err)
+	}
+
+	keys := make([]itemKey, 0, len(items))
+	for _, item := range items {
+		var partialRole roleItem
+		if err := attributevalue.UnmarshalMap(item, &partialRole); err != nil {
+			log.Print(err.Error())
+			continue
+		}
+		keys = append(keys, k.metadata(RolePrefix, string(partialRole.ID)))
+	}
+
+	roles, err := hydrate(ctx, r.client, r.config.TableName, keys, itemToRole)
+	if err != nil {
+		return nil, err
+	}
+	return &repository.Page[*domain.Role]{Items: roles, NextCursor: nextCursor}, nil
+}
+
+func (r *DynamoDBRoleRepository) AssignPermissionToRole(ctx context.Context, grant *domain.PermissionGrant) error {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return err
+	}
+
+	grant.AssignedAt = time.Now().UTC()
+	item, err := attributevalue.MarshalMap(grantToItem(k, grant))
+	if err != nil {
+		return fmt.Errorf("failed to marshal permission grant: %w", err)
+	}
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.config.TableName),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to assign permission to role: %w", err)
+	}
+	return nil
+}
+
+func (r *DynamoDBRoleRepository) RemovePermissionFromRole(ctx context.Context, roleID domain.RoleID, permissionID domain.PermissionID) error {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(r.config.TableName),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: k.key(RolePrefix, string(roleID))},
+			"SK": &types.AttributeValueMemberS{Value: k.key(PermissionPrefix, string(permissionID))},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove permission from role: %w", err)
+	}
+	return nil
+}
+
+// GetRolePermissions returns the permissions the role allows, regardless of
+// their conditions. Denied permissions are only returned by ListRoleGrants.
+func (r *DynamoDBRoleRepository) GetRolePermissions(ctx context.Context, roleID domain.RoleID, page repository.PageRequest) (*repository.Page[*domain.Permission], error) {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	queryInput := &dynamodb.QueryInput{
+		TableName:              aws.String(r.config.TableName),
+		KeyConditionExpression: aws.String("PK = :pkVal AND begins_with(SK, :skPrefix)"),
+		FilterExpression:       aws.String("attribute_not_exists(Effect) OR Effect <> :deny"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pkVal":    &types.AttributeValueMemberS{Value: k.key(RolePrefix, string(roleID))},
+			":skPrefix": &types.AttributeValueMemberS{Value: k.key(PermissionPrefix, "")},
+			":deny":     &types.AttributeValueMemberS{Value: string(domain.EffectDeny)},
+		},
+	}
+
+	items, nextCursor, err := queryPage(ctx, r.client, queryInput, page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query role permissions: %w", err)
+	}
+
+	keys := make([]itemKey, 0, len(items))
+	for _, item := range items {
+		var base baseItem
+		if err := attributevalue.UnmarshalMap(item, &base); err != nil {
+			continue
+		}
+		// SK should be like PERMISSION#permission-id
+		keys = append(keys, k.metadata(PermissionPrefix, keyID(base.SK, PermissionPrefix)))
+	}
+
+	permissions, err := hydrate(ctx, r.client, r.config.TableName, keys, itemToPermission)
+	if err != nil {
+		return nil, err
+	}
+	return &repository.Page[*domain.Permission]{Items: permissions, NextCursor: nextCursor}, nil
+}
+
+// ListRoleGrants returns the allow and deny grants of the role. Grants of
+// permissions that no longer exist or are soft-deleted are skipped.
+func (r *DynamoDBRoleRepository) ListRoleGrants(ctx context.Context, roleID domain.RoleID, page repository.PageRequest) (*repository.Page[*domain.PermissionGrant], error) {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	queryInput := prefixQuery(r.config.TableName, k.key(RolePrefix, string(roleID)), k.key(PermissionPrefix, ""))
+	items, nextCursor, err := queryPage(ctx, r.client, queryInput, page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query role grants: %w", err)
+	}
+
+	edges := make([]*rolePermissionItem, 0, len(items))
+	keys := make([]itemKey, 0, len(items))
+	for _, item := range items {
+		var edge rolePermissionItem
+		if err := attributevalue.UnmarshalMap(item, &edge); err != nil {
+			log.Print(err.Error())
+			continue
+		}
+		edges = append(edges, &edge)
+		keys = append(keys, k.metadata(PermissionPrefix, keyID(edge.SK, PermissionPrefix)))
+	}
+
+	permissions, err := batchGetItems(ctx, r.client, r.config.TableName, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	grants := make([]*domain.PermissionGrant, 0, len(edges))
+	for i, edge := range edges {
+		if permission, ok := permissions[keys[i]]; !ok || isDeleted(permission) {
+			continue
+		}
+		grants = append(grants, itemToGrant(edge))
+	}
+	return &repository.Page[*domain.PermissionGrant]{Items: grants, NextCursor: nextCursor}, nil
+}
+
+func (r *DynamoDBRoleRepository) ListRolesWithPermission(ctx context.Context, permissionID domain.PermissionID, page repository.PageRequest) (*repository.Page[*domain.Role], error) {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	queryInput := &dynamodb.QueryInput{
+		TableName:              aws.String(r.config.TableName),
+		IndexName:              aws.String(GSI1Name), // GSI1PK = SK, GSI1SK = PK
+		KeyConditionExpression: aws.String("SK = :skVal AND begins_with(PK, :pkPrefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":skVal":    &types.AttributeValueMemberS{Value: k.key(PermissionPrefix, string(permissionID))},
+			":pkPrefix": &types.AttributeValueMemberS{Value: k.key(RolePrefix, "")},
+		},
+	}
+
+	items, nextCursor, err := queryPage(ctx, r.client, queryInput, page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query roles with permission using GSI1: %w", err)
+	}
+
+	keys := make([]itemKey, 0, len(items))
+	for _, itemMap := range items {
+		var base baseItem
+		if err := attributevalue.UnmarshalMap(itemMap, &base); err != nil {
+			continue
+		}
+		// PK should be ROLE#roleID
+		keys = append(keys, k.metadata(RolePrefix, keyID(base.PK, RolePrefix)))
+	}
+
+	roles, err := hydrate(ctx, r.client, r.config.TableName, keys, itemToRole)
+	if err != nil {
+		return nil, err
+	}
+	return &repository.Page[*domain.Role]{Items: roles, NextCursor: nextCursor}, nil
 }
 
-// TODO
-func (r *DynamoDBRoleRepository) AssignPermissionToRole(ctx context.Context, roleID domain.RoleID, permissionID domain.PermissionID) error {
+func (r *DynamoDBRoleRepository) AddParentRole(ctx context.Context, roleID domain.RoleID, parentID domain.RoleID) error {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return err
+	}
+
+	item := map[string]types.AttributeValue{
+		"PK":         &types.AttributeValueMemberS{Value: k.key(RolePrefix, string(roleID))},
+		"SK":         &types.AttributeValueMemberS{Value: k.key(ParentPrefix, string(parentID))},
+		"EntityType": &types.AttributeValueMemberS{Value: EntityTypeRoleInheritance},
+		"AssignedAt": &types.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339)},
+	}
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.config.TableName),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add parent role: %w", err)
+	}
+	return nil
+}
+
+func (r *DynamoDBRoleRepository) RemoveParentRole(ctx context.Context, roleID domain.RoleID, parentID domain.RoleID) error {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(r.config.TableName),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: k.key(RolePrefix, string(roleID))},
+			"SK": &types.AttributeValueMemberS{Value: k.key(ParentPrefix, string(parentID))},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove parent role: %w", err)
+	}
 	return nil
 }
 
-// TODO
-func (r *DynamoDBRoleRepository) GetRolePermissions(ctx context.Context, roleID domain.RoleID) ([]*domain.Permission, error) {
-	return []*domain.Permission{}, nil
+// GetParentRoles returns the roles the role directly inherits from.
+func (r *DynamoDBRoleRepository) GetParentRoles(ctx context.Context, roleID domain.RoleID, page repository.PageRequest) (*repository.Page[*domain.Role], error) {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	queryInput := &dynamodb.QueryInput{
+		TableName:              aws.String(r.config.TableName),
+		KeyConditionExpression: aws.String("PK = :pkVal AND begins_with(SK, :skPrefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pkVal":    &types.AttributeValueMemberS{Value: k.key(RolePrefix, string(roleID))},
+			":skPrefix": &types.AttributeValueMemberS{Value: k.key(ParentPrefix, "")},
+		},
+	}
+
+	items, nextCursor, err := queryPage(ctx, r.client, queryInput, page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query parent roles: %w", err)
+	}
+
+	keys := make([]itemKey, 0, len(items))
+	for _, item := range items {
+		var base baseItem
+		if err := attributevalue.UnmarshalMap(item, &base); err != nil {
+			continue
+		}
+		// SK should be like PARENT#role-id
+		keys = append(keys, k.metadata(RolePrefix, keyID(base.SK, ParentPrefix)))
+	}
+
+	roles, err := hydrate(ctx, r.client, r.config.TableName, keys, itemToRole)
+	if err != nil {
+		return nil, err
+	}
+	return &repository.Page[*domain.Role]{Items: roles, NextCursor: nextCursor}, nil
 }
 
-// --- DynamoDBRoleRepository ---
-// (Similar structure to DynamoDBUserRepository)
-// - CreateRole, GetRoleByID, UpdateRole, DeleteRole
-// - AssignPermissionToRole (PK: ROLE#roleID, SK: PERMISSION#permID)
-// - RemovePermissionFromRole
-// - GetRolePermissions (Query PK=ROLE#roleID, SK begins_with PERMISSION#)
-// - ListRolesWithPermission (Query GSI1 GSI1PK=PERMISSION#permID, GSI1SK begins_with ROLE#)
-// - ListAllRoles (Query for EntityType=ROLE on GSI or do a scan if few roles and infrequent)
+// GetChildRoles returns the roles that directly inherit from the role.
+func (r *DynamoDBRoleRepository) GetChildRoles(ctx context.Context, roleID domain.RoleID, page repository.PageRequest) (*repository.Page[*domain.Role], error) {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	queryInput := &dynamodb.QueryInput{
+		TableName:              aws.String(r.config.TableName),
+		IndexName:              aws.String(GSI1Name), // GSI1PK = SK, GSI1SK = PK
+		KeyConditionExpression: aws.String("SK = :skVal AND begins_with(PK, :pkPrefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":skVal":    &types.AttributeValueMemberS{Value: k.key(ParentPrefix, string(roleID))},
+			":pkPrefix": &types.AttributeValueMemberS{Value: k.key(RolePrefix, "")},
+		},
+	}
+
+	items, nextCursor, err := queryPage(ctx, r.client, queryInput, page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query child roles using GSI1: %w", err)
+	}
+
+	keys := make([]itemKey, 0, len(items))
+	for _, itemMap := range items {
+		var base baseItem
+		if err := attributevalue.UnmarshalMap(itemMap, &base); err != nil {
+			continue
+		}
+		// PK should be ROLE#roleID
+		keys = append(keys, k.metadata(RolePrefix, keyID(base.PK, RolePrefix)))
+	}
+
+	roles, err := hydrate(ctx, r.client, r.config.TableName, keys, itemToRole)
+	if err != nil {
+		return nil, err
+	}
+	return &repository.Page[*domain.Role]{Items: roles, NextCursor: nextCursor}, nil
+}
@@ -0,0 +1,97 @@
+package dynamodb
+
+import (
+	"aws-dynamodb-store/internal/config"
+	"aws-dynamodb-store/internal/domain"
+	"aws-dynamodb-store/internal/repository"
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestGrantItemRoundTrip(t *testing.T) {
+	k := tenantKeys("TENANT#t1#")
+
+	grant := domain.PermissionGrant{RoleID: "role-support", PermissionID: "account:finance:read", Effect: domain.EffectDeny}
+	item := grantToItem(k, &grant)
+	if item.PK != "TENANT#t1#ROLE#role-support" || item.SK != "TENANT#t1#PERMISSION#account:finance:read" {
+		t.Fatalf("unexpected key %s / %s", item.PK, item.SK)
+	}
+	if got := itemToGrant(item); *got != grant {
+		t.Errorf("expected %+v; got %+v", grant, *got)
+	}
+
+	// Edges written before deny grants existed have no Effect.
+	item.Effect = ""
+	if got := itemToGrant(item); got.Effect != domain.EffectAllow {
+		t.Errorf("expected legacy edge to allow; got %q", got.Effect)
+	}
+}
+
+func TestGetRolePermissionsFillsPagesPastDenyGrants(t *testing.T) {
+	client := newFakeDynamoDB()
+	cfg := config.DynamoDBConfig{TableName: "rbac", EntityTypeIndex: fakeEntityTypeIndex}
+	roles := NewDynamoDBRoleRepository(client, cfg)
+	permissions := NewDynamoDBPermissionRepository(client, cfg)
+	ctx := repository.WithTenant(context.Background(), "t1")
+
+	if err := roles.CreateRole(ctx, &domain.Role{ID: "role-support", DisplayName: "Support"}); err != nil {
+		t.Fatal(err)
+	}
+	// The deny grants sort before and between the allow grants, so every
+	// Query with the page's limit returns fewer items than asked for.
+	grants := []struct {
+		id     domain.PermissionID
+		effect domain.Effect
+	}{
+		{"a:deny", domain.EffectDeny},
+		{"b:deny", domain.EffectDeny},
+		{"c:read", domain.EffectAllow},
+		{"d:deny", domain.EffectDeny},
+		{"e:read", domain.EffectAllow},
+		{"f:read", domain.EffectAllow},
+		{"g:deny", domain.EffectDeny},
+	}
+	for _, g := range grants {
+		if err := permissions.CreatePermission(ctx, &domain.Permission{ID: g.id, DisplayName: string(g.id)}); err != nil {
+			t.Fatal(err)
+		}
+		if err := roles.AssignPermissionToRole(ctx, &domain.PermissionGrant{RoleID: "role-support", PermissionID: g.id, Effect: g.effect}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		limit int
+		want  [][]domain.PermissionID
+	}{
+		{limit: 1, want: [][]domain.PermissionID{{"c:read"}, {"e:read"}, {"f:read"}, {}}},
+		{limit: 2, want: [][]domain.PermissionID{{"c:read", "e:read"}, {"f:read"}}},
+		{limit: 10, want: [][]domain.PermissionID{{"c:read", "e:read", "f:read"}}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("limit %d", tt.limit), func(t *testing.T) {
+			var got [][]domain.PermissionID
+			page := repository.PageRequest{Limit: tt.limit}
+			for {
+				result, err := roles.GetRolePermissions(ctx, "role-support", page)
+				if err != nil {
+					t.Fatal(err)
+				}
+				ids := []domain.PermissionID{}
+				for _, p := range result.Items {
+					ids = append(ids, p.ID)
+				}
+				got = append(got, ids)
+				if result.NextCursor == "" {
+					break
+				}
+				page.Cursor = result.NextCursor
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected pages %v; got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,344 @@
+package dynamodb
+
+import (
+	"aws-dynamodb-store/internal/config"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// How often EnsureSchema polls while a table or index is being created.
+const schemaPollInterval = 2 * time.Second
+
+// schemaAPI is the part of the DynamoDB API EnsureSchema and VerifySchema
+// use. *dynamodb.Client implements it.
+type schemaAPI interface {
+	DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
+	CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error)
+	UpdateTable(ctx context.Context, params *dynamodb.UpdateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTableOutput, error)
+	DescribeTimeToLive(ctx context.Context, params *dynamodb.DescribeTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTimeToLiveOutput, error)
+	UpdateTimeToLive(ctx context.Context, params *dynamodb.UpdateTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTimeToLiveOutput, error)
+}
+
+// SchemaReport lists what EnsureSchema changed.
+type SchemaReport struct {
+	TableName      string
+	CreatedTable   bool
+	CreatedIndexes []string
+	EnabledTTL     bool
+}
+
+func (r *SchemaReport) String() string {
+	var changes []string
+	if r.CreatedTable {
+		changes = append(changes, "created table")
+	}
+	for _, index := range r.CreatedIndexes {
+		changes = append(changes, "created index "+index)
+	}
+	if r.EnabledTTL {
+		changes = append(changes, "enabled TTL on "+TTLAttribute)
+	}
+	if len(changes) == 0 {
+		return fmt.Sprintf("table %s is up to date", r.TableName)
+	}
+	return fmt.Sprintf("table %s: %s", r.TableName, strings.Join(changes, ", "))
+}
+
+// TableDefinition returns the table the repository expects: PK/SK string keys,
+// EntityTypeIndex (EntityType/EntityID, keys only) for listing entities, and
+// GSI1 (SK/PK, all attributes) for following edges backwards.
+func TableDefinition(cfg config.DynamoDBConfig) *dynamodb.CreateTableInput {
+	return &dynamodb.CreateTableInput{
+		TableName: aws.String(cfg.TableName),
+		AttributeDefinitions: []types.AttributeDefinition{
+			stringAttribute("PK"), stringAttribute("SK"), stringAttribute("EntityType"), stringAttribute("EntityID"),
+		},
+		KeySchema:   keySchema("PK", "SK"),
+		BillingMode: types.BillingModePayPerRequest,
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{
+			{
+				IndexName:  aws.String(cfg.EntityTypeIndex),
+				KeySchema:  keySchema("EntityType", "EntityID"),
+				Projection: &types.Projection{ProjectionType: types.ProjectionTypeKeysOnly},
+			},
+			{
+				IndexName:  aws.String(GSI1Name),
+				KeySchema:  keySchema("SK", "PK"),
+				Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+			},
+		},
+	}
+}
+
+// EnsureSchema creates the table of cfg, its global secondary indexes and its
+// TTL setting where they are missing, and waits until they are active. Existing
+// tables and indexes are left as they are, whatever their definition; see
+// VerifySchema.
+func EnsureSchema(ctx context.Context, client schemaAPI, cfg config.DynamoDBConfig) (*SchemaReport, error) {
+	report := &SchemaReport{TableName: cfg.TableName}
+	definition := TableDefinition(cfg)
+
+	table, err := describeTable(ctx, client, cfg.TableName)
+	var notFound *types.ResourceNotFoundException
+	switch {
+	case errors.As(err, &notFound):
+		if _, err := client.CreateTable(ctx, definition); err != nil {
+			return report, fmt.Errorf("failed to create table %s: %w", cfg.TableName, err)
+		}
+		report.CreatedTable = true
+		if table, err = waitForTable(ctx, client, cfg.TableName); err != nil {
+			return report, err
+		}
+	case err != nil:
+		return report, err
+	}
+
+	// A single update may only create one index.
+	for _, index := range missingIndexes(definition, table) {
+		if _, err := client.UpdateTable(ctx, &dynamodb.UpdateTableInput{
+			TableName:            aws.String(cfg.TableName),
+			AttributeDefinitions: definition.AttributeDefinitions,
+			GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{
+				{Create: indexCreation(index, table)},
+			},
+		}); err != nil {
+			return report, fmt.Errorf("failed to create index %s: %w", aws.ToString(index.IndexName), err)
+		}
+		report.CreatedIndexes = append(report.CreatedIndexes, aws.ToString(index.IndexName))
+		if table, err = waitForTable(ctx, client, cfg.TableName); err != nil {
+			return report, err
+		}
+	}
+
+	enabled, err := ensureTTL(ctx, client, cfg.TableName)
+	report.EnabledTTL = enabled
+	return report, err
+}
+
+// SchemaMismatchError reports how the table differs from TableDefinition.
+type SchemaMismatchError struct {
+	TableName string
+	Problems  []string
+}
+
+func (e *SchemaMismatchError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "table %s does not match the schema the repository expects:", e.TableName)
+	for _, problem := range e.Problems {
+		b.WriteString("\n  - " + problem)
+	}
+	b.WriteString("\nRun `make schema` to create a missing table or index; keys, attribute types and projections of existing ones cannot be changed in place, so the index or table has to be recreated.")
+	return b.String()
+}
+
+// VerifySchema checks that the table of cfg has the keys, attribute types and
+// indexes of TableDefinition, returning a *SchemaMismatchError listing every
+// difference. Queries on a missing or mismatched index would otherwise only
+// fail once they are first run.
+func VerifySchema(ctx context.Context, client schemaAPI, cfg config.DynamoDBConfig) error {
+	table, err := describeTable(ctx, client, cfg.TableName)
+	var notFound *types.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return &SchemaMismatchError{TableName: cfg.TableName, Problems: []string{"the table does not exist"}}
+	}
+	if err != nil {
+		return err
+	}
+	if problems := schemaProblems(TableDefinition(cfg), table); len(problems) > 0 {
+		return &SchemaMismatchError{TableName: cfg.TableName, Problems: problems}
+	}
+	return nil
+}
+
+// schemaProblems describes every difference between table and definition
+// that breaks the repository. Projections that include more attributes than
+// needed are fine.
+func schemaProblems(definition *dynamodb.CreateTableInput, table *types.TableDescription) []string {
+	var problems []string
+
+	if got, want := formatKeySchema(table.KeySchema), formatKeySchema(definition.KeySchema); got != want {
+		problems = append(problems, fmt.Sprintf("the table key is %s; expected %s", got, want))
+	}
+
+	attributeTypes := make(map[string]types.ScalarAttributeType, len(table.AttributeDefinitions))
+	for _, attribute := range table.AttributeDefinitions {
+		attributeTypes[aws.ToString(attribute.AttributeName)] = attribute.AttributeType
+	}
+	for _, attribute := range definition.AttributeDefinitions {
+		name := aws.ToString(attribute.AttributeName)
+		if got, ok := attributeTypes[name]; ok && got != attribute.AttributeType {
+			problems = append(problems, fmt.Sprintf("attribute %s has type %s; expected %s", name, got, attribute.AttributeType))
+		}
+	}
+
+	indexes := make(map[string]types.GlobalSecondaryIndexDescription, len(table.GlobalSecondaryIndexes))
+	for _, index := range table.GlobalSecondaryIndexes {
+		indexes[aws.ToString(index.IndexName)] = index
+	}
+	for _, want := range definition.GlobalSecondaryIndexes {
+		name := aws.ToString(want.IndexName)
+		got, ok := indexes[name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("index %s is missing", name))
+			continue
+		}
+		if gotKey, wantKey := formatKeySchema(got.KeySchema), formatKeySchema(want.KeySchema); gotKey != wantKey {
+			problems = append(problems, fmt.Sprintf("index %s has key %s; expected %s", name, gotKey, wantKey))
+		}
+		if !projectionCovers(got.Projection, want.Projection) {
+			problems = append(problems, fmt.Sprintf("index %s projects %s; expected %s", name, projectionType(got.Projection), projectionType(want.Projection)))
+		}
+	}
+	return problems
+}
+
+// formatKeySchema renders a key schema as e.g. "PK (HASH), SK (RANGE)".
+func formatKeySchema(elements []types.KeySchemaElement) string {
+	if len(elements) == 0 {
+		return "none"
+	}
+	parts := make([]string, 0, len(elements))
+	for _, element := range elements {
+		parts = append(parts, fmt.Sprintf("%s (%s)", aws.ToString(element.AttributeName), element.KeyType))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// projectionCovers reports whether got projects at least the attributes want
+// does. Every projection includes the keys.
+func projectionCovers(got *types.Projection, want *types.Projection) bool {
+	switch projectionType(want) {
+	case types.ProjectionTypeKeysOnly:
+		return true
+	case types.ProjectionTypeAll:
+		return projectionType(got) == types.ProjectionTypeAll
+	}
+	return projectionType(got) == projectionType(want)
+}
+
+func projectionType(projection *types.Projection) types.ProjectionType {
+	if projection == nil {
+		return types.ProjectionTypeKeysOnly
+	}
+	return projection.ProjectionType
+}
+
+// missingIndexes returns the indexes of definition the table lacks.
+func missingIndexes(definition *dynamodb.CreateTableInput, table *types.TableDescription) []types.GlobalSecondaryIndex {
+	existing := make(map[string]bool, len(table.GlobalSecondaryIndexes))
+	for _, index := range table.GlobalSecondaryIndexes {
+		existing[aws.ToString(index.IndexName)] = true
+	}
+	var missing []types.GlobalSecondaryIndex
+	for _, index := range definition.GlobalSecondaryIndexes {
+		if !existing[aws.ToString(index.IndexName)] {
+			missing = append(missing, index)
+		}
+	}
+	return missing
+}
+
+// indexCreation creates index on table. Indexes of provisioned tables need a
+// throughput of their own, which is taken from the table.
+func indexCreation(index types.GlobalSecondaryIndex, table *types.TableDescription) *types.CreateGlobalSecondaryIndexAction {
+	action := &types.CreateGlobalSecondaryIndexAction{
+		IndexName:  index.IndexName,
+		KeySchema:  index.KeySchema,
+		Projection: index.Projection,
+	}
+	payPerRequest := table.BillingModeSummary != nil && table.BillingModeSummary.BillingMode == types.BillingModePayPerRequest
+	if !payPerRequest && table.ProvisionedThroughput != nil {
+		action.ProvisionedThroughput = &types.ProvisionedThroughput{
+			ReadCapacityUnits:  table.ProvisionedThroughput.ReadCapacityUnits,
+			WriteCapacityUnits: table.ProvisionedThroughput.WriteCapacityUnits,
+		}
+	}
+	return action
+}
+
+// ensureTTL enables TTL on TTLAttribute unless it already is. TTL enabled on
+// another attribute is an error, as it cannot be moved without disabling it
+// for up to an hour.
+func ensureTTL(ctx context.Context, client schemaAPI, tableName string) (bool, error) {
+	out, err := client.DescribeTimeToLive(ctx, &dynamodb.DescribeTimeToLiveInput{TableName: aws.String(tableName)})
+	if err != nil {
+		return false, fmt.Errorf("failed to describe TTL of table %s: %w", tableName, err)
+	}
+	if ttl := out.TimeToLiveDescription; ttl != nil {
+		switch ttl.TimeToLiveStatus {
+		case types.TimeToLiveStatusEnabled, types.TimeToLiveStatusEnabling:
+			if name := aws.ToString(ttl.AttributeName); name != TTLAttribute {
+				return false, fmt.Errorf("TTL of table %s is enabled on %s instead of %s", tableName, name, TTLAttribute)
+			}
+			return false, nil
+		}
+	}
+
+	_, err = client.UpdateTimeToLive(ctx, &dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(tableName),
+		TimeToLiveSpecification: &types.TimeToLiveSpecification{
+			AttributeName: aws.String(TTLAttribute),
+			Enabled:       aws.Bool(true),
+		},
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to enable TTL on table %s: %w", tableName, err)
+	}
+	return true, nil
+}
+
+func describeTable(ctx context.Context, client schemaAPI, tableName string) (*types.TableDescription, error) {
+	out, err := client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(tableName)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe table %s: %w", tableName, err)
+	}
+	return out.Table, nil
+}
+
+// waitForTable polls the table until it and all of its indexes are active.
+func waitForTable(ctx context.Context, client schemaAPI, tableName string) (*types.TableDescription, error) {
+	for {
+		table, err := describeTable(ctx, client, tableName)
+		if err != nil {
+			return nil, err
+		}
+		if tableActive(table) {
+			return table, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("table %s did not become active: %w", tableName, ctx.Err())
+		case <-time.After(schemaPollInterval):
+		}
+	}
+}
+
+func tableActive(table *types.TableDescription) bool {
+	if table.TableStatus != types.TableStatusActive {
+		return false
+	}
+	for _, index := range table.GlobalSecondaryIndexes {
+		if index.IndexStatus != types.IndexStatusActive {
+			return false
+		}
+	}
+	return true
+}
+
+func stringAttribute(name string) types.AttributeDefinition {
+	return types.AttributeDefinition{AttributeName: aws.String(name), AttributeType: types.ScalarAttributeTypeS}
+}
+
+func keySchema(hash string, rangeKey string) []types.KeySchemaElement {
+	return []types.KeySchemaElement{
+		{AttributeName: aws.String(hash), KeyType: types.KeyTypeHash},
+		{AttributeName: aws.String(rangeKey), KeyType: types.KeyTypeRange},
+	}
+}
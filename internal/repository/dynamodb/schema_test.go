@@ -0,0 +1,252 @@
+package dynamodb
+
+import (
+	"aws-dynamodb-store/internal/config"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var schemaTestConfig = config.DynamoDBConfig{TableName: "rbac", EntityTypeIndex: fakeEntityTypeIndex}
+
+// newSchemaTable returns a fake whose table was created from TableDefinition
+// as changed by modify, or that has no table when modify is nil.
+func newSchemaTable(t *testing.T, modify func(*dynamodb.CreateTableInput)) *fakeDynamoDB {
+	t.Helper()
+	client := newFakeDynamoDB()
+	if modify == nil {
+		return client
+	}
+	definition := TableDefinition(schemaTestConfig)
+	modify(definition)
+	if _, err := client.CreateTable(context.Background(), definition); err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func withoutGSI1(definition *dynamodb.CreateTableInput) {
+	definition.GlobalSecondaryIndexes = definition.GlobalSecondaryIndexes[:1]
+}
+
+func enableTTL(t *testing.T, client *fakeDynamoDB, attribute string) {
+	t.Helper()
+	_, err := client.UpdateTimeToLive(context.Background(), &dynamodb.UpdateTimeToLiveInput{
+		TableName:               aws.String(schemaTestConfig.TableName),
+		TimeToLiveSpecification: &types.TimeToLiveSpecification{AttributeName: aws.String(attribute), Enabled: aws.Bool(true)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEnsureSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*dynamodb.CreateTableInput) // nil for no table
+		ttl     string                           // Attribute TTL is already enabled on
+		want    SchemaReport
+		wantErr bool
+	}{
+		{name: "missing table", want: SchemaReport{CreatedTable: true, EnabledTTL: true}},
+		{name: "missing index", modify: withoutGSI1, want: SchemaReport{CreatedIndexes: []string{GSI1Name}, EnabledTTL: true}},
+		{name: "missing TTL", modify: func(*dynamodb.CreateTableInput) {}, want: SchemaReport{EnabledTTL: true}},
+		{name: "up to date", modify: func(*dynamodb.CreateTableInput) {}, ttl: TTLAttribute},
+		{name: "TTL on another attribute", modify: func(*dynamodb.CreateTableInput) {}, ttl: "ExpiresAt", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newSchemaTable(t, tt.modify)
+			if tt.ttl != "" {
+				enableTTL(t, client, tt.ttl)
+			}
+
+			report, err := EnsureSchema(context.Background(), client, schemaTestConfig)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected an error; got %s", report)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.want.TableName = schemaTestConfig.TableName
+			if report.String() != tt.want.String() {
+				t.Errorf("expected %q; got %q", tt.want.String(), report)
+			}
+			if err := VerifySchema(context.Background(), client, schemaTestConfig); err != nil {
+				t.Errorf("expected the ensured table to verify; got %v", err)
+			}
+		})
+	}
+}
+
+func TestNewDynamoDBRepositoryVerifiesSchema(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*dynamodb.CreateTableInput) // nil for no table
+		want   []string                         // Problems of the *SchemaMismatchError
+	}{
+		{"matching", func(*dynamodb.CreateTableInput) {}, nil},
+		{"missing table", nil, []string{"the table does not exist"}},
+		{"missing index", withoutGSI1, []string{"index GSI1 is missing"}},
+		{
+			"mismatched key schema",
+			func(definition *dynamodb.CreateTableInput) { definition.KeySchema = keySchema("SK", "PK") },
+			[]string{"the table key is SK (HASH), PK (RANGE); expected PK (HASH), SK (RANGE)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newSchemaTable(t, tt.modify)
+
+			repo, err := NewDynamoDBRepository(context.Background(), client, schemaTestConfig)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if repo.User == nil || repo.Role == nil || repo.Permission == nil || repo.Group == nil {
+					t.Errorf("expected every repository; got %+v", repo)
+				}
+				return
+			}
+			var mismatch *SchemaMismatchError
+			if !errors.As(err, &mismatch) {
+				t.Fatalf("expected a *SchemaMismatchError; got %v", err)
+			}
+			if !slices.Equal(mismatch.Problems, tt.want) {
+				t.Errorf("expected %q; got %q", tt.want, mismatch.Problems)
+			}
+		})
+	}
+}
+
+func TestMissingIndexes(t *testing.T) {
+	definition := TableDefinition(config.DynamoDBConfig{TableName: "rbac", EntityTypeIndex: "EntityTypeIndex"})
+
+	// Tables created from the README before GSI1 existed.
+	table := &types.TableDescription{
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndexDescription{
+			{IndexName: aws.String("EntityTypeIndex"), IndexStatus: types.IndexStatusActive},
+		},
+	}
+	var names []string
+	for _, index := range missingIndexes(definition, table) {
+		names = append(names, aws.ToString(index.IndexName))
+	}
+	if !slices.Equal(names, []string{GSI1Name}) {
+		t.Errorf("expected only %s to be missing; got %v", GSI1Name, names)
+	}
+
+	if missing := missingIndexes(definition, &types.TableDescription{}); len(missing) != 2 {
+		t.Errorf("expected both indexes to be missing; got %d", len(missing))
+	}
+}
+
+func TestIndexCreationThroughput(t *testing.T) {
+	index := TableDefinition(config.DynamoDBConfig{TableName: "rbac"}).GlobalSecondaryIndexes[1]
+
+	provisioned := &types.TableDescription{
+		ProvisionedThroughput: &types.ProvisionedThroughputDescription{ReadCapacityUnits: aws.Int64(10), WriteCapacityUnits: aws.Int64(5)},
+	}
+	action := indexCreation(index, provisioned)
+	if action.ProvisionedThroughput == nil || aws.ToInt64(action.ProvisionedThroughput.ReadCapacityUnits) != 10 || aws.ToInt64(action.ProvisionedThroughput.WriteCapacityUnits) != 5 {
+		t.Errorf("expected the throughput of the table; got %+v", action.ProvisionedThroughput)
+	}
+
+	onDemand := &types.TableDescription{
+		BillingModeSummary:    &types.BillingModeSummary{BillingMode: types.BillingModePayPerRequest},
+		ProvisionedThroughput: &types.ProvisionedThroughputDescription{ReadCapacityUnits: aws.Int64(0), WriteCapacityUnits: aws.Int64(0)},
+	}
+	if action := indexCreation(index, onDemand); action.ProvisionedThroughput != nil {
+		t.Errorf("expected no throughput on an on-demand table; got %+v", action.ProvisionedThroughput)
+	}
+}
+
+func TestTableActive(t *testing.T) {
+	table := &types.TableDescription{
+		TableStatus: types.TableStatusActive,
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndexDescription{
+			{IndexName: aws.String(GSI1Name), IndexStatus: types.IndexStatusCreating},
+		},
+	}
+	if tableActive(table) {
+		t.Error("expected a table with an index being created not to be active")
+	}
+	table.GlobalSecondaryIndexes[0].IndexStatus = types.IndexStatusActive
+	if !tableActive(table) {
+		t.Error("expected the table to be active")
+	}
+}
+
+func TestSchemaProblems(t *testing.T) {
+	definition := TableDefinition(config.DynamoDBConfig{TableName: "rbac", EntityTypeIndex: "EntityTypeIndex"})
+	matching := func() *types.TableDescription {
+		return &types.TableDescription{
+			KeySchema:            definition.KeySchema,
+			AttributeDefinitions: definition.AttributeDefinitions,
+			GlobalSecondaryIndexes: []types.GlobalSecondaryIndexDescription{
+				{IndexName: aws.String("EntityTypeIndex"), KeySchema: keySchema("EntityType", "EntityID"), Projection: &types.Projection{ProjectionType: types.ProjectionTypeKeysOnly}},
+				{IndexName: aws.String(GSI1Name), KeySchema: keySchema("SK", "PK"), Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*types.TableDescription)
+		want   []string
+	}{
+		{"matching", func(*types.TableDescription) {}, nil},
+		{
+			"wider projection",
+			func(table *types.TableDescription) {
+				table.GlobalSecondaryIndexes[0].Projection.ProjectionType = types.ProjectionTypeAll
+			},
+			nil,
+		},
+		{
+			"missing index",
+			func(table *types.TableDescription) { table.GlobalSecondaryIndexes = table.GlobalSecondaryIndexes[:1] },
+			[]string{"index GSI1 is missing"},
+		},
+		{
+			"narrow projection",
+			func(table *types.TableDescription) {
+				table.GlobalSecondaryIndexes[1].Projection.ProjectionType = types.ProjectionTypeKeysOnly
+			},
+			[]string{"index GSI1 projects KEYS_ONLY; expected ALL"},
+		},
+		{
+			"index key",
+			func(table *types.TableDescription) {
+				table.GlobalSecondaryIndexes[1].KeySchema = keySchema("SK", "EntityID")
+			},
+			[]string{"index GSI1 has key SK (HASH), EntityID (RANGE); expected SK (HASH), PK (RANGE)"},
+		},
+		{
+			"table key and attribute type",
+			func(table *types.TableDescription) {
+				table.KeySchema = table.KeySchema[:1]
+				table.AttributeDefinitions = []types.AttributeDefinition{{AttributeName: aws.String("PK"), AttributeType: types.ScalarAttributeTypeN}}
+			},
+			[]string{"the table key is PK (HASH); expected PK (HASH), SK (RANGE)", "attribute PK has type N; expected S"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := matching()
+			tt.modify(table)
+			if got := schemaProblems(definition, table); !slices.Equal(got, tt.want) {
+				t.Errorf("expected %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package dynamodb
+
+import (
+	"aws-dynamodb-store/internal/config"
+	"aws-dynamodb-store/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// isDeleted reports whether a raw metadata item is soft-deleted.
+func isDeleted(item map[string]types.AttributeValue) bool {
+	_, ok := item["DeletedAt"]
+	return ok
+}
+
+func retentionWindow(cfg config.DynamoDBConfig) time.Duration {
+	return time.Duration(cfg.SoftDeleteRetentionDays) * 24 * time.Hour
+}
+
+// softDeleteEntity marks the metadata item of prefix+id in the tenant of k as
+// deleted and lets the table TTL reap it once the retention window has
+// passed. Its edges stay in place so the entity can be restored as it was,
+// but are given the same TTL, unless theirs is earlier, so the table TTL
+// reaps them along with it instead of leaving them behind.
+//
+// The metadata item is marked first. When stamping the edges is interrupted,
+// restoring the entity and deleting it again stamps the rest.
+func softDeleteEntity(ctx context.Context, client DynamoDBAPI, tableName string, k tenantKeys, prefix string, id string, retention time.Duration) error {
+	now := time.Now().UTC()
+	ttl := &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Add(retention).Unix(), 10)}
+	metadata := k.metadata(prefix, id)
+	_, err := client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(tableName),
+		Key:                 metadata.attributes(),
+		UpdateExpression:    aws.String("SET DeletedAt = :now, UpdatedAt = :now, #TTL = :ttl, #Version = if_not_exists(#Version, :zero) + :one"),
+		ConditionExpression: aws.String("attribute_exists(PK) AND attribute_not_exists(DeletedAt)"),
+		ExpressionAttributeNames: map[string]string{
+			"#TTL":     TTLAttribute,
+			"#Version": "Version",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now":  &types.AttributeValueMemberS{Value: now.Format(time.RFC3339Nano)},
+			":ttl":  ttl,
+			":zero": &types.AttributeValueMemberN{Value: "0"},
+			":one":  &types.AttributeValueMemberN{Value: "1"},
+		},
+	})
+	if err != nil {
+		var condCheckFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &condCheckFailed) {
+			return repository.ErrNotFound
+		}
+		return fmt.Errorf("failed to soft delete item: %w", err)
+	}
+
+	edges, err := entityEdges(ctx, client, tableName, k, prefix, id, "PK, SK")
+	if err != nil {
+		return err
+	}
+	for _, edge := range edges {
+		_, err := client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:                 aws.String(tableName),
+			Key:                       keyOf(edge).attributes(),
+			UpdateExpression:          aws.String("SET #TTL = :ttl"),
+			ConditionExpression:       aws.String("attribute_exists(PK) AND (attribute_not_exists(#TTL) OR #TTL > :ttl)"),
+			ExpressionAttributeNames:  map[string]string{"#TTL": TTLAttribute},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":ttl": ttl},
+		})
+		var condCheckFailed *types.ConditionalCheckFailedException
+		if err != nil && !errors.As(err, &condCheckFailed) {
+			return fmt.Errorf("failed to expire edge %s / %s: %w", keyOf(edge).PK, keyOf(edge).SK, err)
+		}
+	}
+	return nil
+}
+
+// restoreEntity clears the deletion marker of the metadata item of prefix+id
+// in the tenant of k, as long as its retention window has not passed yet. Its
+// edges get back the TTL they would have without the soft delete: the end of
+// their own assignment window, or of the retention window of their other end
+// when that is soft-deleted too.
+func restoreEntity(ctx context.Context, client DynamoDBAPI, tableName string, k tenantKeys, prefix string, id string) error {
+	now := time.Now().UTC()
+	metadata := k.metadata(prefix, id)
+	_, err := client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(tableName),
+		Key:                 metadata.attributes(),
+		UpdateExpression:    aws.String("SET UpdatedAt = :now, #Version = if_not_exists(#Version, :zero) + :one REMOVE DeletedAt, #TTL"),
+		ConditionExpression: aws.String("attribute_exists(DeletedAt) AND #TTL > :epoch"),
+		ExpressionAttributeNames: map[string]string{
+			"#TTL":     TTLAttribute,
+			"#Version": "Version",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now":   &types.AttributeValueMemberS{Value: now.Format(time.RFC3339Nano)},
+			":epoch": &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Unix(), 10)},
+			":zero":  &types.AttributeValueMemberN{Value: "0"},
+			":one":   &types.AttributeValueMemberN{Value: "1"},
+		},
+	})
+	if err != nil {
+		var condCheckFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &condCheckFailed) {
+			return repository.ErrNotFound
+		}
+		return fmt.Errorf("failed to restore item: %w", err)
+	}
+
+	edges, err := entityEdges(ctx, client, tableName, k, prefix, id, "PK, SK, ExpiresAt")
+	if err != nil {
+		return err
+	}
+	var endpoints []itemKey
+	for _, edge := range edges {
+		for _, endpoint := range edgeEndpoints(keyOf(edge)) {
+			if endpoint != metadata {
+				endpoints = append(endpoints, endpoint)
+			}
+		}
+	}
+	endpointItems, err := batchGetItems(ctx, client, tableName, endpoints)
+	if err != nil {
+		return err
+	}
+
+	for _, edge := range edges {
+		input := &dynamodb.UpdateItemInput{
+			TableName:                aws.String(tableName),
+			Key:                      keyOf(edge).attributes(),
+			UpdateExpression:         aws.String("REMOVE #TTL"),
+			ConditionExpression:      aws.String("attribute_exists(PK)"),
+			ExpressionAttributeNames: map[string]string{"#TTL": TTLAttribute},
+		}
+		if ttl := edgeExpiry(edge, metadata, endpointItems); ttl > 0 {
+			input.UpdateExpression = aws.String("SET #TTL = :ttl")
+			input.ExpressionAttributeValues = map[string]types.AttributeValue{
+				":ttl": &types.AttributeValueMemberN{Value: strconv.FormatInt(ttl, 10)},
+			}
+		}
+		_, err := client.UpdateItem(ctx, input)
+		var condCheckFailed *types.ConditionalCheckFailedException
+		if err != nil && !errors.As(err, &condCheckFailed) {
+			return fmt.Errorf("failed to restore edge %s / %s: %w", keyOf(edge).PK, keyOf(edge).SK, err)
+		}
+	}
+	return nil
+}
+
+// entityEdges returns the projected attributes of every item stored with the
+// entity prefix+id in the tenant of k, other than its metadata item.
+func entityEdges(ctx context.Context, client DynamoDBAPI, tableName string, k tenantKeys, prefix string, id string, projection string) ([]map[string]types.AttributeValue, error) {
+	queries, err := edgeQueries(ctx, client, tableName, k, prefix, id)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[itemKey]bool{k.metadata(prefix, id): true}
+	var edges []map[string]types.AttributeValue
+	for _, query := range queries {
+		items, err := queryItems(ctx, client, query, projection)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			if key := keyOf(item); !seen[key] {
+				seen[key] = true
+				edges = append(edges, item)
+			}
+		}
+	}
+	return edges, nil
+}
+
+// edgeEndpoints returns the metadata keys of the users, roles and permissions
+// an edge connects.
+func edgeEndpoints(edge itemKey) []itemKey {
+	var endpoints []itemKey
+	for _, key := range []string{edge.PK, edge.SK} {
+		k, rest := splitTenant(key)
+		switch {
+		case strings.HasPrefix(rest, UserPrefix):
+			// USER#u#RESOURCE#path partitions belong to the user.
+			id, _, _ := strings.Cut(rest[len(UserPrefix):], "#"+ResourcePrefix)
+			endpoints = append(endpoints, k.metadata(UserPrefix, id))
+		case strings.HasPrefix(rest, RolePrefix):
+			endpoints = append(endpoints, k.metadata(RolePrefix, rest[len(RolePrefix):]))
+		case strings.HasPrefix(rest, ParentPrefix):
+			endpoints = append(endpoints, k.metadata(RolePrefix, rest[len(ParentPrefix):]))
+		case strings.HasPrefix(rest, PermissionPrefix):
+			endpoints = append(endpoints, k.metadata(PermissionPrefix, rest[len(PermissionPrefix):]))
+		}
+	}
+	return endpoints
+}
+
+// edgeExpiry returns the epoch second the table TTL should reap edge at once
+// restored is restored, or 0 when nothing limits it.
+func edgeExpiry(edge map[string]types.AttributeValue, restored itemKey, endpoints map[itemKey]map[string]types.AttributeValue) int64 {
+	var ttl int64
+	earliest := func(at int64) {
+		if at > 0 && (ttl == 0 || at < ttl) {
+			ttl = at
+		}
+	}
+	if expiresAt, err := time.Parse(time.RFC3339Nano, stringAttr(edge, "ExpiresAt")); err == nil {
+		earliest(expiresAt.Unix())
+	}
+	for _, endpoint := range edgeEndpoints(keyOf(edge)) {
+		if item, ok := endpoints[endpoint]; ok && endpoint != restored && isDeleted(item) {
+			earliest(numberAttr(item, TTLAttribute))
+		}
+	}
+	return ttl
+}
+
+func keyOf(item map[string]types.AttributeValue) itemKey {
+	return itemKey{PK: stringAttr(item, "PK"), SK: stringAttr(item, "SK")}
+}
+
+func numberAttr(item map[string]types.AttributeValue, name string) int64 {
+	if v, ok := item[name].(*types.AttributeValueMemberN); ok {
+		n, _ := strconv.ParseInt(v.Value, 10, 64)
+		return n
+	}
+	return 0
+}
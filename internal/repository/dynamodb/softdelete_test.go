@@ -0,0 +1,193 @@
+package dynamodb
+
+import (
+	"aws-dynamodb-store/internal/domain"
+	"aws-dynamodb-store/internal/repository"
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	softDeleteRolePK  = "TENANT#t1#ROLE#role-support"
+	softDeleteRoleSK  = "TENANT#t1#METADATA#role-support"
+	softDeletePermPK  = "TENANT#t1#PERMISSION#ticket:read"
+	softDeletePermSK  = "TENANT#t1#METADATA#ticket:read"
+	softDeleteGrantSK = "TENANT#t1#PERMISSION#ticket:read"
+)
+
+// newSoftDeleteFixture stores a role granting one permission, assigned to u1
+// globally and on org/acme, and to u2 until expiresAt.
+func newSoftDeleteFixture(t *testing.T, expiresAt time.Time) (*fakeDynamoDB, repository.Repository, context.Context) {
+	t.Helper()
+	client := newFakeDynamoDB()
+	repo := newFakeRepository(client)
+	ctx := repository.WithTenant(context.Background(), "t1")
+
+	for _, err := range []error{
+		repo.User.CreateUser(ctx, &domain.User{ID: "u1", DisplayName: "Alice"}),
+		repo.User.CreateUser(ctx, &domain.User{ID: "u2", DisplayName: "Bob"}),
+		repo.Role.CreateRole(ctx, &domain.Role{ID: "role-support", DisplayName: "Support"}),
+		repo.Permission.CreatePermission(ctx, &domain.Permission{ID: "ticket:read", DisplayName: "Read tickets"}),
+		repo.Role.AssignPermissionToRole(ctx, &domain.PermissionGrant{RoleID: "role-support", PermissionID: "ticket:read", Effect: domain.EffectAllow}),
+		repo.User.AssignRoleToUser(ctx, &domain.RoleAssignment{UserID: "u1", RoleID: "role-support"}),
+		repo.User.AssignRoleToUser(ctx, &domain.RoleAssignment{UserID: "u1", RoleID: "role-support", ResourceID: "org/acme"}),
+		repo.User.AssignRoleToUser(ctx, &domain.RoleAssignment{UserID: "u2", RoleID: "role-support", ExpiresAt: &expiresAt}),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return client, repo, ctx
+}
+
+// setTTL replaces the TTL of the item at pk / sk.
+func setTTL(client *fakeDynamoDB, pk string, sk string, at time.Time) {
+	item := client.Get(pk, sk)
+	item[TTLAttribute] = &types.AttributeValueMemberN{Value: strconv.FormatInt(at.Unix(), 10)}
+	client.Put(item)
+}
+
+// assertTTL checks the TTL of the item at pk / sk to within a few seconds of
+// want, or that it has none when want is zero.
+func assertTTL(t *testing.T, client *fakeDynamoDB, pk string, sk string, want time.Time) {
+	t.Helper()
+	item := client.Get(pk, sk)
+	if item == nil {
+		t.Fatalf("expected %s / %s to exist", pk, sk)
+	}
+	got := numberAttr(item, TTLAttribute)
+	if want.IsZero() {
+		if got != 0 {
+			t.Errorf("%s / %s: expected no %s; got %d", pk, sk, TTLAttribute, got)
+		}
+		return
+	}
+	if got < want.Unix()-5 || got > want.Unix()+5 {
+		t.Errorf("%s / %s: expected %s around %d; got %d", pk, sk, TTLAttribute, want.Unix(), got)
+	}
+}
+
+func TestSoftDeleteExpiresTheEdges(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour)
+	client, repo, ctx := newSoftDeleteFixture(t, expiresAt)
+	if err := repo.Role.SoftDeleteRole(ctx, "role-support"); err != nil {
+		t.Fatal(err)
+	}
+	window := time.Now().Add(24 * time.Hour)
+
+	tests := []struct {
+		name string
+		pk   string
+		sk   string
+		want time.Time
+	}{
+		{"role", softDeleteRolePK, softDeleteRoleSK, window},
+		{"grant", softDeleteRolePK, softDeleteGrantSK, window},
+		{"assignment", "TENANT#t1#USER#u1", "TENANT#t1#ROLE#role-support", window},
+		{"resource assignment", "TENANT#t1#USER#u1#RESOURCE#org/acme", "TENANT#t1#ROLE#role-support", window},
+		{"assignment expiring earlier", "TENANT#t1#USER#u2", "TENANT#t1#ROLE#role-support", expiresAt},
+		{"user", "TENANT#t1#USER#u1", "TENANT#t1#METADATA#u1", time.Time{}},
+		{"resource marker", "TENANT#t1#USER#u1", "TENANT#t1#RESOURCE#org/acme", time.Time{}},
+		{"permission", softDeletePermPK, softDeletePermSK, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertTTL(t, client, tt.pk, tt.sk, tt.want)
+		})
+	}
+
+	if _, err := repo.Role.GetRoleByID(ctx, "role-support"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("expected the soft-deleted role to be hidden; got %v", err)
+	}
+	if err := repo.Role.SoftDeleteRole(ctx, "role-support"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("expected a second soft delete to fail with ErrNotFound; got %v", err)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	now := time.Now()
+	expiresAt := now.Add(time.Hour)
+	permissionWindow := now.Add(2 * time.Hour)
+
+	tests := []struct {
+		name             string
+		window           time.Time // End of the role's retention window
+		deletePermission bool      // Soft-delete the granted permission too
+		wantErr          error
+		wantGrantTTL     time.Time
+	}{
+		{name: "within the window", window: now.Add(time.Hour)},
+		{name: "window passed", window: now.Add(-time.Hour), wantErr: repository.ErrNotFound},
+		{name: "permission still deleted", window: now.Add(time.Hour), deletePermission: true, wantGrantTTL: permissionWindow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, repo, ctx := newSoftDeleteFixture(t, expiresAt)
+			if err := repo.Role.SoftDeleteRole(ctx, "role-support"); err != nil {
+				t.Fatal(err)
+			}
+			if tt.deletePermission {
+				if err := repo.Permission.SoftDeletePermission(ctx, "ticket:read"); err != nil {
+					t.Fatal(err)
+				}
+				setTTL(client, softDeletePermPK, softDeletePermSK, permissionWindow)
+			}
+			setTTL(client, softDeleteRolePK, softDeleteRoleSK, tt.window)
+
+			err := repo.Role.RestoreRole(ctx, "role-support")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v; got %v", tt.wantErr, err)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if _, err := repo.Role.GetRoleByID(ctx, "role-support"); err != nil {
+				t.Errorf("expected the restored role; got %v", err)
+			}
+			if _, ok := client.Get(softDeleteRolePK, softDeleteRoleSK)["DeletedAt"]; ok {
+				t.Errorf("expected DeletedAt to be removed on restore")
+			}
+			assertTTL(t, client, softDeleteRolePK, softDeleteRoleSK, time.Time{})
+			assertTTL(t, client, softDeleteRolePK, softDeleteGrantSK, tt.wantGrantTTL)
+			assertTTL(t, client, "TENANT#t1#USER#u1", "TENANT#t1#ROLE#role-support", time.Time{})
+			assertTTL(t, client, "TENANT#t1#USER#u1#RESOURCE#org/acme", "TENANT#t1#ROLE#role-support", time.Time{})
+			assertTTL(t, client, "TENANT#t1#USER#u2", "TENANT#t1#ROLE#role-support", expiresAt)
+		})
+	}
+}
+
+func TestEdgeEndpoints(t *testing.T) {
+	user := itemKey{PK: "TENANT#t1#USER#u1", SK: "TENANT#t1#METADATA#u1"}
+	role := itemKey{PK: "TENANT#t1#ROLE#r1", SK: "TENANT#t1#METADATA#r1"}
+	tests := []struct {
+		name string
+		edge itemKey
+		want []itemKey
+	}{
+		{"assignment", itemKey{PK: "TENANT#t1#USER#u1", SK: "TENANT#t1#ROLE#r1"}, []itemKey{user, role}},
+		{"resource assignment", itemKey{PK: "TENANT#t1#USER#u1#RESOURCE#org/acme", SK: "TENANT#t1#ROLE#r1"}, []itemKey{user, role}},
+		{"grant", itemKey{PK: "TENANT#t1#ROLE#r1", SK: "TENANT#t1#PERMISSION#doc:read"}, []itemKey{role, {PK: "TENANT#t1#PERMISSION#doc:read", SK: "TENANT#t1#METADATA#doc:read"}}},
+		{"parent", itemKey{PK: "TENANT#t1#ROLE#r1", SK: "TENANT#t1#PARENT#r2"}, []itemKey{role, {PK: "TENANT#t1#ROLE#r2", SK: "TENANT#t1#METADATA#r2"}}},
+		{"group role", itemKey{PK: "TENANT#t1#GROUP#g1", SK: "TENANT#t1#ROLE#r1"}, []itemKey{role}},
+		{"resource marker", itemKey{PK: "TENANT#t1#USER#u1", SK: "TENANT#t1#RESOURCE#org/acme"}, []itemKey{user}},
+		{"unscoped", itemKey{PK: "USER#u1", SK: "ROLE#r1"}, []itemKey{{PK: "USER#u1", SK: "METADATA#u1"}, {PK: "ROLE#r1", SK: "METADATA#r1"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := edgeEndpoints(tt.edge)
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %v; got %v", tt.want, got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("expected %v; got %v", tt.want, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package dynamodb
+
+import (
+	"aws-dynamodb-store/internal/domain"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Stream event names, as in DynamoDB Streams.
+const (
+	EventInsert = "INSERT"
+	EventModify = "MODIFY"
+	EventRemove = "REMOVE"
+)
+
+// StreamRecord is a change to a single item of the table, shaped like a
+// DynamoDB Streams record. Images are only present when the stream view type
+// includes them.
+type StreamRecord struct {
+	EventID   string
+	EventName string
+	Keys      map[string]types.AttributeValue
+	OldImage  map[string]types.AttributeValue
+	NewImage  map[string]types.AttributeValue
+}
+
+// Change names the derived data a StreamRecord invalidates. Empty fields are
+// unaffected.
+type Change struct {
+	Tenant       domain.TenantID
+	UserID       domain.UserID       // Effective permissions of the user
+	RoleID       domain.RoleID       // Effective permissions of the holders of the role
+	PermissionID domain.PermissionID // Effective permissions of the holders of the permission
+	GroupID      domain.GroupID      // Effective permissions of the members of the group
+	CountGroupID domain.GroupID      // Member count of the group
+}
+
+// DecodeStreamRecord reports the derived data the record invalidates. Items
+// that no derived data depends on, such as user and group metadata that the
+// recomputation itself writes, and legacy unscoped items return false.
+func DecodeStreamRecord(record StreamRecord) (Change, bool) {
+	k, pk := splitTenant(stringAttr(record.Keys, "PK"))
+	_, sk := splitTenant(stringAttr(record.Keys, "SK"))
+	if k == "" {
+		return Change{}, false
+	}
+	change := Change{Tenant: k.tenant()}
+
+	if strings.HasPrefix(sk, MetadataPrefix) {
+		// Creating an entity changes nothing, and neither does removing
+		// one: hard deletes cascade to the edges, and the table TTL reaps
+		// the edges of a soft-deleted entity along with it, each a change
+		// of its own. Only soft deletes and restores of roles and
+		// permissions matter.
+		if record.EventName != EventModify || !deletionChanged(record) {
+			return Change{}, false
+		}
+		switch {
+		case strings.HasPrefix(pk, RolePrefix):
+			change.RoleID = domain.RoleID(pk[len(RolePrefix):])
+		case strings.HasPrefix(pk, PermissionPrefix):
+			change.PermissionID = domain.PermissionID(pk[len(PermissionPrefix):])
+		default:
+			return Change{}, false
+		}
+		return change, true
+	}
+
+	switch {
+	case strings.HasPrefix(pk, UserPrefix) && strings.HasPrefix(sk, RolePrefix):
+		// Roles assigned on a single resource are not part of the effective permissions.
+		if strings.Contains(pk, "#"+ResourcePrefix) {
+			return Change{}, false
+		}
+		change.UserID = domain.UserID(pk[len(UserPrefix):])
+	case strings.HasPrefix(pk, UserPrefix) && strings.HasPrefix(sk, GroupPrefix):
+		change.UserID = domain.UserID(pk[len(UserPrefix):])
+		change.CountGroupID = domain.GroupID(sk[len(GroupPrefix):])
+	case strings.HasPrefix(pk, GroupPrefix) && strings.HasPrefix(sk, RolePrefix):
+		change.GroupID = domain.GroupID(pk[len(GroupPrefix):])
+	case strings.HasPrefix(pk, RolePrefix) && strings.HasPrefix(sk, PermissionPrefix):
+		change.RoleID = domain.RoleID(pk[len(RolePrefix):])
+	case strings.HasPrefix(pk, RolePrefix) && strings.HasPrefix(sk, ParentPrefix):
+		change.RoleID = domain.RoleID(pk[len(RolePrefix):])
+	default:
+		return Change{}, false
+	}
+	return change, true
+}
+
+// deletionChanged reports whether the record soft-deleted or restored the
+// item. Without both images it has to be assumed.
+func deletionChanged(record StreamRecord) bool {
+	if record.OldImage == nil || record.NewImage == nil {
+		return true
+	}
+	_, wasDeleted := record.OldImage["DeletedAt"]
+	_, isDeleted := record.NewImage["DeletedAt"]
+	return wasDeleted != isDeleted
+}
@@ -0,0 +1,102 @@
+package dynamodb
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func streamKeys(pk, sk string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: pk},
+		"SK": &types.AttributeValueMemberS{Value: sk},
+	}
+}
+
+func TestDecodeStreamRecord(t *testing.T) {
+	deleted := map[string]types.AttributeValue{"DeletedAt": &types.AttributeValueMemberS{Value: "2024-01-01T00:00:00Z"}}
+	live := map[string]types.AttributeValue{}
+
+	tests := []struct {
+		name   string
+		record StreamRecord
+		want   Change
+		wantOK bool
+	}{
+		{
+			name:   "user role",
+			record: StreamRecord{EventName: EventInsert, Keys: streamKeys("TENANT#t1#USER#u1", "TENANT#t1#ROLE#r1")},
+			want:   Change{Tenant: "t1", UserID: "u1"},
+			wantOK: true,
+		},
+		{
+			name:   "resource role",
+			record: StreamRecord{EventName: EventInsert, Keys: streamKeys("TENANT#t1#USER#u1#RESOURCE#doc", "TENANT#t1#ROLE#r1")},
+		},
+		{
+			name:   "group membership",
+			record: StreamRecord{EventName: EventRemove, Keys: streamKeys("TENANT#t1#USER#u1", "TENANT#t1#GROUP#g1")},
+			want:   Change{Tenant: "t1", UserID: "u1", CountGroupID: "g1"},
+			wantOK: true,
+		},
+		{
+			name:   "group role",
+			record: StreamRecord{EventName: EventInsert, Keys: streamKeys("TENANT#t1#GROUP#g1", "TENANT#t1#ROLE#r1")},
+			want:   Change{Tenant: "t1", GroupID: "g1"},
+			wantOK: true,
+		},
+		{
+			name:   "role grant",
+			record: StreamRecord{EventName: EventModify, Keys: streamKeys("TENANT#t1#ROLE#r1", "TENANT#t1#PERMISSION#p1")},
+			want:   Change{Tenant: "t1", RoleID: "r1"},
+			wantOK: true,
+		},
+		{
+			name:   "role parent",
+			record: StreamRecord{EventName: EventRemove, Keys: streamKeys("TENANT#t1#ROLE#r1", "TENANT#t1#PARENT#r0")},
+			want:   Change{Tenant: "t1", RoleID: "r1"},
+			wantOK: true,
+		},
+		{
+			name: "role soft delete",
+			record: StreamRecord{EventName: EventModify, Keys: streamKeys("TENANT#t1#ROLE#r1", "TENANT#t1#METADATA#r1"),
+				OldImage: live, NewImage: deleted},
+			want:   Change{Tenant: "t1", RoleID: "r1"},
+			wantOK: true,
+		},
+		{
+			name: "permission restore",
+			record: StreamRecord{EventName: EventModify, Keys: streamKeys("TENANT#t1#PERMISSION#p1", "TENANT#t1#METADATA#p1"),
+				OldImage: deleted, NewImage: live},
+			want:   Change{Tenant: "t1", PermissionID: "p1"},
+			wantOK: true,
+		},
+		{
+			name: "role update",
+			record: StreamRecord{EventName: EventModify, Keys: streamKeys("TENANT#t1#ROLE#r1", "TENANT#t1#METADATA#r1"),
+				OldImage: live, NewImage: live},
+		},
+		{
+			name: "user metadata",
+			record: StreamRecord{EventName: EventModify, Keys: streamKeys("TENANT#t1#USER#u1", "TENANT#t1#METADATA#u1"),
+				OldImage: live, NewImage: deleted},
+		},
+		{
+			name:   "role insert",
+			record: StreamRecord{EventName: EventInsert, Keys: streamKeys("TENANT#t1#ROLE#r1", "TENANT#t1#METADATA#r1")},
+		},
+		{
+			name:   "legacy item",
+			record: StreamRecord{EventName: EventInsert, Keys: streamKeys("USER#u1", "ROLE#r1")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := DecodeStreamRecord(tt.record)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("DecodeStreamRecord() = %+v, %v; want %+v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
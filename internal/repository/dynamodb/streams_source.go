@@ -0,0 +1,218 @@
+package dynamodb
+
+import (
+	"aws-dynamodb-store/internal/config"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams"
+	streamtypes "github.com/aws/aws-sdk-go-v2/service/dynamodbstreams/types"
+)
+
+// StreamsSource reads the records of the table's DynamoDB stream. No
+// checkpoints are kept: shards are read from their oldest record
+// (TRIM_HORIZON), or, with FromLatest, shards open at startup are read from
+// their newest. Since recomputing derived data is idempotent, rereading
+// records after a restart only costs time.
+type StreamsSource struct {
+	client    *dynamodbstreams.Client
+	streamARN string
+
+	FromLatest   bool
+	PollInterval time.Duration // Between polls of a shard without new records
+}
+
+// NewStreamsSource returns a source for the stream of the configured table,
+// which must have streams enabled.
+func NewStreamsSource(ctx context.Context, cfg config.DynamoDBConfig) (*StreamsSource, error) {
+	table, err := NewDynamoDBClient(cfg).DescribeTable(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(cfg.TableName),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe table: %w", err)
+	}
+	if table.Table.LatestStreamArn == nil {
+		return nil, fmt.Errorf("table %s has no stream enabled", cfg.TableName)
+	}
+
+	sdkConfig, err := awsConfig.LoadDefaultConfig(ctx, awsConfig.WithRegion(cfg.AWSRegion))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	client := dynamodbstreams.NewFromConfig(sdkConfig, func(o *dynamodbstreams.Options) {
+		if cfg.UseDynamoDBLocal {
+			o.BaseEndpoint = aws.String(cfg.DynamoDBLocalURL)
+		}
+	})
+
+	return &StreamsSource{
+		client:       client,
+		streamARN:    *table.Table.LatestStreamArn,
+		PollInterval: time.Second,
+	}, nil
+}
+
+// Run passes the records of every shard to handle, in order per shard, until
+// ctx is done. A batch handle fails on is retried after PollInterval.
+func (s *StreamsSource) Run(ctx context.Context, handle func(context.Context, []StreamRecord) error) error {
+	iterators := make(map[string]*string) // Open shards being read
+	closed := make(map[string]bool)
+	startType := streamtypes.ShardIteratorTypeTrimHorizon
+	if s.FromLatest {
+		startType = streamtypes.ShardIteratorTypeLatest
+	}
+
+	for {
+		shards, err := s.shards(ctx)
+		if err != nil {
+			return err
+		}
+		for _, shard := range shards {
+			id := aws.ToString(shard.ShardId)
+			if _, reading := iterators[id]; reading || closed[id] {
+				continue
+			}
+			// Children are read once their parent is done, to keep the order of
+			// changes to an item.
+			if parent := aws.ToString(shard.ParentShardId); parent != "" && iterators[parent] != nil {
+				continue
+			}
+			out, err := s.client.GetShardIterator(ctx, &dynamodbstreams.GetShardIteratorInput{
+				StreamArn:         aws.String(s.streamARN),
+				ShardId:           shard.ShardId,
+				ShardIteratorType: startType,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to get iterator of shard %s: %w", id, err)
+			}
+			iterators[id] = out.ShardIterator
+		}
+		// Shards appearing after startup are read from their start.
+		startType = streamtypes.ShardIteratorTypeTrimHorizon
+
+		idle := true
+		for id, iterator := range iterators {
+			out, err := s.client.GetRecords(ctx, &dynamodbstreams.GetRecordsInput{ShardIterator: iterator})
+			if err != nil {
+				var expired *streamtypes.ExpiredIteratorException
+				if errors.As(err, &expired) {
+					delete(iterators, id) // Requested again on the next pass
+					continue
+				}
+				return fmt.Errorf("failed to get records of shard %s: %w", id, err)
+			}
+
+			if len(out.Records) > 0 {
+				records := make([]StreamRecord, 0, len(out.Records))
+				for _, record := range out.Records {
+					records = append(records, fromStreamRecord(record))
+				}
+				if err := handle(ctx, records); err != nil {
+					// Reread on the next pass, after PollInterval.
+					log.Printf("Failed to handle %d records of shard %s, retrying: %v", len(records), id, err)
+					continue
+				}
+				idle = false
+			}
+
+			if out.NextShardIterator == nil {
+				delete(iterators, id)
+				closed[id] = true
+			} else {
+				iterators[id] = out.NextShardIterator
+			}
+		}
+
+		if idle {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(s.PollInterval):
+			}
+		} else if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+func (s *StreamsSource) shards(ctx context.Context) ([]streamtypes.Shard, error) {
+	var shards []streamtypes.Shard
+	var start *string
+	for {
+		out, err := s.client.DescribeStream(ctx, &dynamodbstreams.DescribeStreamInput{
+			StreamArn:             aws.String(s.streamARN),
+			ExclusiveStartShardId: start,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe stream: %w", err)
+		}
+		shards = append(shards, out.StreamDescription.Shards...)
+		start = out.StreamDescription.LastEvaluatedShardId
+		if start == nil {
+			return shards, nil
+		}
+	}
+}
+
+func fromStreamRecord(record streamtypes.Record) StreamRecord {
+	result := StreamRecord{
+		EventID:   aws.ToString(record.EventID),
+		EventName: string(record.EventName),
+	}
+	if record.Dynamodb != nil {
+		result.Keys = fromStreamItem(record.Dynamodb.Keys)
+		result.OldImage = fromStreamItem(record.Dynamodb.OldImage)
+		result.NewImage = fromStreamItem(record.Dynamodb.NewImage)
+	}
+	return result
+}
+
+// fromStreamItem converts an item of the streams API to the types of the
+// DynamoDB API, which are identical but distinct.
+func fromStreamItem(item map[string]streamtypes.AttributeValue) map[string]types.AttributeValue {
+	if item == nil {
+		return nil
+	}
+	result := make(map[string]types.AttributeValue, len(item))
+	for name, value := range item {
+		result[name] = fromStreamValue(value)
+	}
+	return result
+}
+
+func fromStreamValue(value streamtypes.AttributeValue) types.AttributeValue {
+	switch v := value.(type) {
+	case *streamtypes.AttributeValueMemberS:
+		return &types.AttributeValueMemberS{Value: v.Value}
+	case *streamtypes.AttributeValueMemberN:
+		return &types.AttributeValueMemberN{Value: v.Value}
+	case *streamtypes.AttributeValueMemberB:
+		return &types.AttributeValueMemberB{Value: v.Value}
+	case *streamtypes.AttributeValueMemberSS:
+		return &types.AttributeValueMemberSS{Value: v.Value}
+	case *streamtypes.AttributeValueMemberNS:
+		return &types.AttributeValueMemberNS{Value: v.Value}
+	case *streamtypes.AttributeValueMemberBS:
+		return &types.AttributeValueMemberBS{Value: v.Value}
+	case *streamtypes.AttributeValueMemberBOOL:
+		return &types.AttributeValueMemberBOOL{Value: v.Value}
+	case *streamtypes.AttributeValueMemberNULL:
+		return &types.AttributeValueMemberNULL{Value: v.Value}
+	case *streamtypes.AttributeValueMemberM:
+		return &types.AttributeValueMemberM{Value: fromStreamItem(v.Value)}
+	case *streamtypes.AttributeValueMemberL:
+		list := make([]types.AttributeValue, 0, len(v.Value))
+		for _, element := range v.Value {
+			list = append(list, fromStreamValue(element))
+		}
+		return &types.AttributeValueMemberL{Value: list}
+	}
+	return &types.AttributeValueMemberNULL{Value: true}
+}
@@ -0,0 +1,60 @@
+package dynamodb
+
+import (
+	"aws-dynamodb-store/internal/domain"
+	"aws-dynamodb-store/internal/repository"
+	"context"
+	"strings"
+)
+
+// tenantKeys is the TENANT#tenantID# prefix of every PK and SK of a tenant.
+// Metadata items also carry it in EntityType, which keeps the EntityTypeIndex
+// partitioned per tenant. The empty value addresses items written before keys
+// were tenant-scoped; it is never derived from a request context.
+type tenantKeys string
+
+// keysFor returns the keys of the tenant in ctx.
+func keysFor(ctx context.Context) (tenantKeys, error) {
+	tenantID, ok := repository.TenantFromContext(ctx)
+	if !ok || strings.Contains(string(tenantID), "#") {
+		return "", repository.ErrInvalidTenant
+	}
+	return tenantKeys(TenantPrefix + string(tenantID) + "#"), nil
+}
+
+func (k tenantKeys) key(prefix string, id string) string {
+	return string(k) + prefix + id
+}
+
+func (k tenantKeys) metadata(prefix string, id string) itemKey {
+	return itemKey{PK: k.key(prefix, id), SK: k.key(MetadataPrefix, id)}
+}
+
+func (k tenantKeys) entityType(entityType string) string {
+	return string(k) + entityType
+}
+
+// tenant returns the tenant ID the keys belong to.
+func (k tenantKeys) tenant() domain.TenantID {
+	return domain.TenantID(strings.TrimSuffix(strings.TrimPrefix(string(k), TenantPrefix), "#"))
+}
+
+// splitTenant separates the tenant prefix from a key such as
+// TENANT#t1#USER#u1. Keys without one return empty tenantKeys.
+func splitTenant(key string) (tenantKeys, string) {
+	if !strings.HasPrefix(key, TenantPrefix) {
+		return "", key
+	}
+	end := strings.Index(key[len(TenantPrefix):], "#")
+	if end < 0 {
+		return "", key
+	}
+	end += len(TenantPrefix) + 1
+	return tenantKeys(key[:end]), key[end:]
+}
+
+// keyID returns the ID in a key such as TENANT#t1#ROLE#r1 for prefix ROLE#.
+func keyID(key string, prefix string) string {
+	_, rest := splitTenant(key)
+	return strings.TrimPrefix(rest, prefix)
+}
@@ -0,0 +1,54 @@
+package dynamodb
+
+import (
+	"aws-dynamodb-store/internal/domain"
+	"aws-dynamodb-store/internal/repository"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestKeysFor(t *testing.T) {
+	if _, err := keysFor(context.Background()); !errors.Is(err, repository.ErrInvalidTenant) {
+		t.Errorf("expected ErrInvalidTenant without a tenant; got %v", err)
+	}
+	if _, err := keysFor(repository.WithTenant(context.Background(), "a#b")); !errors.Is(err, repository.ErrInvalidTenant) {
+		t.Errorf("expected ErrInvalidTenant for a tenant containing #; got %v", err)
+	}
+
+	k, err := keysFor(repository.WithTenant(context.Background(), domain.TenantID("t1")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := k.metadata(UserPrefix, "u1")
+	if key.PK != "TENANT#t1#USER#u1" || key.SK != "TENANT#t1#METADATA#u1" {
+		t.Errorf("unexpected metadata key %+v", key)
+	}
+	if got := k.entityType(EntityTypeUser); got != "TENANT#t1#USER" {
+		t.Errorf("unexpected entity type %s", got)
+	}
+}
+
+func TestSplitTenant(t *testing.T) {
+	tests := []struct {
+		key      string
+		wantKeys tenantKeys
+		wantRest string
+	}{
+		{"TENANT#t1#USER#u1", "TENANT#t1#", "USER#u1"},
+		{"TENANT#t1#METADATA#u1", "TENANT#t1#", "METADATA#u1"},
+		{"USER#u1", "", "USER#u1"},
+		{"TENANT#broken", "", "TENANT#broken"},
+	}
+
+	for _, tt := range tests {
+		k, rest := splitTenant(tt.key)
+		if k != tt.wantKeys || rest != tt.wantRest {
+			t.Errorf("splitTenant(%q) = %q, %q; want %q, %q", tt.key, k, rest, tt.wantKeys, tt.wantRest)
+		}
+	}
+
+	if id := keyID("TENANT#t1#ROLE#role-admin", RolePrefix); id != "role-admin" {
+		t.Errorf("unexpected role ID %s", id)
+	}
+}
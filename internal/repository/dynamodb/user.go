@@ -5,8 +5,10 @@ import (
 	"aws-dynamodb-store/internal/domain"
 	"aws-dynamodb-store/internal/repository"
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -17,62 +19,172 @@ import (
 
 type userItem struct {
 	baseItem
-	ID          domain.UserID `dynamodbav:"EntityID"` // Store the raw ID too
-	DisplayName string        `dynamodbav:"DisplayName"`
-	Email       string        `dynamodbav:"Email"`
-	CreatedAt   time.Time     `dynamodbav:"CreatedAt"`
-	UpdatedAt   time.Time     `dynamodbav:"UpdatedAt"`
+	ID          domain.UserID     `dynamodbav:"EntityID"` // Store the raw ID too
+	DisplayName string            `dynamodbav:"DisplayName"`
+	Email       string            `dynamodbav:"Email"`
+	Attributes  map[string]string `dynamodbav:"Attributes,omitempty"`
+	CreatedAt   time.Time         `dynamodbav:"CreatedAt"`
+	UpdatedAt   time.Time         `dynamodbav:"UpdatedAt"`
+	Version     int64             `dynamodbav:"Version"`
+
+	// Set by SetEffectivePermissions.
+	EffectivePermissions   []string   `dynamodbav:"EffectivePermissions,stringset,omitempty"`
+	DeniedPermissions      []string   `dynamodbav:"DeniedPermissions,stringset,omitempty"`
+	ConditionalPermissions []string   `dynamodbav:"ConditionalPermissions,stringset,omitempty"`
+	PermissionsComputedAt  int64      `dynamodbav:"PermissionsComputedAt,omitempty"` // Unix nanoseconds, absent until computed
+	PermissionsValidUntil  *time.Time `dynamodbav:"PermissionsValidUntil,omitempty"`
+}
+
+// userRoleItem is the USER#userID / ROLE#roleID edge. Assignments scoped to a
+// resource are stored in the user's partition for that resource,
+// USER#userID#RESOURCE#resourceID / ROLE#roleID, so GSI1 still finds them by
+// role.
+type userRoleItem struct {
+	baseItem
+	ResourceID string        `dynamodbav:"ResourceID,omitempty"`
+	AssignedAt time.Time     `dynamodbav:"AssignedAt"`
+	AssignedBy domain.UserID `dynamodbav:"AssignedBy,omitempty"`
+	Reason     string        `dynamodbav:"Reason,omitempty"`
+	TicketRef  string        `dynamodbav:"TicketRef,omitempty"`
+	NotBefore  *time.Time    `dynamodbav:"NotBefore,omitempty"`
+	ExpiresAt  *time.Time    `dynamodbav:"ExpiresAt,omitempty"`
 }
 
 type DynamoDBUserRepository struct {
-	client *dynamodb.Client
+	client DynamoDBAPI
 	config config.DynamoDBConfig
 }
 
-func NewDynamoDBUserRepository(client *dynamodb.Client, config config.DynamoDBConfig) repository.UserRepository {
+func NewDynamoDBUserRepository(client DynamoDBAPI, config config.DynamoDBConfig) repository.UserRepository {
 	return &DynamoDBUserRepository{client: client, config: config}
 }
 
-func userToItem(user *domain.User) *userItem {
-	pk := UserPrefix + string(user.ID)
-	return &userItem{
+func userToItem(k tenantKeys, user *domain.User) *userItem {
+	key := k.metadata(UserPrefix, string(user.ID))
+	item := &userItem{
 		baseItem: baseItem{
-			PK:         pk,
-			SK:         MetadataPrefix + string(user.ID),
-			EntityType: EntityTypeUser,
+			PK:         key.PK,
+			SK:         key.SK,
+			EntityType: k.entityType(EntityTypeUser),
 		},
 		ID:          user.ID,
 		DisplayName: user.DisplayName,
 		Email:       user.Email,
+		Attributes:  user.Attributes,
 		CreatedAt:   user.CreatedAt,
 		UpdatedAt:   user.UpdatedAt,
+		Version:     user.Version,
+	}
+	if p := user.Permissions; p != nil {
+		item.EffectivePermissions = permissionStrings(p.Allowed)
+		item.DeniedPermissions = permissionStrings(p.Denied)
+		item.ConditionalPermissions = permissionStrings(p.Conditional)
+		item.PermissionsComputedAt = p.ComputedAt.UnixNano()
+		item.PermissionsValidUntil = p.ValidUntil
 	}
+	return item
 }
 
 func itemToUser(item *userItem) *domain.User {
-	return &domain.User{
+	user := &domain.User{
 		ID:          item.ID,
 		DisplayName: item.DisplayName,
 		Email:       item.Email,
+		Attributes:  item.Attributes,
 		CreatedAt:   item.CreatedAt,
 		UpdatedAt:   item.UpdatedAt,
+		Version:     item.Version,
+	}
+	if item.PermissionsComputedAt != 0 {
+		user.Permissions = &domain.EffectivePermissions{
+			Allowed:     permissionIDs(item.EffectivePermissions),
+			Denied:      permissionIDs(item.DeniedPermissions),
+			Conditional: permissionIDs(item.ConditionalPermissions),
+			ComputedAt:  time.Unix(0, item.PermissionsComputedAt).UTC(),
+			ValidUntil:  item.PermissionsValidUntil,
+		}
+	}
+	return user
+}
+
+// markerAttempts bounds the retries of RemoveRoleFromUserOnResource while
+// roles keep being assigned on the same resource.
+const markerAttempts = 3
+
+// resourceMarker is the key of the RESOURCE# item in the user's partition that
+// lists resourceID among the resources the user holds roles on.
+func resourceMarker(k tenantKeys, userID domain.UserID, resourceID string) itemKey {
+	return itemKey{PK: k.key(UserPrefix, string(userID)), SK: k.key(ResourcePrefix, resourceID)}
+}
+
+// bindingPartition is the PK of the user's role assignments on resourceID.
+func bindingPartition(k tenantKeys, userID domain.UserID, resourceID string) string {
+	return k.key(UserPrefix, string(userID)) + "#" + ResourcePrefix + resourceID
+}
+
+func assignmentToItem(k tenantKeys, assignment *domain.RoleAssignment) *userRoleItem {
+	pk := k.key(UserPrefix, string(assignment.UserID))
+	if assignment.ResourceID != "" {
+		pk = bindingPartition(k, assignment.UserID, assignment.ResourceID)
+	}
+	item := &userRoleItem{
+		baseItem: baseItem{
+			PK:         pk,
+			SK:         k.key(RolePrefix, string(assignment.RoleID)),
+			EntityType: EntityTypeUserRoleAssignment,
+		},
+		ResourceID: assignment.ResourceID,
+		AssignedAt: assignment.AssignedAt,
+		AssignedBy: assignment.AssignedBy,
+		Reason:     assignment.Reason,
+		TicketRef:  assignment.TicketRef,
+		NotBefore:  assignment.NotBefore,
+		ExpiresAt:  assignment.ExpiresAt,
+	}
+	// Let the table TTL reap the edge once it has expired.
+	if assignment.ExpiresAt != nil {
+		item.TTL = assignment.ExpiresAt.Unix()
+	}
+	return item
+}
+
+func itemToAssignment(item *userRoleItem) *domain.RoleAssignment {
+	return &domain.RoleAssignment{
+		UserID:     domain.UserID(strings.TrimSuffix(keyID(item.PK, UserPrefix), "#"+ResourcePrefix+item.ResourceID)),
+		RoleID:     domain.RoleID(keyID(item.SK, RolePrefix)),
+		ResourceID: item.ResourceID,
+		AssignedAt: item.AssignedAt,
+		AssignedBy: item.AssignedBy,
+		Reason:     item.Reason,
+		TicketRef:  item.TicketRef,
+		NotBefore:  item.NotBefore,
+		ExpiresAt:  item.ExpiresAt,
 	}
 }
 
 func (r *DynamoDBUserRepository) CreateUser(ctx context.Context, user *domain.User) error {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return err
+	}
+
 	user.CreatedAt = time.Now().UTC()
 	user.UpdatedAt = user.CreatedAt
-	item := userToItem(user)
+	user.Version = 1
+	item := userToItem(k, user)
 
 	return createItem(ctx, r.client, r.config.TableName, item)
 
 }
 
 func (r *DynamoDBUserRepository) GetUserByID(ctx context.Context, id domain.UserID) (*domain.User, error) {
-	pk := UserPrefix + string(id)
-	sk := MetadataPrefix + string(id)
+	k, err := keysFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key := k.metadata(UserPrefix, string(id))
 
-	out, err := getItemById(ctx, r.client, r.config.TableName, pk, sk)
+	out, err := getItemById(ctx, r.client, r.config.TableName, key.PK, key.SK)
 	if err != nil {
 		return nil, err
 	}
@@ -81,73 +193,171 @@ func (r *DynamoDBUserRepository) GetUserByID(ctx context.Context, id domain.User
 	if err := attributevalue.UnmarshalMap(out.Item, &userItem); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal user item: %w", err)
 	}
+	if userItem.DeletedAt != nil {
+		return nil, repository.ErrNotFound
+	}
 	return itemToUser(&userItem), nil
 }
 
-func (r *DynamoDBUserRepository) ListAllUsers(ctx context.Context) ([]*domain.User, error) {
+func (r *DynamoDBUserRepository) UpdateUser(ctx context.Context, user *domain.User) error {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return err
+	}
+
+	current, err := r.GetUserByID(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if current.Version != user.Version {
+		return repository.ErrConflict
+	}
+
+	changes := map[string]interface{}{}
+	setIfChanged(changes, "DisplayName", current.DisplayName, user.DisplayName)
+	setIfChanged(changes, "Email", current.Email, user.Email)
+	setMapIfChanged(changes, "Attributes", current.Attributes, user.Attributes)
+	if len(changes) == 0 {
+		*user = *current
+		return nil
+	}
+
+	var updated userItem
+	if err := updateItem(ctx, r.client, r.config.TableName, k.metadata(UserPrefix, string(user.ID)), user.Version, changes, &updated); err != nil {
+		return err
+	}
+	*user = *itemToUser(&updated)
+	return nil
+}
+
+// DeleteUser removes the user and all of its role assignments.
+func (r *DynamoDBUserRepository) DeleteUser(ctx context.Context, id domain.UserID) error {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return err
+	}
+	return deleteEntity(ctx, r.client, r.config.TableName, k, UserPrefix, string(id))
+}
+
+// SoftDeleteUser hides the user until it is restored or its retention window
+// passes.
+func (r *DynamoDBUserRepository) SoftDeleteUser(ctx context.Context, id domain.UserID) error {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return err
+	}
+	return softDeleteEntity(ctx, r.client, r.config.TableName, k, UserPrefix, string(id), retentionWindow(r.config))
+}
+
+func (r *DynamoDBUserRepository) RestoreUser(ctx context.Context, id domain.UserID) error {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return err
+	}
+	return restoreEntity(ctx, r.client, r.config.TableName, k, UserPrefix, string(id))
+}
+
+func (r *DynamoDBUserRepository) ListAllUsers(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.User], error) {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	queryInput := &dynamodb.QueryInput{
 		TableName:              aws.String(r.config.TableName),
 		IndexName:              aws.String(r.config.EntityTypeIndex),
 		KeyConditionExpression: aws.String("EntityType = :entityTypeVal"),
 		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":entityTypeVal": &types.AttributeValueMemberS{Value: EntityTypeUser},
+			":entityTypeVal": &types.AttributeValueMemberS{Value: k.entityType(EntityTypeUser)},
 		},
 	}
 
-	paginator := dynamodb.NewQueryPaginator(r.client, queryInput)
-	var users []*domain.User
-
-	// roleRepo := NewDynamoDBRoleRepository(r.client, r.tableName) // Or inject it
+	items, nextCursor, err := queryPage(ctx, r.client, queryInput, page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
 
-	for paginator.HasMorePages() {
-		page, err := paginator.NextPage(ctx)
-		if err != nil {
-			return nil, fmt.Errorf("failed to query user roles: %w", err)
-		}
-		for _, item := range page.Items {
-			var partialUser userItem
-			if err := attributevalue.UnmarshalMap(item, &partialUser); err != nil {
-				log.Print(err.Error())
-				continue
-			}
-
-			user, err := r.GetUserByID(ctx, partialUser.ID)
-			if err != nil {
-				log.Print(err.Error())
-				continue
-			}
-
-			users = append(users, user)
+	keys := make([]itemKey, 0, len(items))
+	for _, item := range items {
+		var partialUser userItem
+		if err := attributevalue.UnmarshalMap(item, &partialUser); err != nil {
+			log.Print(err.Error())
+			continue
 		}
+		keys = append(keys, k.metadata(UserPrefix, string(partialUser.ID)))
 	}
-	return users, nil
+
+	users, err := hydrate(ctx, r.client, r.config.TableName, keys, itemToUser)
+	if err != nil {
+		return nil, err
+	}
+	return &repository.Page[*domain.User]{Items: users, NextCursor: nextCursor}, nil
 
 }
 
-func (r *DynamoDBUserRepository) AssignRoleToUser(ctx context.Context, userID domain.UserID, roleID domain.RoleID) error {
-	item := map[string]types.AttributeValue{
-		"PK":         &types.AttributeValueMemberS{Value: UserPrefix + string(userID)},
-		"SK":         &types.AttributeValueMemberS{Value: RolePrefix + string(roleID)},
-		"EntityType": &types.AttributeValueMemberS{Value: "UserRoleAssignment"}, // Optional but good for clarity
-		"AssignedAt": &types.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339)},
+func (r *DynamoDBUserRepository) AssignRoleToUser(ctx context.Context, assignment *domain.RoleAssignment) error {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return err
 	}
-	_, err := r.client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: aws.String(r.config.TableName),
-		Item:      item,
+
+	assignment.AssignedAt = time.Now().UTC()
+	item, err := attributevalue.MarshalMap(assignmentToItem(k, assignment))
+	if err != nil {
+		return fmt.Errorf("failed to marshal role assignment: %w", err)
+	}
+
+	if assignment.ResourceID == "" {
+		_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+			TableName: aws.String(r.config.TableName),
+			Item:      item,
+		})
+		// Consider adding checks: Does user exist? Does role exist? (Usually done in service layer)
+		if err != nil {
+			return fmt.Errorf("failed to assign role to user: %w", err)
+		}
+		return nil
+	}
+
+	// The RESOURCE# marker in the user's partition lets DeleteUser and
+	// ListUserResources find the binding partition again. Its Version changes
+	// with every assignment, which RemoveRoleFromUserOnResource relies on.
+	marker := &types.Update{
+		TableName:        aws.String(r.config.TableName),
+		Key:              resourceMarker(k, assignment.UserID, assignment.ResourceID).attributes(),
+		UpdateExpression: aws.String("SET EntityType = :entityType, #Version = if_not_exists(#Version, :zero) + :one"),
+		ExpressionAttributeNames: map[string]string{
+			"#Version": "Version",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":entityType": &types.AttributeValueMemberS{Value: EntityTypeUserResource},
+			":zero":       &types.AttributeValueMemberN{Value: "0"},
+			":one":        &types.AttributeValueMemberN{Value: "1"},
+		},
+	}
+	_, err = r.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{Update: marker},
+			{Put: &types.Put{TableName: aws.String(r.config.TableName), Item: item}},
+		},
 	})
-	// Consider adding checks: Does user exist? Does role exist? (Usually done in service layer)
 	if err != nil {
-		return fmt.Errorf("failed to assign role to user: %w", err)
+		return fmt.Errorf("failed to assign role to user on resource: %w", err)
 	}
 	return nil
 }
 
 func (r *DynamoDBUserRepository) RemoveRoleFromUser(ctx context.Context, userID domain.UserID, roleID domain.RoleID) error {
-	_, err := r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+	k, err := keysFor(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
 		TableName: aws.String(r.config.TableName),
 		Key: map[string]types.AttributeValue{
-			"PK": &types.AttributeValueMemberS{Value: UserPrefix + string(userID)},
-			"SK": &types.AttributeValueMemberS{Value: RolePrefix + string(roleID)},
+			"PK": &types.AttributeValueMemberS{Value: k.key(UserPrefix, string(userID))},
+			"SK": &types.AttributeValueMemberS{Value: k.key(RolePrefix, string(roleID))},
 		},
 	})
 	if err != nil {
@@ -156,87 +366,249 @@ func (r *DynamoDBUserRepository) RemoveRoleFromUser(ctx context.Context, userID
 	return nil
 }
 
-func (r *DynamoDBUserRepository) GetUserRoles(ctx context.Context, userID domain.UserID) ([]*domain.Role, error) {
+// RemoveRoleFromUserOnResource removes the assignment of roleID to the user on
+// resourceID. Global assignments of the role are not affected. When it was the
+// last role on the resource, the resource's marker is removed in the same
+// transaction, provided no role was assigned on the resource in the meantime.
+func (r *DynamoDBUserRepository) RemoveRoleFromUserOnResource(ctx context.Context, userID domain.UserID, roleID domain.RoleID, resourceID string) error {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return err
+	}
+
+	edge := &types.Delete{
+		TableName: aws.String(r.config.TableName),
+		Key: itemKey{
+			PK: bindingPartition(k, userID, resourceID),
+			SK: k.key(RolePrefix, string(roleID)),
+		}.attributes(),
+	}
+	for attempt := 0; attempt < markerAttempts; attempt++ {
+		transactItems := []types.TransactWriteItem{{Delete: edge}}
+
+		markerDelete, err := r.lastRoleMarkerDelete(ctx, k, userID, roleID, resourceID)
+		if err != nil {
+			return err
+		}
+		if markerDelete != nil {
+			transactItems = append(transactItems, types.TransactWriteItem{Delete: markerDelete})
+		}
+
+		_, err = r.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems: transactItems,
+		})
+		var canceled *types.TransactionCanceledException
+		if errors.As(err, &canceled) {
+			// A role was assigned on the resource since the marker was read.
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to remove role from user on resource: %w", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("failed to remove role from user on resource: %w", repository.ErrConflict)
+}
+
+// lastRoleMarkerDelete returns the delete of the RESOURCE# marker of
+// resourceID when roleID is the last role the user holds on it, or nil. The
+// delete only succeeds while the marker's Version is the one read here.
+func (r *DynamoDBUserRepository) lastRoleMarkerDelete(ctx context.Context, k tenantKeys, userID domain.UserID, roleID domain.RoleID, resourceID string) (*types.Delete, error) {
+	key := resourceMarker(k, userID, resourceID)
+	out, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(r.config.TableName),
+		Key:            key.attributes(),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get resource marker: %w", err)
+	}
+	if out.Item == nil {
+		return nil, nil
+	}
+
+	queryInput := prefixQuery(r.config.TableName, bindingPartition(k, userID, resourceID), k.key(RolePrefix, ""))
+	queryInput.ConsistentRead = aws.Bool(true)
+	queryInput.Limit = aws.Int32(2)
+	edges, err := r.client.Query(ctx, queryInput)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query roles on resource: %w", err)
+	}
+	for _, item := range edges.Items {
+		if stringAttr(item, "SK") != k.key(RolePrefix, string(roleID)) {
+			return nil, nil
+		}
+	}
+
+	markerDelete := &types.Delete{
+		TableName:                aws.String(r.config.TableName),
+		Key:                      key.attributes(),
+		ConditionExpression:      aws.String("attribute_not_exists(#Version)"),
+		ExpressionAttributeNames: map[string]string{"#Version": "Version"},
+	}
+	if version, ok := out.Item["Version"].(*types.AttributeValueMemberN); ok {
+		markerDelete.ConditionExpression = aws.String("#Version = :version")
+		markerDelete.ExpressionAttributeValues = map[string]types.AttributeValue{":version": version}
+	}
+	return markerDelete, nil
+}
+
+// GetUserRoles returns the roles assigned to the user globally.
+func (r *DynamoDBUserRepository) GetUserRoles(ctx context.Context, userID domain.UserID, page repository.PageRequest) (*repository.Page[*domain.Role], error) {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.activeRoles(ctx, k, k.key(UserPrefix, string(userID)), page)
+}
+
+// GetUserRolesOnResource returns the roles assigned to the user on resourceID
+// only, without its global roles.
+func (r *DynamoDBUserRepository) GetUserRolesOnResource(ctx context.Context, userID domain.UserID, resourceID string, page repository.PageRequest) (*repository.Page[*domain.Role], error) {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.activeRoles(ctx, k, bindingPartition(k, userID, resourceID), page)
+}
+
+// ListUserResources returns the resources the user has been assigned roles on
+// whose path starts with prefix, in path order.
+func (r *DynamoDBUserRepository) ListUserResources(ctx context.Context, userID domain.UserID, prefix string, page repository.PageRequest) (*repository.Page[string], error) {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	queryInput := prefixQuery(r.config.TableName, k.key(UserPrefix, string(userID)), k.key(ResourcePrefix, prefix))
+	items, nextCursor, err := queryPage(ctx, r.client, queryInput, page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user resources: %w", err)
+	}
+
+	resources := make([]string, 0, len(items))
+	for _, item := range items {
+		resources = append(resources, keyID(stringAttr(item, "SK"), ResourcePrefix))
+	}
+	return &repository.Page[string]{Items: resources, NextCursor: nextCursor}, nil
+}
+
+// activeRoles returns the roles of the assignments in partition pk that are
+// currently in effect.
+func (r *DynamoDBUserRepository) activeRoles(ctx context.Context, k tenantKeys, pk string, page repository.PageRequest) (*repository.Page[*domain.Role], error) {
 	queryInput := &dynamodb.QueryInput{
 		TableName:              aws.String(r.config.TableName),
 		KeyConditionExpression: aws.String("PK = :pkVal AND begins_with(SK, :skPrefix)"),
 		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":pkVal":    &types.AttributeValueMemberS{Value: UserPrefix + string(userID)},
-			":skPrefix": &types.AttributeValueMemberS{Value: RolePrefix},
+			":pkVal":    &types.AttributeValueMemberS{Value: pk},
+			":skPrefix": &types.AttributeValueMemberS{Value: k.key(RolePrefix, "")},
 		},
 	}
 
-	paginator := dynamodb.NewQueryPaginator(r.client, queryInput)
-	var roles []*domain.Role
+	items, nextCursor, err := queryPage(ctx, r.client, queryInput, page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user roles: %w", err)
+	}
+
+	// SK for a User-Role assignment is ROLE#roleID, the role metadata lives
+	// under ROLE#roleID / METADATA#roleID.
+	// Assignments outside their NotBefore/ExpiresAt window are skipped, even
+	// before the table TTL has reaped them.
+	now := time.Now().UTC()
+	keys := make([]itemKey, 0, len(items))
+	for _, item := range items {
+		var edge userRoleItem
+		if err := attributevalue.UnmarshalMap(item, &edge); err != nil {
+			// log error and continue or return
+			continue
+		}
+		if !itemToAssignment(&edge).ActiveAt(now) {
+			continue
+		}
+		keys = append(keys, k.metadata(RolePrefix, keyID(edge.SK, RolePrefix)))
+	}
 
-	// Need a separate RoleRepository or a helper to fetch Role details
-	// For simplicity here, we'll assume the SK contains enough info or we do a subsequent GetItem for role details.
-	// A better approach would be to have a GetRoleByID in RoleRepository and call it.
-	// Let's assume for now SK for User-Role assignment is just ROLE#roleID.
-	// We need to fetch the actual role metadata.
+	roles, err := hydrate(ctx, r.client, r.config.TableName, keys, itemToRole)
+	if err != nil {
+		return nil, err
+	}
+	return &repository.Page[*domain.Role]{Items: roles, NextCursor: nextCursor}, nil
+}
 
-	roleRepo := NewDynamoDBRoleRepository(r.client, r.config) // Or inject it
+// ListUserAssignments returns the user's global role assignment edges as
+// stored, including ones outside their NotBefore/ExpiresAt window.
+func (r *DynamoDBUserRepository) ListUserAssignments(ctx context.Context, userID domain.UserID, page repository.PageRequest) (*repository.Page[*domain.RoleAssignment], error) {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	for paginator.HasMorePages() {
-		page, err := paginator.NextPage(ctx)
-		if err != nil {
-			return nil, fmt.Errorf("failed to query user roles: %w", err)
-		}
-		for _, item := range page.Items {
-			var base baseItem
-			if err := attributevalue.UnmarshalMap(item, &base); err != nil {
-				// log error and continue or return
-				continue
-			}
-			// SK should be like ROLE#role-id
-			roleIDStr := base.SK[len(RolePrefix):]
-			role, err := roleRepo.GetRoleByID(ctx, domain.RoleID(roleIDStr))
-			if err != nil {
-				// Handle error, maybe role was deleted, log and continue
-				fmt.Printf("Warning: could not fetch role %s for user %s: %v\n", roleIDStr, userID, err)
-				continue
-			}
-			roles = append(roles, role)
+	queryInput := &dynamodb.QueryInput{
+		TableName:              aws.String(r.config.TableName),
+		KeyConditionExpression: aws.String("PK = :pkVal AND begins_with(SK, :skPrefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pkVal":    &types.AttributeValueMemberS{Value: k.key(UserPrefix, string(userID))},
+			":skPrefix": &types.AttributeValueMemberS{Value: k.key(RolePrefix, "")},
+		},
+	}
+
+	items, nextCursor, err := queryPage(ctx, r.client, queryInput, page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user role assignments: %w", err)
+	}
+
+	assignments := make([]*domain.RoleAssignment, 0, len(items))
+	for _, item := range items {
+		var edge userRoleItem
+		if err := attributevalue.UnmarshalMap(item, &edge); err != nil {
+			log.Print(err.Error())
+			continue
 		}
+		assignments = append(assignments, itemToAssignment(&edge))
 	}
-	return roles, nil
+	return &repository.Page[*domain.RoleAssignment]{Items: assignments, NextCursor: nextCursor}, nil
 }
 
-func (r *DynamoDBUserRepository) ListUsersInRole(ctx context.Context, roleID domain.RoleID) ([]*domain.User, error) {
+func (r *DynamoDBUserRepository) ListUsersInRole(ctx context.Context, roleID domain.RoleID, page repository.PageRequest) (*repository.Page[*domain.User], error) {
+	k, err := keysFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	queryInput := &dynamodb.QueryInput{
 		TableName:              aws.String(r.config.TableName),
 		IndexName:              aws.String(GSI1Name), // GSI1PK = SK, GSI1SK = PK
 		KeyConditionExpression: aws.String("SK = :skVal AND begins_with(PK, :pkPrefix)"),
 		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":skVal":    &types.AttributeValueMemberS{Value: RolePrefix + string(roleID)},
-			":pkPrefix": &types.AttributeValueMemberS{Value: UserPrefix},
+			":skVal":    &types.AttributeValueMemberS{Value: k.key(RolePrefix, string(roleID))},
+			":pkPrefix": &types.AttributeValueMemberS{Value: k.key(UserPrefix, "")},
 		},
 	}
 
-	paginator := dynamodb.NewQueryPaginator(r.client, queryInput)
-	var users []*domain.User
+	items, nextCursor, err := queryPage(ctx, r.client, queryInput, page)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users in role using GSI1: %w", err)
+	}
 
-	for paginator.HasMorePages() {
-		page, err := paginator.NextPage(ctx)
-		if err != nil {
-			return nil, fmt.Errorf("failed to query users in role using GSI1: %w", err)
+	now := time.Now().UTC()
+	keys := make([]itemKey, 0, len(items))
+	for _, itemMap := range items {
+		var edge userRoleItem
+		if err := attributevalue.UnmarshalMap(itemMap, &edge); err != nil {
+			// log and continue
+			continue
 		}
-		for _, itemMap := range page.Items {
-			var base baseItem
-			if err := attributevalue.UnmarshalMap(itemMap, &base); err != nil {
-				// log and continue
-				continue
-			}
-			// PK should be USER#userID
-			userIDStr := base.PK[len(UserPrefix):]
-			user, err := r.GetUserByID(ctx, domain.UserID(userIDStr)) // Fetch full user details
-			if err != nil {
-				// Handle error, maybe user was deleted
-				fmt.Printf("Warning: could not fetch user %s for role %s: %v\n", userIDStr, roleID, err)
-				continue
-			}
-			users = append(users, user)
+		// Users holding the role on a single resource are not members of it.
+		if edge.ResourceID != "" || !itemToAssignment(&edge).ActiveAt(now) {
+			continue
 		}
+		// PK should be USER#userID
+		keys = append(keys, k.metadata(UserPrefix, keyID(edge.PK, UserPrefix)))
+	}
+
+	users, err := hydrate(ctx, r.client, r.config.TableName, keys, itemToUser)
+	if err != nil {
+		return nil, err
 	}
-	return users, nil
+	return &repository.Page[*domain.User]{Items: users, NextCursor: nextCursor}, nil
 }
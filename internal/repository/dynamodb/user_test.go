@@ -0,0 +1,88 @@
+package dynamodb
+
+import (
+	"aws-dynamodb-store/internal/domain"
+	"aws-dynamodb-store/internal/repository"
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAssignmentItemRoundTrip(t *testing.T) {
+	k := tenantKeys("TENANT#t1#")
+
+	tests := []struct {
+		name       string
+		assignment domain.RoleAssignment
+		wantPK     string
+	}{
+		{"global", domain.RoleAssignment{UserID: "u1", RoleID: "role-editor"}, "TENANT#t1#USER#u1"},
+		{"on resource", domain.RoleAssignment{UserID: "u1", RoleID: "role-editor", ResourceID: "project/42"}, "TENANT#t1#USER#u1#RESOURCE#project/42"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := assignmentToItem(k, &tt.assignment)
+			if item.PK != tt.wantPK || item.SK != "TENANT#t1#ROLE#role-editor" {
+				t.Fatalf("unexpected key %s / %s", item.PK, item.SK)
+			}
+			got := itemToAssignment(item)
+			if got.UserID != tt.assignment.UserID || got.RoleID != tt.assignment.RoleID || got.ResourceID != tt.assignment.ResourceID {
+				t.Errorf("expected %+v; got %+v", tt.assignment, *got)
+			}
+		})
+	}
+}
+
+func TestUserItemEffectivePermissions(t *testing.T) {
+	k := tenantKeys("TENANT#t1#")
+
+	if got := itemToUser(userToItem(k, &domain.User{ID: "u1"})); got.Permissions != nil {
+		t.Fatalf("expected no effective permissions; got %+v", got.Permissions)
+	}
+
+	computedAt := time.Date(2024, 5, 1, 12, 0, 0, 123, time.UTC)
+	user := &domain.User{ID: "u1", Permissions: &domain.EffectivePermissions{
+		Allowed:    []domain.PermissionID{"document:read"},
+		ComputedAt: computedAt,
+	}}
+	got := itemToUser(userToItem(k, user)).Permissions
+	if got == nil || !got.ComputedAt.Equal(computedAt) || len(got.Allowed) != 1 || got.Allowed[0] != "document:read" || got.Denied != nil {
+		t.Errorf("expected %+v; got %+v", user.Permissions, got)
+	}
+}
+
+func TestRemoveRoleFromUserOnResourceKeepsConcurrentAssignments(t *testing.T) {
+	client := newFakeDynamoDB()
+	users := newFakeRepository(client).User
+	ctx := repository.WithTenant(context.Background(), "t1")
+	if err := users.AssignRoleToUser(ctx, &domain.RoleAssignment{UserID: "u1", RoleID: "owner", ResourceID: "org/acme"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another role is assigned on the resource after the remove has found
+	// owner to be the last one, which must keep the marker.
+	assigned := false
+	client.Intercept = func(operation string, input any) error {
+		if operation != "TransactWriteItems" || assigned {
+			return nil
+		}
+		assigned = true
+		client.Intercept = nil
+		return users.AssignRoleToUser(ctx, &domain.RoleAssignment{UserID: "u1", RoleID: "editor", ResourceID: "org/acme"})
+	}
+	if err := users.RemoveRoleFromUserOnResource(ctx, "u1", "owner", "org/acme"); err != nil {
+		t.Fatal(err)
+	}
+	if !assigned {
+		t.Fatal("expected the concurrent assignment to run")
+	}
+	resources, err := users.ListUserResources(ctx, "u1", "", repository.PageRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(resources.Items, []string{"org/acme"}) {
+		t.Errorf("expected org/acme to stay listed; got %v", resources.Items)
+	}
+}
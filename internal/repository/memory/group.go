@@ -0,0 +1,172 @@
+package memory
+
+import (
+	"aws-dynamodb-store/internal/domain"
+	"aws-dynamodb-store/internal/repository"
+	"context"
+)
+
+type MemoryGroupRepository struct {
+	store *store
+}
+
+func cloneGroup(group *domain.Group) *domain.Group {
+	c := *group
+	return &c
+}
+
+func (r *MemoryGroupRepository) CreateGroup(ctx context.Context, group *domain.Group) error {
+	t, unlock, err := r.store.write(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, ok := t.groups[group.ID]; ok {
+		return repository.ErrAlreadyExists
+	}
+	group.CreatedAt = now()
+	group.UpdatedAt = group.CreatedAt
+	t.groups[group.ID] = &entry[domain.Group]{entity: *group}
+	return nil
+}
+
+func (r *MemoryGroupRepository) GetGroupByID(ctx context.Context, id domain.GroupID) (*domain.Group, error) {
+	t, unlock, err := r.store.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	e := t.groups[id]
+	if !e.live() {
+		return nil, repository.ErrNotFound
+	}
+	return cloneGroup(&e.entity), nil
+}
+
+// DeleteGroup removes the group, its role assignments and its memberships.
+func (r *MemoryGroupRepository) DeleteGroup(ctx context.Context, id domain.GroupID) error {
+	t, unlock, err := r.store.write(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	_, found := t.groups[id]
+	removed := len(t.groupRoles[id])
+	delete(t.groups, id)
+	delete(t.groupRoles, id)
+	removed += deleteEdgesTo(t.memberships, id)
+	if !found && removed == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+func (r *MemoryGroupRepository) ListAllGroups(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.Group], error) {
+	t, unlock, err := r.store.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return repository.PageSlice(hydrate(t.groups, liveIDs(t.groups), cloneGroup), page)
+}
+
+func (r *MemoryGroupRepository) AddUserToGroup(ctx context.Context, userID domain.UserID, groupID domain.GroupID) error {
+	t, unlock, err := r.store.write(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	setEdge(t.memberships, userID, groupID, true)
+	return nil
+}
+
+func (r *MemoryGroupRepository) RemoveUserFromGroup(ctx context.Context, userID domain.UserID, groupID domain.GroupID) error {
+	t, unlock, err := r.store.write(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	deleteEdge(t.memberships, userID, groupID)
+	return nil
+}
+
+func (r *MemoryGroupRepository) GetUserGroups(ctx context.Context, userID domain.UserID, page repository.PageRequest) (*repository.Page[*domain.Group], error) {
+	t, unlock, err := r.store.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return repository.PageSlice(hydrate(t.groups, sortedKeys(t.memberships[userID]), cloneGroup), page)
+}
+
+func (r *MemoryGroupRepository) ListGroupMembers(ctx context.Context, groupID domain.GroupID, page repository.PageRequest) (*repository.Page[*domain.User], error) {
+	t, unlock, err := r.store.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return repository.PageSlice(hydrate(t.users, sources(t.memberships, groupID), cloneUser), page)
+}
+
+// RecountGroupMembers counts the members of the group and stores the count on
+// the group.
+func (r *MemoryGroupRepository) RecountGroupMembers(ctx context.Context, groupID domain.GroupID) (int, error) {
+	t, unlock, err := r.store.write(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	e := t.groups[groupID]
+	if e == nil {
+		return 0, repository.ErrNotFound
+	}
+	e.entity.MemberCount = len(sources(t.memberships, groupID))
+	return e.entity.MemberCount, nil
+}
+
+// ListGroupsWithRole returns the groups roleID is assigned to.
+func (r *MemoryGroupRepository) ListGroupsWithRole(ctx context.Context, roleID domain.RoleID, page repository.PageRequest) (*repository.Page[*domain.Group], error) {
+	t, unlock, err := r.store.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return repository.PageSlice(hydrate(t.groups, sources(t.groupRoles, roleID), cloneGroup), page)
+}
+
+func (r *MemoryGroupRepository) AssignRoleToGroup(ctx context.Context, groupID domain.GroupID, roleID domain.RoleID) error {
+	t, unlock, err := r.store.write(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	setEdge(t.groupRoles, groupID, roleID, true)
+	return nil
+}
+
+func (r *MemoryGroupRepository) RemoveRoleFromGroup(ctx context.Context, groupID domain.GroupID, roleID domain.RoleID) error {
+	t, unlock, err := r.store.write(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	deleteEdge(t.groupRoles, groupID, roleID)
+	return nil
+}
+
+func (r *MemoryGroupRepository) GetGroupRoles(ctx context.Context, groupID domain.GroupID, page repository.PageRequest) (*repository.Page[*domain.Role], error) {
+	t, unlock, err := r.store.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return repository.PageSlice(hydrate(t.roles, sortedKeys(t.groupRoles[groupID]), cloneRole), page)
+}
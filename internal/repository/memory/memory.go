@@ -0,0 +1,251 @@
+// Package memory keeps the repositories in process memory. It mirrors the
+// behaviour of the DynamoDB backend, including soft deletes, cascading deletes
+// and the errors it returns, so the service and the handlers can run without a
+// table, in tests and local demos. Nothing is persisted.
+package memory
+
+import (
+	"aws-dynamodb-store/internal/domain"
+	"aws-dynamodb-store/internal/repository"
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// store holds the data of every tenant. A single lock serialises writes, as
+// with the conditional writes of the table no caller observes a partial one.
+type store struct {
+	mu        sync.RWMutex
+	tenants   map[domain.TenantID]*tenant
+	retention time.Duration // How long soft-deleted entities stay restorable
+}
+
+// tenant holds the entities of one tenant and the edges between them, keyed
+// like the partitions of the table.
+type tenant struct {
+	users       map[domain.UserID]*entry[domain.User]
+	roles       map[domain.RoleID]*entry[domain.Role]
+	permissions map[domain.PermissionID]*entry[domain.Permission]
+	groups      map[domain.GroupID]*entry[domain.Group]
+
+	userRoles map[domain.UserID]map[domain.RoleID]*domain.RoleAssignment
+	// Assignments on a resource, per user and resource path. A resource stays
+	// listed without roles once they are removed, like the RESOURCE# markers of
+	// the table, until the user is deleted.
+	resourceRoles map[domain.UserID]map[string]map[domain.RoleID]*domain.RoleAssignment
+	grants        map[domain.RoleID]map[domain.PermissionID]*domain.PermissionGrant
+	parents       map[domain.RoleID]map[domain.RoleID]bool // Child role to its parents
+	memberships   map[domain.UserID]map[domain.GroupID]bool
+	groupRoles    map[domain.GroupID]map[domain.RoleID]bool
+}
+
+// entry is a stored entity. deletedAt is set while it is soft-deleted, until
+// which it can be restored before expiresAt.
+type entry[T any] struct {
+	entity    T
+	deletedAt *time.Time
+	expiresAt time.Time
+}
+
+func (e *entry[T]) live() bool {
+	return e != nil && e.deletedAt == nil
+}
+
+// NewMemoryRepository returns empty repositories sharing one store.
+// Soft-deleted entities can be restored for softDeleteRetention.
+func NewMemoryRepository(softDeleteRetention time.Duration) repository.Repository {
+	s := &store{
+		tenants:   make(map[domain.TenantID]*tenant),
+		retention: softDeleteRetention,
+	}
+	return repository.Repository{
+		User:       &MemoryUserRepository{store: s},
+		Role:       &MemoryRoleRepository{store: s},
+		Permission: &MemoryPermissionRepository{store: s},
+		Group:      &MemoryGroupRepository{store: s},
+	}
+}
+
+func newTenant() *tenant {
+	return &tenant{
+		users:         make(map[domain.UserID]*entry[domain.User]),
+		roles:         make(map[domain.RoleID]*entry[domain.Role]),
+		permissions:   make(map[domain.PermissionID]*entry[domain.Permission]),
+		groups:        make(map[domain.GroupID]*entry[domain.Group]),
+		userRoles:     make(map[domain.UserID]map[domain.RoleID]*domain.RoleAssignment),
+		resourceRoles: make(map[domain.UserID]map[string]map[domain.RoleID]*domain.RoleAssignment),
+		grants:        make(map[domain.RoleID]map[domain.PermissionID]*domain.PermissionGrant),
+		parents:       make(map[domain.RoleID]map[domain.RoleID]bool),
+		memberships:   make(map[domain.UserID]map[domain.GroupID]bool),
+		groupRoles:    make(map[domain.GroupID]map[domain.RoleID]bool),
+	}
+}
+
+// tenantID returns the tenant in ctx, rejecting the same IDs the DynamoDB
+// backend cannot key.
+func tenantID(ctx context.Context) (domain.TenantID, error) {
+	id, ok := repository.TenantFromContext(ctx)
+	if !ok || strings.Contains(string(id), "#") {
+		return "", repository.ErrInvalidTenant
+	}
+	return id, nil
+}
+
+// read locks the store for reading and returns the tenant in ctx. Tenants
+// without data read as empty.
+func (s *store) read(ctx context.Context) (*tenant, func(), error) {
+	id, err := tenantID(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.mu.RLock()
+	t, ok := s.tenants[id]
+	if !ok {
+		t = newTenant()
+	}
+	return t, s.mu.RUnlock, nil
+}
+
+// write locks the store for writing and returns the tenant in ctx.
+func (s *store) write(ctx context.Context) (*tenant, func(), error) {
+	id, err := tenantID(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.mu.Lock()
+	t, ok := s.tenants[id]
+	if !ok {
+		t = newTenant()
+		s.tenants[id] = t
+	}
+	return t, s.mu.Unlock, nil
+}
+
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+// softDelete hides the entity until it is restored or the retention window
+// passes. touch refreshes its UpdatedAt and Version.
+func softDelete[K comparable, T any](s *store, entries map[K]*entry[T], id K, touch func(*T, time.Time)) error {
+	e := entries[id]
+	if !e.live() {
+		return repository.ErrNotFound
+	}
+	at := now()
+	e.deletedAt = &at
+	e.expiresAt = at.Add(s.retention)
+	touch(&e.entity, at)
+	return nil
+}
+
+// restore clears the deletion of the entity, as long as its retention window
+// has not passed yet.
+func restore[K comparable, T any](entries map[K]*entry[T], id K, touch func(*T, time.Time)) error {
+	e := entries[id]
+	at := now()
+	if e == nil || e.deletedAt == nil || !e.expiresAt.After(at) {
+		return repository.ErrNotFound
+	}
+	e.deletedAt = nil
+	e.expiresAt = time.Time{}
+	touch(&e.entity, at)
+	return nil
+}
+
+// hydrate returns copies of the live entities behind ids, preserving their
+// order. Missing and soft-deleted entities are skipped.
+func hydrate[K comparable, T any](entries map[K]*entry[T], ids []K, clone func(*T) *T) []*T {
+	result := make([]*T, 0, len(ids))
+	for _, id := range ids {
+		if e := entries[id]; e.live() {
+			result = append(result, clone(&e.entity))
+		}
+	}
+	return result
+}
+
+// liveIDs returns the IDs of the live entities, in ID order.
+func liveIDs[K cmp.Ordered, T any](entries map[K]*entry[T]) []K {
+	ids := make([]K, 0, len(entries))
+	for id, e := range entries {
+		if e.live() {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// sortedKeys returns the keys of m in order, as a query of a partition
+// returns its items in SK order.
+func sortedKeys[K cmp.Ordered, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// setEdge adds the edge from to to, creating the inner map as needed.
+func setEdge[K comparable, L comparable, V any](edges map[K]map[L]V, from K, to L, value V) {
+	if edges[from] == nil {
+		edges[from] = make(map[L]V)
+	}
+	edges[from][to] = value
+}
+
+// deleteEdge removes the edge from to to and reports whether it existed.
+func deleteEdge[K comparable, L comparable, V any](edges map[K]map[L]V, from K, to L) bool {
+	if _, ok := edges[from][to]; !ok {
+		return false
+	}
+	delete(edges[from], to)
+	if len(edges[from]) == 0 {
+		delete(edges, from)
+	}
+	return true
+}
+
+// deleteEdgesTo removes every edge pointing at to and returns how many there were.
+func deleteEdgesTo[K comparable, L comparable, V any](edges map[K]map[L]V, to L) int {
+	removed := 0
+	for from := range edges {
+		if deleteEdge(edges, from, to) {
+			removed++
+		}
+	}
+	return removed
+}
+
+// sources returns, in order, the keys with an edge to to.
+func sources[K cmp.Ordered, L comparable, V any](edges map[K]map[L]V, to L) []K {
+	var from []K
+	for k, targets := range edges {
+		if _, ok := targets[to]; ok {
+			from = append(from, k)
+		}
+	}
+	slices.Sort(from)
+	return from
+}
+
+func cloneStrings(m map[string]string) map[string]string {
+	if len(m) == 0 {
+		return nil
+	}
+	return maps.Clone(m)
+}
+
+func cloneTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
@@ -0,0 +1,14 @@
+package memory
+
+import (
+	"aws-dynamodb-store/internal/repository"
+	"aws-dynamodb-store/internal/repository/repositorytest"
+	"testing"
+	"time"
+)
+
+func TestConformance(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repository.Repository {
+		return NewMemoryRepository(time.Hour)
+	})
+}
@@ -0,0 +1,121 @@
+package memory
+
+import (
+	"aws-dynamodb-store/internal/domain"
+	"aws-dynamodb-store/internal/repository"
+	"context"
+	"time"
+)
+
+type MemoryPermissionRepository struct {
+	store *store
+}
+
+func clonePermission(permission *domain.Permission) *domain.Permission {
+	c := *permission
+	return &c
+}
+
+func touchPermission(permission *domain.Permission, at time.Time) {
+	permission.UpdatedAt = at
+	permission.Version++
+}
+
+func (r *MemoryPermissionRepository) CreatePermission(ctx context.Context, permission *domain.Permission) error {
+	t, unlock, err := r.store.write(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, ok := t.permissions[permission.ID]; ok {
+		return repository.ErrAlreadyExists
+	}
+	permission.CreatedAt = now()
+	permission.UpdatedAt = permission.CreatedAt
+	permission.Version = 1
+	t.permissions[permission.ID] = &entry[domain.Permission]{entity: *permission}
+	return nil
+}
+
+func (r *MemoryPermissionRepository) GetPermissionByID(ctx context.Context, id domain.PermissionID) (*domain.Permission, error) {
+	t, unlock, err := r.store.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	e := t.permissions[id]
+	if !e.live() {
+		return nil, repository.ErrNotFound
+	}
+	return clonePermission(&e.entity), nil
+}
+
+func (r *MemoryPermissionRepository) UpdatePermission(ctx context.Context, permission *domain.Permission) error {
+	t, unlock, err := r.store.write(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	e := t.permissions[permission.ID]
+	if !e.live() {
+		return repository.ErrNotFound
+	}
+	current := &e.entity
+	if current.Version != permission.Version {
+		return repository.ErrConflict
+	}
+
+	if current.DisplayName != permission.DisplayName || current.Description != permission.Description {
+		current.DisplayName = permission.DisplayName
+		current.Description = permission.Description
+		touchPermission(current, now())
+	}
+	*permission = *current
+	return nil
+}
+
+// DeletePermission removes the permission and unassigns it from every role.
+func (r *MemoryPermissionRepository) DeletePermission(ctx context.Context, id domain.PermissionID) error {
+	t, unlock, err := r.store.write(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	_, found := t.permissions[id]
+	delete(t.permissions, id)
+	if removed := deleteEdgesTo(t.grants, id); !found && removed == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+func (r *MemoryPermissionRepository) SoftDeletePermission(ctx context.Context, id domain.PermissionID) error {
+	t, unlock, err := r.store.write(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return softDelete(r.store, t.permissions, id, touchPermission)
+}
+
+func (r *MemoryPermissionRepository) RestorePermission(ctx context.Context, id domain.PermissionID) error {
+	t, unlock, err := r.store.write(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return restore(t.permissions, id, touchPermission)
+}
+
+func (r *MemoryPermissionRepository) ListAllPermissions(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.Permission], error) {
+	t, unlock, err := r.store.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return repository.PageSlice(hydrate(t.permissions, liveIDs(t.permissions), clonePermission), page)
+}
@@ -0,0 +1,252 @@
+package memory
+
+import (
+	"aws-dynamodb-store/internal/domain"
+	"aws-dynamodb-store/internal/repository"
+	"context"
+	"time"
+)
+
+type MemoryRoleRepository struct {
+	store *store
+}
+
+func cloneRole(role *domain.Role) *domain.Role {
+	c := *role
+	return &c
+}
+
+// cloneGrant copies grant as the table returns it: grants without an effect
+// allow.
+func cloneGrant(grant *domain.PermissionGrant) *domain.PermissionGrant {
+	c := *grant
+	if c.Effect == "" {
+		c.Effect = domain.EffectAllow
+	}
+	return &c
+}
+
+func touchRole(role *domain.Role, at time.Time) {
+	role.UpdatedAt = at
+	role.Version++
+}
+
+func (r *MemoryRoleRepository) CreateRole(ctx context.Context, role *domain.Role) error {
+	t, unlock, err := r.store.write(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, ok := t.roles[role.ID]; ok {
+		return repository.ErrAlreadyExists
+	}
+	role.CreatedAt = now()
+	role.UpdatedAt = role.CreatedAt
+	role.Version = 1
+	t.roles[role.ID] = &entry[domain.Role]{entity: *role}
+	return nil
+}
+
+func (r *MemoryRoleRepository) GetRoleByID(ctx context.Context, id domain.RoleID) (*domain.Role, error) {
+	t, unlock, err := r.store.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	e := t.roles[id]
+	if !e.live() {
+		return nil, repository.ErrNotFound
+	}
+	return cloneRole(&e.entity), nil
+}
+
+func (r *MemoryRoleRepository) UpdateRole(ctx context.Context, role *domain.Role) error {
+	t, unlock, err := r.store.write(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	e := t.roles[role.ID]
+	if !e.live() {
+		return repository.ErrNotFound
+	}
+	current := &e.entity
+	if current.Version != role.Version {
+		return repository.ErrConflict
+	}
+
+	if current.DisplayName != role.DisplayName || current.Description != role.Description {
+		current.DisplayName = role.DisplayName
+		current.Description = role.Description
+		touchRole(current, now())
+	}
+	*role = *current
+	return nil
+}
+
+// DeleteRole removes the role together with its permission assignments, its
+// inheritance edges and its assignments to users and groups.
+func (r *MemoryRoleRepository) DeleteRole(ctx context.Context, id domain.RoleID) error {
+	t, unlock, err := r.store.write(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	_, found := t.roles[id]
+	removed := len(t.grants[id]) + len(t.parents[id])
+	delete(t.roles, id)
+	delete(t.grants, id)
+	delete(t.parents, id)
+	removed += deleteEdgesTo(t.parents, id)
+	removed += deleteEdgesTo(t.userRoles, id)
+	removed += deleteEdgesTo(t.groupRoles, id)
+	for _, resources := range t.resourceRoles {
+		removed += deleteEdgesTo(resources, id)
+	}
+	if !found && removed == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+func (r *MemoryRoleRepository) SoftDeleteRole(ctx context.Context, id domain.RoleID) error {
+	t, unlock, err := r.store.write(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return softDelete(r.store, t.roles, id, touchRole)
+}
+
+func (r *MemoryRoleRepository) RestoreRole(ctx context.Context, id domain.RoleID) error {
+	t, unlock, err := r.store.write(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return restore(t.roles, id, touchRole)
+}
+
+func (r *MemoryRoleRepository) ListAllRoles(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.Role], error) {
+	t, unlock, err := r.store.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return repository.PageSlice(hydrate(t.roles, liveIDs(t.roles), cloneRole), page)
+}
+
+func (r *MemoryRoleRepository) AssignPermissionToRole(ctx context.Context, grant *domain.PermissionGrant) error {
+	t, unlock, err := r.store.write(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	grant.AssignedAt = now()
+	c := *grant
+	setEdge(t.grants, grant.RoleID, grant.PermissionID, &c)
+	return nil
+}
+
+func (r *MemoryRoleRepository) RemovePermissionFromRole(ctx context.Context, roleID domain.RoleID, permissionID domain.PermissionID) error {
+	t, unlock, err := r.store.write(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	deleteEdge(t.grants, roleID, permissionID)
+	return nil
+}
+
+// GetRolePermissions returns the permissions the role allows, regardless of
+// their conditions. Denied permissions are only returned by ListRoleGrants.
+func (r *MemoryRoleRepository) GetRolePermissions(ctx context.Context, roleID domain.RoleID, page repository.PageRequest) (*repository.Page[*domain.Permission], error) {
+	t, unlock, err := r.store.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var ids []domain.PermissionID
+	for _, permissionID := range sortedKeys(t.grants[roleID]) {
+		if t.grants[roleID][permissionID].Effect != domain.EffectDeny {
+			ids = append(ids, permissionID)
+		}
+	}
+	return repository.PageSlice(hydrate(t.permissions, ids, clonePermission), page)
+}
+
+// ListRoleGrants returns the allow and deny grants of the role. Grants of
+// permissions that no longer exist or are soft-deleted are skipped.
+func (r *MemoryRoleRepository) ListRoleGrants(ctx context.Context, roleID domain.RoleID, page repository.PageRequest) (*repository.Page[*domain.PermissionGrant], error) {
+	t, unlock, err := r.store.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	grants := make([]*domain.PermissionGrant, 0, len(t.grants[roleID]))
+	for _, permissionID := range sortedKeys(t.grants[roleID]) {
+		if t.permissions[permissionID].live() {
+			grants = append(grants, cloneGrant(t.grants[roleID][permissionID]))
+		}
+	}
+	return repository.PageSlice(grants, page)
+}
+
+func (r *MemoryRoleRepository) ListRolesWithPermission(ctx context.Context, permissionID domain.PermissionID, page repository.PageRequest) (*repository.Page[*domain.Role], error) {
+	t, unlock, err := r.store.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return repository.PageSlice(hydrate(t.roles, sources(t.grants, permissionID), cloneRole), page)
+}
+
+func (r *MemoryRoleRepository) AddParentRole(ctx context.Context, roleID domain.RoleID, parentID domain.RoleID) error {
+	t, unlock, err := r.store.write(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	setEdge(t.parents, roleID, parentID, true)
+	return nil
+}
+
+func (r *MemoryRoleRepository) RemoveParentRole(ctx context.Context, roleID domain.RoleID, parentID domain.RoleID) error {
+	t, unlock, err := r.store.write(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	deleteEdge(t.parents, roleID, parentID)
+	return nil
+}
+
+// GetParentRoles returns the roles the role directly inherits from.
+func (r *MemoryRoleRepository) GetParentRoles(ctx context.Context, roleID domain.RoleID, page repository.PageRequest) (*repository.Page[*domain.Role], error) {
+	t, unlock, err := r.store.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return repository.PageSlice(hydrate(t.roles, sortedKeys(t.parents[roleID]), cloneRole), page)
+}
+
+// GetChildRoles returns the roles that directly inherit from the role.
+func (r *MemoryRoleRepository) GetChildRoles(ctx context.Context, roleID domain.RoleID, page repository.PageRequest) (*repository.Page[*domain.Role], error) {
+	t, unlock, err := r.store.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return repository.PageSlice(hydrate(t.roles, sources(t.parents, roleID), cloneRole), page)
+}
@@ -0,0 +1,316 @@
+package memory
+
+import (
+	"aws-dynamodb-store/internal/domain"
+	"aws-dynamodb-store/internal/repository"
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+type MemoryUserRepository struct {
+	store *store
+}
+
+func cloneUser(user *domain.User) *domain.User {
+	c := *user
+	c.Attributes = cloneStrings(user.Attributes)
+	c.Permissions = clonePermissions(user.Permissions)
+	return &c
+}
+
+// clonePermissions copies permissions as the table stores them: empty sets
+// read back as nil.
+func clonePermissions(p *domain.EffectivePermissions) *domain.EffectivePermissions {
+	if p == nil {
+		return nil
+	}
+	return &domain.EffectivePermissions{
+		Allowed:     clonePermissionIDs(p.Allowed),
+		Denied:      clonePermissionIDs(p.Denied),
+		Conditional: clonePermissionIDs(p.Conditional),
+		ComputedAt:  p.ComputedAt.UTC(),
+		ValidUntil:  cloneTime(p.ValidUntil),
+	}
+}
+
+func clonePermissionIDs(ids []domain.PermissionID) []domain.PermissionID {
+	if len(ids) == 0 {
+		return nil
+	}
+	return slices.Clone(ids)
+}
+
+func cloneAssignment(assignment *domain.RoleAssignment) *domain.RoleAssignment {
+	c := *assignment
+	c.NotBefore = cloneTime(assignment.NotBefore)
+	c.ExpiresAt = cloneTime(assignment.ExpiresAt)
+	return &c
+}
+
+func touchUser(user *domain.User, at time.Time) {
+	user.UpdatedAt = at
+	user.Version++
+}
+
+func (r *MemoryUserRepository) CreateUser(ctx context.Context, user *domain.User) error {
+	t, unlock, err := r.store.write(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, ok := t.users[user.ID]; ok {
+		return repository.ErrAlreadyExists
+	}
+	user.CreatedAt = now()
+	user.UpdatedAt = user.CreatedAt
+	user.Version = 1
+	t.users[user.ID] = &entry[domain.User]{entity: *cloneUser(user)}
+	return nil
+}
+
+func (r *MemoryUserRepository) GetUserByID(ctx context.Context, id domain.UserID) (*domain.User, error) {
+	t, unlock, err := r.store.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	e := t.users[id]
+	if !e.live() {
+		return nil, repository.ErrNotFound
+	}
+	return cloneUser(&e.entity), nil
+}
+
+func (r *MemoryUserRepository) UpdateUser(ctx context.Context, user *domain.User) error {
+	t, unlock, err := r.store.write(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	e := t.users[user.ID]
+	if !e.live() {
+		return repository.ErrNotFound
+	}
+	current := &e.entity
+	if current.Version != user.Version {
+		return repository.ErrConflict
+	}
+
+	if current.DisplayName != user.DisplayName || current.Email != user.Email || !maps.Equal(current.Attributes, user.Attributes) {
+		current.DisplayName = user.DisplayName
+		current.Email = user.Email
+		current.Attributes = cloneStrings(user.Attributes)
+		touchUser(current, now())
+	}
+	*user = *cloneUser(current)
+	return nil
+}
+
+// SetEffectivePermissions stores permissions on the user without touching its
+// Version. It fails with repository.ErrConflict if the user does not exist or
+// permissions computed later are already stored.
+func (r *MemoryUserRepository) SetEffectivePermissions(ctx context.Context, id domain.UserID, permissions *domain.EffectivePermissions) error {
+	t, unlock, err := r.store.write(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	e := t.users[id]
+	if e == nil {
+		return repository.ErrConflict
+	}
+	if stored := e.entity.Permissions; permissions != nil && stored != nil && stored.ComputedAt.After(permissions.ComputedAt) {
+		return repository.ErrConflict
+	}
+	e.entity.Permissions = clonePermissions(permissions)
+	return nil
+}
+
+// DeleteUser removes the user and all of its role assignments and group
+// memberships.
+func (r *MemoryUserRepository) DeleteUser(ctx context.Context, id domain.UserID) error {
+	t, unlock, err := r.store.write(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	_, found := t.users[id]
+	found = found || len(t.userRoles[id]) > 0 || len(t.resourceRoles[id]) > 0 || len(t.memberships[id]) > 0
+	if !found {
+		return repository.ErrNotFound
+	}
+	delete(t.users, id)
+	delete(t.userRoles, id)
+	delete(t.resourceRoles, id)
+	delete(t.memberships, id)
+	return nil
+}
+
+func (r *MemoryUserRepository) SoftDeleteUser(ctx context.Context, id domain.UserID) error {
+	t, unlock, err := r.store.write(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return softDelete(r.store, t.users, id, touchUser)
+}
+
+func (r *MemoryUserRepository) RestoreUser(ctx context.Context, id domain.UserID) error {
+	t, unlock, err := r.store.write(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return restore(t.users, id, touchUser)
+}
+
+func (r *MemoryUserRepository) ListAllUsers(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.User], error) {
+	t, unlock, err := r.store.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return repository.PageSlice(hydrate(t.users, liveIDs(t.users), cloneUser), page)
+}
+
+func (r *MemoryUserRepository) AssignRoleToUser(ctx context.Context, assignment *domain.RoleAssignment) error {
+	t, unlock, err := r.store.write(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	assignment.AssignedAt = now()
+	if assignment.ResourceID == "" {
+		setEdge(t.userRoles, assignment.UserID, assignment.RoleID, cloneAssignment(assignment))
+		return nil
+	}
+	if t.resourceRoles[assignment.UserID] == nil {
+		t.resourceRoles[assignment.UserID] = make(map[string]map[domain.RoleID]*domain.RoleAssignment)
+	}
+	setEdge(t.resourceRoles[assignment.UserID], assignment.ResourceID, assignment.RoleID, cloneAssignment(assignment))
+	return nil
+}
+
+func (r *MemoryUserRepository) RemoveRoleFromUser(ctx context.Context, userID domain.UserID, roleID domain.RoleID) error {
+	t, unlock, err := r.store.write(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	deleteEdge(t.userRoles, userID, roleID)
+	return nil
+}
+
+// RemoveRoleFromUserOnResource removes the assignment of roleID to the user on
+// resourceID. Global assignments of the role are not affected. The resource is
+// no longer listed once its last role is removed.
+func (r *MemoryUserRepository) RemoveRoleFromUserOnResource(ctx context.Context, userID domain.UserID, roleID domain.RoleID, resourceID string) error {
+	t, unlock, err := r.store.write(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	delete(t.resourceRoles[userID][resourceID], roleID)
+	if len(t.resourceRoles[userID][resourceID]) == 0 {
+		delete(t.resourceRoles[userID], resourceID)
+	}
+	return nil
+}
+
+// GetUserRoles returns the roles assigned to the user globally.
+func (r *MemoryUserRepository) GetUserRoles(ctx context.Context, userID domain.UserID, page repository.PageRequest) (*repository.Page[*domain.Role], error) {
+	t, unlock, err := r.store.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return repository.PageSlice(activeRoles(t, t.userRoles[userID]), page)
+}
+
+// GetUserRolesOnResource returns the roles assigned to the user on resourceID
+// only, without its global roles.
+func (r *MemoryUserRepository) GetUserRolesOnResource(ctx context.Context, userID domain.UserID, resourceID string, page repository.PageRequest) (*repository.Page[*domain.Role], error) {
+	t, unlock, err := r.store.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return repository.PageSlice(activeRoles(t, t.resourceRoles[userID][resourceID]), page)
+}
+
+// ListUserResources returns the resources the user has been assigned roles on
+// whose path starts with prefix, in path order.
+func (r *MemoryUserRepository) ListUserResources(ctx context.Context, userID domain.UserID, prefix string, page repository.PageRequest) (*repository.Page[string], error) {
+	t, unlock, err := r.store.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	resources := make([]string, 0)
+	for _, resource := range sortedKeys(t.resourceRoles[userID]) {
+		if strings.HasPrefix(resource, prefix) {
+			resources = append(resources, resource)
+		}
+	}
+	return repository.PageSlice(resources, page)
+}
+
+// activeRoles returns the roles of the assignments that are currently in
+// effect, in role ID order.
+func activeRoles(t *tenant, assignments map[domain.RoleID]*domain.RoleAssignment) []*domain.Role {
+	at := now()
+	var ids []domain.RoleID
+	for _, roleID := range sortedKeys(assignments) {
+		if assignments[roleID].ActiveAt(at) {
+			ids = append(ids, roleID)
+		}
+	}
+	return hydrate(t.roles, ids, cloneRole)
+}
+
+// ListUserAssignments returns the user's global role assignments as stored,
+// including ones outside their NotBefore/ExpiresAt window.
+func (r *MemoryUserRepository) ListUserAssignments(ctx context.Context, userID domain.UserID, page repository.PageRequest) (*repository.Page[*domain.RoleAssignment], error) {
+	t, unlock, err := r.store.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	assignments := make([]*domain.RoleAssignment, 0, len(t.userRoles[userID]))
+	for _, roleID := range sortedKeys(t.userRoles[userID]) {
+		assignments = append(assignments, cloneAssignment(t.userRoles[userID][roleID]))
+	}
+	return repository.PageSlice(assignments, page)
+}
+
+// ListUsersInRole returns the users the role is currently assigned to
+// globally.
+func (r *MemoryUserRepository) ListUsersInRole(ctx context.Context, roleID domain.RoleID, page repository.PageRequest) (*repository.Page[*domain.User], error) {
+	t, unlock, err := r.store.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	at := now()
+	var ids []domain.UserID
+	for _, userID := range sources(t.userRoles, roleID) {
+		if t.userRoles[userID][roleID].ActiveAt(at) {
+			ids = append(ids, userID)
+		}
+	}
+	return repository.PageSlice(hydrate(t.users, ids, cloneUser), page)
+}
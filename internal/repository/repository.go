@@ -4,52 +4,160 @@ import (
 	"aws-dynamodb-store/internal/domain"
 	"context"
 	"errors"
+	"strconv"
 )
 
 var (
 	ErrNotFound      = errors.New("entity not found")
 	ErrAlreadyExists = errors.New("entity already exists")
+	ErrInvalidCursor = errors.New("invalid pagination cursor")
+	ErrConflict      = errors.New("entity was modified concurrently")
+	ErrInvalidTenant = errors.New("missing or invalid tenant")
 	// Add other common repository errors
 )
 
+type tenantContextKey struct{}
+
+// WithTenant scopes every repository call made with the returned context to
+// tenantID. Calls without a tenant fail with ErrInvalidTenant.
+func WithTenant(ctx context.Context, tenantID domain.TenantID) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenantID)
+}
+
+// TenantFromContext returns the tenant set by WithTenant.
+func TenantFromContext(ctx context.Context) (domain.TenantID, bool) {
+	tenantID, ok := ctx.Value(tenantContextKey{}).(domain.TenantID)
+	return tenantID, ok && tenantID != ""
+}
+
+// PageRequest selects a single page of a list. A zero Limit lets the backend
+// pick the page size, an empty Cursor starts from the beginning.
+type PageRequest struct {
+	Limit  int
+	Cursor string
+}
+
+// Page is one page of a list. NextCursor is empty once the last page was read.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// CollectAll follows NextCursor until every page of a list has been read.
+func CollectAll[T any](ctx context.Context, list func(ctx context.Context, page PageRequest) (*Page[T], error)) ([]T, error) {
+	var items []T
+	page := PageRequest{}
+	for {
+		result, err := list(ctx, page)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, result.Items...)
+		if result.NextCursor == "" {
+			return items, nil
+		}
+		page.Cursor = result.NextCursor
+	}
+}
+
+// PageSlice returns one page of items that are already held in memory. The
+// cursor is the offset of the first item of the page.
+func PageSlice[T any](items []T, page PageRequest) (*Page[T], error) {
+	start := 0
+	if page.Cursor != "" {
+		offset, err := strconv.Atoi(page.Cursor)
+		if err != nil || offset < 0 || offset > len(items) {
+			return nil, ErrInvalidCursor
+		}
+		start = offset
+	}
+
+	end := len(items)
+	if page.Limit > 0 && start+page.Limit < end {
+		end = start + page.Limit
+	}
+
+	result := &Page[T]{Items: items[start:end]}
+	if end < len(items) {
+		result.NextCursor = strconv.Itoa(end)
+	}
+	return result, nil
+}
+
 type UserRepository interface {
 	CreateUser(ctx context.Context, user *domain.User) error
 	GetUserByID(ctx context.Context, id domain.UserID) (*domain.User, error)
-	// UpdateUser(ctx context.Context, user *domain.User) error // For user metadata
-	// DeleteUser(ctx context.Context, id domain.UserID) error  // Deletes user and their role assignments
-	ListAllUsers(ctx context.Context) ([]*domain.User, error)
+	UpdateUser(ctx context.Context, user *domain.User) error                                                       // For user metadata
+	SetEffectivePermissions(ctx context.Context, id domain.UserID, permissions *domain.EffectivePermissions) error // nil clears them
+	DeleteUser(ctx context.Context, id domain.UserID) error                                                        // Deletes user and their role assignments
+	SoftDeleteUser(ctx context.Context, id domain.UserID) error                                                    // Hides the user until it is restored or reaped
+	RestoreUser(ctx context.Context, id domain.UserID) error
+	ListAllUsers(ctx context.Context, page PageRequest) (*Page[*domain.User], error)
 
-	AssignRoleToUser(ctx context.Context, userID domain.UserID, roleID domain.RoleID) error
+	AssignRoleToUser(ctx context.Context, assignment *domain.RoleAssignment) error
 	RemoveRoleFromUser(ctx context.Context, userID domain.UserID, roleID domain.RoleID) error
-	GetUserRoles(ctx context.Context, userID domain.UserID) ([]*domain.Role, error)
-	ListUsersInRole(ctx context.Context, roleID domain.RoleID) ([]*domain.User, error)
+	GetUserRoles(ctx context.Context, userID domain.UserID, page PageRequest) (*Page[*domain.Role], error) // Global assignments only
+	RemoveRoleFromUserOnResource(ctx context.Context, userID domain.UserID, roleID domain.RoleID, resourceID string) error
+	GetUserRolesOnResource(ctx context.Context, userID domain.UserID, resourceID string, page PageRequest) (*Page[*domain.Role], error)
+	ListUserResources(ctx context.Context, userID domain.UserID, prefix string, page PageRequest) (*Page[string], error) // Resources the user has roles on, by path prefix
+	ListUserAssignments(ctx context.Context, userID domain.UserID, page PageRequest) (*Page[*domain.RoleAssignment], error)
+	ListUsersInRole(ctx context.Context, roleID domain.RoleID, page PageRequest) (*Page[*domain.User], error)
 }
 
 type RoleRepository interface {
 	CreateRole(ctx context.Context, role *domain.Role) error
 	GetRoleByID(ctx context.Context, id domain.RoleID) (*domain.Role, error)
-	// UpdateRole(ctx context.Context, role *domain.Role) error // For role metadata
-	// DeleteRole(ctx context.Context, id domain.RoleID) error  // Deletes role and its permission assignments, and unassigns from users
+	UpdateRole(ctx context.Context, role *domain.Role) error    // For role metadata
+	DeleteRole(ctx context.Context, id domain.RoleID) error     // Deletes role and its permission assignments, and unassigns from users
+	SoftDeleteRole(ctx context.Context, id domain.RoleID) error // Hides the role until it is restored or reaped
+	RestoreRole(ctx context.Context, id domain.RoleID) error
 
-	AssignPermissionToRole(ctx context.Context, roleID domain.RoleID, permissionID domain.PermissionID) error
-	// RemovePermissionFromRole(ctx context.Context, roleID domain.RoleID, permissionID domain.PermissionID) error
-	GetRolePermissions(ctx context.Context, roleID domain.RoleID) ([]*domain.Permission, error)
-	// ListRolesWithPermission(ctx context.Context, permissionID domain.PermissionID) ([]*domain.Role, error)
-	ListAllRoles(ctx context.Context) ([]*domain.Role, error)
+	AssignPermissionToRole(ctx context.Context, grant *domain.PermissionGrant) error
+	RemovePermissionFromRole(ctx context.Context, roleID domain.RoleID, permissionID domain.PermissionID) error
+	GetRolePermissions(ctx context.Context, roleID domain.RoleID, page PageRequest) (*Page[*domain.Permission], error) // Allowed permissions only
+	ListRoleGrants(ctx context.Context, roleID domain.RoleID, page PageRequest) (*Page[*domain.PermissionGrant], error)
+	ListRolesWithPermission(ctx context.Context, permissionID domain.PermissionID, page PageRequest) (*Page[*domain.Role], error)
+	ListAllRoles(ctx context.Context, page PageRequest) (*Page[*domain.Role], error)
+
+	AddParentRole(ctx context.Context, roleID domain.RoleID, parentID domain.RoleID) error // roleID inherits the permissions of parentID
+	RemoveParentRole(ctx context.Context, roleID domain.RoleID, parentID domain.RoleID) error
+	GetParentRoles(ctx context.Context, roleID domain.RoleID, page PageRequest) (*Page[*domain.Role], error)
+	GetChildRoles(ctx context.Context, roleID domain.RoleID, page PageRequest) (*Page[*domain.Role], error)
 }
 
 type PermissionRepository interface {
 	CreatePermission(ctx context.Context, permission *domain.Permission) error
 	GetPermissionByID(ctx context.Context, id domain.PermissionID) (*domain.Permission, error)
-	// UpdatePermission(ctx context.Context, permission *domain.Permission) error // For permission metadata
-	// DeletePermission(ctx context.Context, id domain.PermissionID) error        // Deletes permission and unassigns from roles
-	ListAllPermissions(ctx context.Context) ([]*domain.Permission, error)
+	UpdatePermission(ctx context.Context, permission *domain.Permission) error // For permission metadata
+	DeletePermission(ctx context.Context, id domain.PermissionID) error        // Deletes permission and unassigns from roles
+	SoftDeletePermission(ctx context.Context, id domain.PermissionID) error    // Hides the permission until it is restored or reaped
+	RestorePermission(ctx context.Context, id domain.PermissionID) error
+	ListAllPermissions(ctx context.Context, page PageRequest) (*Page[*domain.Permission], error)
+}
+
+type GroupRepository interface {
+	CreateGroup(ctx context.Context, group *domain.Group) error
+	GetGroupByID(ctx context.Context, id domain.GroupID) (*domain.Group, error)
+	DeleteGroup(ctx context.Context, id domain.GroupID) error // Deletes group, its memberships and its role assignments
+	ListAllGroups(ctx context.Context, page PageRequest) (*Page[*domain.Group], error)
+
+	AddUserToGroup(ctx context.Context, userID domain.UserID, groupID domain.GroupID) error
+	RemoveUserFromGroup(ctx context.Context, userID domain.UserID, groupID domain.GroupID) error
+	GetUserGroups(ctx context.Context, userID domain.UserID, page PageRequest) (*Page[*domain.Group], error)
+	ListGroupMembers(ctx context.Context, groupID domain.GroupID, page PageRequest) (*Page[*domain.User], error)
+	RecountGroupMembers(ctx context.Context, groupID domain.GroupID) (int, error) // Stores the count as the group's MemberCount
+	ListGroupsWithRole(ctx context.Context, roleID domain.RoleID, page PageRequest) (*Page[*domain.Group], error)
+
+	AssignRoleToGroup(ctx context.Context, groupID domain.GroupID, roleID domain.RoleID) error // Members of groupID hold roleID
+	RemoveRoleFromGroup(ctx context.Context, groupID domain.GroupID, roleID domain.RoleID) error
+	GetGroupRoles(ctx context.Context, groupID domain.GroupID, page PageRequest) (*Page[*domain.Role], error)
 }
 
 type Repository struct {
 	User       UserRepository
 	Role       RoleRepository
 	Permission PermissionRepository
+	Group      GroupRepository
 }
 
 // type Repository interface {
@@ -0,0 +1,733 @@
+// Package repositorytest checks that an implementation of
+// repository.Repository behaves the way the service layer expects, so every
+// backend can be held to the same contract.
+package repositorytest
+
+import (
+	"aws-dynamodb-store/internal/domain"
+	"aws-dynamodb-store/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var tenants atomic.Int64
+
+// Run runs the conformance suite against the repositories returned by
+// newRepository. Every subtest works in a tenant of its own, so newRepository
+// may hand out the same repository, backed by the same table, every time.
+func Run(t *testing.T, newRepository func(t *testing.T) repository.Repository) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, repo repository.Repository, ctx context.Context)
+	}{
+		{"Users", testUsers},
+		{"Roles", testRoles},
+		{"Permissions", testPermissions},
+		{"Groups", testGroups},
+		{"EntityTypes", testEntityTypes},
+		{"SoftDelete", testSoftDelete},
+		{"EffectivePermissions", testEffectivePermissions},
+		{"UserRoles", testUserRoles},
+		{"ResourceRoles", testResourceRoles},
+		{"RoleGrants", testRoleGrants},
+		{"RoleInheritance", testRoleInheritance},
+		{"GroupEdges", testGroupEdges},
+		{"CascadingDeletes", testCascadingDeletes},
+		{"DeleteMissing", testDeleteMissing},
+		{"Pagination", testPagination},
+		{"Tenants", testTenants},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newRepository(t), newTenant(context.Background()))
+		})
+	}
+}
+
+func newTenant(ctx context.Context) context.Context {
+	id := fmt.Sprintf("conformance-%d-%d", time.Now().UnixNano(), tenants.Add(1))
+	return repository.WithTenant(ctx, domain.TenantID(id))
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func expectError(t *testing.T, what string, err error, want error) {
+	t.Helper()
+	if !errors.Is(err, want) {
+		t.Errorf("%s: expected %v; got %v", what, want, err)
+	}
+}
+
+// collect reads every page of a list, checking that no page exceeds limit.
+func collect[T any](t *testing.T, ctx context.Context, limit int, list func(ctx context.Context, page repository.PageRequest) (*repository.Page[T], error)) []T {
+	t.Helper()
+	items, err := repository.CollectAll(ctx, func(ctx context.Context, page repository.PageRequest) (*repository.Page[T], error) {
+		page.Limit = limit
+		result, err := list(ctx, page)
+		if err == nil && limit > 0 && len(result.Items) > limit {
+			t.Errorf("expected at most %d items per page; got %d", limit, len(result.Items))
+		}
+		return result, err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return items
+}
+
+func ids[T any, ID any](items []T, id func(T) ID) []ID {
+	result := make([]ID, 0, len(items))
+	for _, item := range items {
+		result = append(result, id(item))
+	}
+	return result
+}
+
+func userID(user *domain.User) domain.UserID                    { return user.ID }
+func roleID(role *domain.Role) domain.RoleID                    { return role.ID }
+func permissionID(p *domain.Permission) domain.PermissionID     { return p.ID }
+func groupID(group *domain.Group) domain.GroupID                { return group.ID }
+func grantID(grant *domain.PermissionGrant) domain.PermissionID { return grant.PermissionID }
+
+func expectIDs[ID comparable](t *testing.T, what string, got []ID, want ...ID) {
+	t.Helper()
+	if len(got) == 0 && len(want) == 0 {
+		return
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("%s: expected %v; got %v", what, want, got)
+	}
+}
+
+func createUsers(t *testing.T, repo repository.Repository, ctx context.Context, ids ...domain.UserID) {
+	t.Helper()
+	for _, id := range ids {
+		must(t, repo.User.CreateUser(ctx, &domain.User{ID: id, DisplayName: string(id), Email: string(id) + "@example.com"}))
+	}
+}
+
+func createRoles(t *testing.T, repo repository.Repository, ctx context.Context, ids ...domain.RoleID) {
+	t.Helper()
+	for _, id := range ids {
+		must(t, repo.Role.CreateRole(ctx, &domain.Role{ID: id, DisplayName: string(id)}))
+	}
+}
+
+func createPermissions(t *testing.T, repo repository.Repository, ctx context.Context, ids ...domain.PermissionID) {
+	t.Helper()
+	for _, id := range ids {
+		must(t, repo.Permission.CreatePermission(ctx, &domain.Permission{ID: id, DisplayName: string(id)}))
+	}
+}
+
+func createGroups(t *testing.T, repo repository.Repository, ctx context.Context, ids ...domain.GroupID) {
+	t.Helper()
+	for _, id := range ids {
+		must(t, repo.Group.CreateGroup(ctx, &domain.Group{ID: id, DisplayName: string(id)}))
+	}
+}
+
+func testUsers(t *testing.T, repo repository.Repository, ctx context.Context) {
+	user := &domain.User{ID: "u1", DisplayName: "Alice", Email: "alice@example.com", Attributes: map[string]string{"department": "sales"}}
+	must(t, repo.User.CreateUser(ctx, user))
+	if user.Version != 1 || user.CreatedAt.IsZero() {
+		t.Errorf("expected CreateUser to set Version and CreatedAt; got %+v", user)
+	}
+	expectError(t, "CreateUser twice", repo.User.CreateUser(ctx, &domain.User{ID: "u1", DisplayName: "Bob"}), repository.ErrAlreadyExists)
+
+	got, err := repo.User.GetUserByID(ctx, "u1")
+	must(t, err)
+	if got.DisplayName != "Alice" || got.Email != user.Email || got.Attributes["department"] != "sales" || !got.CreatedAt.Equal(user.CreatedAt) || got.Version != 1 {
+		t.Errorf("expected %+v; got %+v", user, got)
+	}
+	_, err = repo.User.GetUserByID(ctx, "missing")
+	expectError(t, "GetUserByID", err, repository.ErrNotFound)
+
+	stale := *got
+	got.DisplayName = "Alice A."
+	must(t, repo.User.UpdateUser(ctx, got))
+	if got.Version != 2 || got.DisplayName != "Alice A." {
+		t.Errorf("expected the update to be applied with Version 2; got %+v", got)
+	}
+	stale.DisplayName = "Alice B."
+	expectError(t, "UpdateUser with a stale Version", repo.User.UpdateUser(ctx, &stale), repository.ErrConflict)
+	expectError(t, "UpdateUser of a missing user", repo.User.UpdateUser(ctx, &domain.User{ID: "missing", Version: 1}), repository.ErrNotFound)
+
+	unchanged := *got
+	must(t, repo.User.UpdateUser(ctx, &unchanged))
+	if unchanged.Version != 2 {
+		t.Errorf("expected an update without changes to keep Version 2; got %d", unchanged.Version)
+	}
+
+	createUsers(t, repo, ctx, "u0", "u2")
+	expectIDs(t, "ListAllUsers", ids(collect(t, ctx, 0, repo.User.ListAllUsers), userID), "u0", "u1", "u2")
+
+	must(t, repo.User.DeleteUser(ctx, "u1"))
+	_, err = repo.User.GetUserByID(ctx, "u1")
+	expectError(t, "GetUserByID after DeleteUser", err, repository.ErrNotFound)
+	expectIDs(t, "ListAllUsers after DeleteUser", ids(collect(t, ctx, 0, repo.User.ListAllUsers), userID), "u0", "u2")
+}
+
+func testRoles(t *testing.T, repo repository.Repository, ctx context.Context) {
+	role := &domain.Role{ID: "editor", DisplayName: "Editor", Description: "Edits documents"}
+	must(t, repo.Role.CreateRole(ctx, role))
+	expectError(t, "CreateRole twice", repo.Role.CreateRole(ctx, &domain.Role{ID: "editor"}), repository.ErrAlreadyExists)
+
+	got, err := repo.Role.GetRoleByID(ctx, "editor")
+	must(t, err)
+	if got.DisplayName != "Editor" || got.Description != "Edits documents" || got.Version != 1 {
+		t.Errorf("expected %+v; got %+v", role, got)
+	}
+	_, err = repo.Role.GetRoleByID(ctx, "missing")
+	expectError(t, "GetRoleByID", err, repository.ErrNotFound)
+
+	stale := *got
+	got.Description = ""
+	must(t, repo.Role.UpdateRole(ctx, got))
+	if got.Version != 2 || got.Description != "" {
+		t.Errorf("expected the description to be cleared with Version 2; got %+v", got)
+	}
+	expectError(t, "UpdateRole with a stale Version", repo.Role.UpdateRole(ctx, &stale), repository.ErrConflict)
+	expectError(t, "UpdateRole of a missing role", repo.Role.UpdateRole(ctx, &domain.Role{ID: "missing", Version: 1}), repository.ErrNotFound)
+
+	createRoles(t, repo, ctx, "admin", "viewer")
+	expectIDs(t, "ListAllRoles", ids(collect(t, ctx, 0, repo.Role.ListAllRoles), roleID), "admin", "editor", "viewer")
+}
+
+func testPermissions(t *testing.T, repo repository.Repository, ctx context.Context) {
+	permission := &domain.Permission{ID: "document:read", DisplayName: "Read", Description: "Reads documents"}
+	must(t, repo.Permission.CreatePermission(ctx, permission))
+	expectError(t, "CreatePermission twice", repo.Permission.CreatePermission(ctx, &domain.Permission{ID: "document:read"}), repository.ErrAlreadyExists)
+
+	got, err := repo.Permission.GetPermissionByID(ctx, "document:read")
+	must(t, err)
+	if got.DisplayName != "Read" || got.Description != "Reads documents" || got.Version != 1 {
+		t.Errorf("expected %+v; got %+v", permission, got)
+	}
+	_, err = repo.Permission.GetPermissionByID(ctx, "missing")
+	expectError(t, "GetPermissionByID", err, repository.ErrNotFound)
+
+	stale := *got
+	got.DisplayName = "Read documents"
+	must(t, repo.Permission.UpdatePermission(ctx, got))
+	if got.Version != 2 {
+		t.Errorf("expected Version 2; got %d", got.Version)
+	}
+	expectError(t, "UpdatePermission with a stale Version", repo.Permission.UpdatePermission(ctx, &stale), repository.ErrConflict)
+	expectError(t, "UpdatePermission of a missing permission", repo.Permission.UpdatePermission(ctx, &domain.Permission{ID: "missing", Version: 1}), repository.ErrNotFound)
+
+	createPermissions(t, repo, ctx, "document:*", "document:write")
+	expectIDs(t, "ListAllPermissions", ids(collect(t, ctx, 0, repo.Permission.ListAllPermissions), permissionID), "document:*", "document:read", "document:write")
+}
+
+func testGroups(t *testing.T, repo repository.Repository, ctx context.Context) {
+	group := &domain.Group{ID: "writers", DisplayName: "Writers", Description: "Write things"}
+	must(t, repo.Group.CreateGroup(ctx, group))
+	expectError(t, "CreateGroup twice", repo.Group.CreateGroup(ctx, &domain.Group{ID: "writers"}), repository.ErrAlreadyExists)
+
+	got, err := repo.Group.GetGroupByID(ctx, "writers")
+	must(t, err)
+	if got.DisplayName != "Writers" || got.Description != "Write things" || got.MemberCount != 0 {
+		t.Errorf("expected %+v; got %+v", group, got)
+	}
+	_, err = repo.Group.GetGroupByID(ctx, "missing")
+	expectError(t, "GetGroupByID", err, repository.ErrNotFound)
+
+	createGroups(t, repo, ctx, "readers")
+	expectIDs(t, "ListAllGroups", ids(collect(t, ctx, 0, repo.Group.ListAllGroups), groupID), "readers", "writers")
+}
+
+// testEntityTypes stores entities of every kind under the same ID, so a
+// backend that files one kind under another's type lists or returns the wrong
+// entity.
+func testEntityTypes(t *testing.T, repo repository.Repository, ctx context.Context) {
+	must(t, repo.User.CreateUser(ctx, &domain.User{ID: "shared", DisplayName: "user"}))
+	must(t, repo.Role.CreateRole(ctx, &domain.Role{ID: "shared", DisplayName: "role"}))
+	must(t, repo.Permission.CreatePermission(ctx, &domain.Permission{ID: "shared", DisplayName: "permission"}))
+	must(t, repo.Group.CreateGroup(ctx, &domain.Group{ID: "shared", DisplayName: "group"}))
+
+	user, err := repo.User.GetUserByID(ctx, "shared")
+	must(t, err)
+	role, err := repo.Role.GetRoleByID(ctx, "shared")
+	must(t, err)
+	permission, err := repo.Permission.GetPermissionByID(ctx, "shared")
+	must(t, err)
+	group, err := repo.Group.GetGroupByID(ctx, "shared")
+	must(t, err)
+	if user.DisplayName != "user" || role.DisplayName != "role" || permission.DisplayName != "permission" || group.DisplayName != "group" {
+		t.Errorf("expected each kind to keep its own item; got %q, %q, %q, %q", user.DisplayName, role.DisplayName, permission.DisplayName, group.DisplayName)
+	}
+
+	users := collect(t, ctx, 0, repo.User.ListAllUsers)
+	roles := collect(t, ctx, 0, repo.Role.ListAllRoles)
+	permissions := collect(t, ctx, 0, repo.Permission.ListAllPermissions)
+	groups := collect(t, ctx, 0, repo.Group.ListAllGroups)
+	if len(users) != 1 || users[0].DisplayName != "user" {
+		t.Errorf("expected ListAllUsers to return the user only; got %+v", users)
+	}
+	if len(roles) != 1 || roles[0].DisplayName != "role" {
+		t.Errorf("expected ListAllRoles to return the role only; got %+v", roles)
+	}
+	if len(permissions) != 1 || permissions[0].DisplayName != "permission" {
+		t.Errorf("expected ListAllPermissions to return the permission only; got %+v", permissions)
+	}
+	if len(groups) != 1 || groups[0].DisplayName != "group" {
+		t.Errorf("expected ListAllGroups to return the group only; got %+v", groups)
+	}
+
+	must(t, repo.Role.DeleteRole(ctx, "shared"))
+	if _, err := repo.Permission.GetPermissionByID(ctx, "shared"); err != nil {
+		t.Errorf("expected deleting the role to leave the permission alone; got %v", err)
+	}
+}
+
+func testSoftDelete(t *testing.T, repo repository.Repository, ctx context.Context) {
+	createUsers(t, repo, ctx, "u1", "u2")
+	createRoles(t, repo, ctx, "editor")
+	createPermissions(t, repo, ctx, "document:read")
+	must(t, repo.User.AssignRoleToUser(ctx, &domain.RoleAssignment{UserID: "u1", RoleID: "editor"}))
+	must(t, repo.Role.AssignPermissionToRole(ctx, &domain.PermissionGrant{RoleID: "editor", PermissionID: "document:read"}))
+
+	must(t, repo.User.SoftDeleteUser(ctx, "u1"))
+	must(t, repo.Role.SoftDeleteRole(ctx, "editor"))
+	must(t, repo.Permission.SoftDeletePermission(ctx, "document:read"))
+
+	_, err := repo.User.GetUserByID(ctx, "u1")
+	expectError(t, "GetUserByID of a soft-deleted user", err, repository.ErrNotFound)
+	_, err = repo.Role.GetRoleByID(ctx, "editor")
+	expectError(t, "GetRoleByID of a soft-deleted role", err, repository.ErrNotFound)
+	_, err = repo.Permission.GetPermissionByID(ctx, "document:read")
+	expectError(t, "GetPermissionByID of a soft-deleted permission", err, repository.ErrNotFound)
+
+	expectIDs(t, "ListAllUsers", ids(collect(t, ctx, 0, repo.User.ListAllUsers), userID), "u2")
+	expectIDs(t, "ListAllRoles", ids(collect(t, ctx, 0, repo.Role.ListAllRoles), roleID))
+	expectIDs(t, "ListAllPermissions", ids(collect(t, ctx, 0, repo.Permission.ListAllPermissions), permissionID))
+	expectIDs(t, "ListUsersInRole", ids(collect(t, ctx, 0, func(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.User], error) {
+		return repo.User.ListUsersInRole(ctx, "editor", page)
+	}), userID))
+
+	expectError(t, "SoftDeleteUser twice", repo.User.SoftDeleteUser(ctx, "u1"), repository.ErrNotFound)
+	expectError(t, "CreateUser over a soft-deleted user", repo.User.CreateUser(ctx, &domain.User{ID: "u1"}), repository.ErrAlreadyExists)
+	expectError(t, "UpdateUser of a soft-deleted user", repo.User.UpdateUser(ctx, &domain.User{ID: "u1", Version: 2}), repository.ErrNotFound)
+
+	must(t, repo.User.RestoreUser(ctx, "u1"))
+	must(t, repo.Role.RestoreRole(ctx, "editor"))
+	must(t, repo.Permission.RestorePermission(ctx, "document:read"))
+	expectError(t, "RestoreUser twice", repo.User.RestoreUser(ctx, "u1"), repository.ErrNotFound)
+	expectError(t, "RestoreRole of a missing role", repo.Role.RestoreRole(ctx, "missing"), repository.ErrNotFound)
+
+	// Edges survive, so restored entities come back as they were.
+	user, err := repo.User.GetUserByID(ctx, "u1")
+	must(t, err)
+	if user.Version != 3 {
+		t.Errorf("expected soft delete and restore to bump Version to 3; got %d", user.Version)
+	}
+	expectIDs(t, "GetUserRoles", ids(collect(t, ctx, 0, func(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.Role], error) {
+		return repo.User.GetUserRoles(ctx, "u1", page)
+	}), roleID), "editor")
+	expectIDs(t, "GetRolePermissions", ids(collect(t, ctx, 0, func(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.Permission], error) {
+		return repo.Role.GetRolePermissions(ctx, "editor", page)
+	}), permissionID), "document:read")
+}
+
+func testEffectivePermissions(t *testing.T, repo repository.Repository, ctx context.Context) {
+	createUsers(t, repo, ctx, "u1")
+	computedAt := time.Now().UTC()
+	validUntil := computedAt.Add(time.Hour)
+	permissions := &domain.EffectivePermissions{
+		Allowed:    []domain.PermissionID{"document:read", "document:write"},
+		Denied:     []domain.PermissionID{"document:delete"},
+		ComputedAt: computedAt,
+		ValidUntil: &validUntil,
+	}
+	must(t, repo.User.SetEffectivePermissions(ctx, "u1", permissions))
+
+	user, err := repo.User.GetUserByID(ctx, "u1")
+	must(t, err)
+	got := user.Permissions
+	if got == nil {
+		t.Fatal("expected effective permissions to be stored")
+	}
+	slices.Sort(got.Allowed)
+	if !slices.Equal(got.Allowed, permissions.Allowed) || !slices.Equal(got.Denied, permissions.Denied) || got.Conditional != nil {
+		t.Errorf("expected %+v; got %+v", permissions, got)
+	}
+	if !got.ComputedAt.Equal(computedAt) || got.ValidUntil == nil || !got.ValidUntil.Equal(validUntil) {
+		t.Errorf("expected computed at %v, valid until %v; got %v, %v", computedAt, validUntil, got.ComputedAt, got.ValidUntil)
+	}
+	if user.Version != 1 {
+		t.Errorf("expected SetEffectivePermissions to leave Version alone; got %d", user.Version)
+	}
+
+	older := &domain.EffectivePermissions{ComputedAt: computedAt.Add(-time.Second)}
+	expectError(t, "SetEffectivePermissions computed earlier", repo.User.SetEffectivePermissions(ctx, "u1", older), repository.ErrConflict)
+	expectError(t, "SetEffectivePermissions of a missing user", repo.User.SetEffectivePermissions(ctx, "missing", permissions), repository.ErrConflict)
+
+	must(t, repo.User.SetEffectivePermissions(ctx, "u1", nil))
+	user, err = repo.User.GetUserByID(ctx, "u1")
+	must(t, err)
+	if user.Permissions != nil {
+		t.Errorf("expected nil to clear effective permissions; got %+v", user.Permissions)
+	}
+}
+
+func testUserRoles(t *testing.T, repo repository.Repository, ctx context.Context) {
+	createUsers(t, repo, ctx, "u1", "u2")
+	createRoles(t, repo, ctx, "admin", "editor", "viewer")
+	past := time.Now().UTC().Add(-time.Hour)
+	future := time.Now().UTC().Add(time.Hour)
+	must(t, repo.User.AssignRoleToUser(ctx, &domain.RoleAssignment{UserID: "u1", RoleID: "editor", AssignedBy: "u2", Reason: "onboarding", TicketRef: "T-1"}))
+	must(t, repo.User.AssignRoleToUser(ctx, &domain.RoleAssignment{UserID: "u1", RoleID: "viewer"}))
+	must(t, repo.User.AssignRoleToUser(ctx, &domain.RoleAssignment{UserID: "u1", RoleID: "admin", NotBefore: &future}))
+	must(t, repo.User.AssignRoleToUser(ctx, &domain.RoleAssignment{UserID: "u2", RoleID: "editor", ExpiresAt: &past}))
+
+	userRoles := func(userID domain.UserID) []domain.RoleID {
+		return ids(collect(t, ctx, 0, func(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.Role], error) {
+			return repo.User.GetUserRoles(ctx, userID, page)
+		}), roleID)
+	}
+	usersInRole := func(roleID domain.RoleID) []domain.UserID {
+		return ids(collect(t, ctx, 0, func(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.User], error) {
+			return repo.User.ListUsersInRole(ctx, roleID, page)
+		}), userID)
+	}
+
+	expectIDs(t, "GetUserRoles", userRoles("u1"), "editor", "viewer")
+	expectIDs(t, "GetUserRoles with an expired assignment", userRoles("u2"))
+	expectIDs(t, "ListUsersInRole", usersInRole("editor"), "u1")
+	expectIDs(t, "ListUsersInRole before NotBefore", usersInRole("admin"))
+
+	assignments := collect(t, ctx, 0, func(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.RoleAssignment], error) {
+		return repo.User.ListUserAssignments(ctx, "u1", page)
+	})
+	expectIDs(t, "ListUserAssignments", ids(assignments, func(a *domain.RoleAssignment) domain.RoleID { return a.RoleID }), "admin", "editor", "viewer")
+	for _, a := range assignments {
+		if a.UserID != "u1" || a.AssignedAt.IsZero() {
+			t.Errorf("expected the assignment to name its user and time; got %+v", a)
+		}
+		if a.RoleID == "editor" && (a.AssignedBy != "u2" || a.Reason != "onboarding" || a.TicketRef != "T-1") {
+			t.Errorf("expected the audit fields to be kept; got %+v", a)
+		}
+		if a.RoleID == "admin" && (a.NotBefore == nil || !a.NotBefore.Equal(future)) {
+			t.Errorf("expected NotBefore %v; got %v", future, a.NotBefore)
+		}
+	}
+
+	// Assigning again replaces the assignment.
+	must(t, repo.User.AssignRoleToUser(ctx, &domain.RoleAssignment{UserID: "u2", RoleID: "editor"}))
+	expectIDs(t, "GetUserRoles after reassigning", userRoles("u2"), "editor")
+
+	must(t, repo.User.RemoveRoleFromUser(ctx, "u1", "editor"))
+	must(t, repo.User.RemoveRoleFromUser(ctx, "u1", "missing"))
+	expectIDs(t, "GetUserRoles after RemoveRoleFromUser", userRoles("u1"), "viewer")
+	expectIDs(t, "ListUsersInRole after RemoveRoleFromUser", usersInRole("editor"), "u2")
+}
+
+func testResourceRoles(t *testing.T, repo repository.Repository, ctx context.Context) {
+	createUsers(t, repo, ctx, "u1")
+	createRoles(t, repo, ctx, "editor", "owner")
+	must(t, repo.User.AssignRoleToUser(ctx, &domain.RoleAssignment{UserID: "u1", RoleID: "owner", ResourceID: "org/acme"}))
+	must(t, repo.User.AssignRoleToUser(ctx, &domain.RoleAssignment{UserID: "u1", RoleID: "editor", ResourceID: "org/acme/project/42"}))
+	must(t, repo.User.AssignRoleToUser(ctx, &domain.RoleAssignment{UserID: "u1", RoleID: "owner", ResourceID: "org/other"}))
+
+	rolesOn := func(resourceID string) []domain.RoleID {
+		return ids(collect(t, ctx, 0, func(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.Role], error) {
+			return repo.User.GetUserRolesOnResource(ctx, "u1", resourceID, page)
+		}), roleID)
+	}
+	resources := func(prefix string) []string {
+		return collect(t, ctx, 0, func(ctx context.Context, page repository.PageRequest) (*repository.Page[string], error) {
+			return repo.User.ListUserResources(ctx, "u1", prefix, page)
+		})
+	}
+
+	expectIDs(t, "GetUserRolesOnResource", rolesOn("org/acme/project/42"), "editor")
+	expectIDs(t, "GetUserRolesOnResource of a resource without roles", rolesOn("org/acme/project"))
+	expectIDs(t, "ListUserResources", resources("org/acme"), "org/acme", "org/acme/project/42")
+	expectIDs(t, "ListUserResources of every path", resources(""), "org/acme", "org/acme/project/42", "org/other")
+
+	// Resource-scoped roles are neither global roles nor role memberships.
+	expectIDs(t, "GetUserRoles", ids(collect(t, ctx, 0, func(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.Role], error) {
+		return repo.User.GetUserRoles(ctx, "u1", page)
+	}), roleID))
+	expectIDs(t, "ListUsersInRole", ids(collect(t, ctx, 0, func(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.User], error) {
+		return repo.User.ListUsersInRole(ctx, "owner", page)
+	}), userID))
+
+	// The resource stays listed while the user holds another role on it.
+	must(t, repo.User.AssignRoleToUser(ctx, &domain.RoleAssignment{UserID: "u1", RoleID: "editor", ResourceID: "org/acme"}))
+	must(t, repo.User.RemoveRoleFromUserOnResource(ctx, "u1", "owner", "org/acme"))
+	expectIDs(t, "GetUserRolesOnResource after removing a role", rolesOn("org/acme"), "editor")
+	expectIDs(t, "ListUserResources after removing a role", resources(""), "org/acme", "org/acme/project/42", "org/other")
+
+	must(t, repo.User.RemoveRoleFromUserOnResource(ctx, "u1", "editor", "org/acme"))
+	expectIDs(t, "GetUserRolesOnResource after removing the last role", rolesOn("org/acme"))
+	expectIDs(t, "GetUserRolesOnResource of another resource", rolesOn("org/other"), "owner")
+	expectIDs(t, "ListUserResources after removing the last role", resources(""), "org/acme/project/42", "org/other")
+}
+
+func testRoleGrants(t *testing.T, repo repository.Repository, ctx context.Context) {
+	createRoles(t, repo, ctx, "editor", "support")
+	createPermissions(t, repo, ctx, "account:*:read", "account:finance:read", "document:read")
+	must(t, repo.Role.AssignPermissionToRole(ctx, &domain.PermissionGrant{RoleID: "support", PermissionID: "account:*:read"}))
+	must(t, repo.Role.AssignPermissionToRole(ctx, &domain.PermissionGrant{RoleID: "support", PermissionID: "account:finance:read", Effect: domain.EffectDeny}))
+	must(t, repo.Role.AssignPermissionToRole(ctx, &domain.PermissionGrant{RoleID: "support", PermissionID: "document:read", Effect: domain.EffectAllow, Condition: `request.ip in "10.0.0.0/8"`}))
+	must(t, repo.Role.AssignPermissionToRole(ctx, &domain.PermissionGrant{RoleID: "editor", PermissionID: "document:read"}))
+
+	grants := collect(t, ctx, 0, func(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.PermissionGrant], error) {
+		return repo.Role.ListRoleGrants(ctx, "support", page)
+	})
+	expectIDs(t, "ListRoleGrants", ids(grants, grantID), "account:*:read", "account:finance:read", "document:read")
+	for _, grant := range grants {
+		want := domain.EffectAllow
+		if grant.PermissionID == "account:finance:read" {
+			want = domain.EffectDeny
+		}
+		if grant.RoleID != "support" || grant.Effect != want || grant.AssignedAt.IsZero() {
+			t.Errorf("expected a %s grant of support; got %+v", want, grant)
+		}
+		if grant.PermissionID == "document:read" && grant.Condition != `request.ip in "10.0.0.0/8"` {
+			t.Errorf("expected the condition to be kept; got %q", grant.Condition)
+		}
+	}
+
+	expectIDs(t, "GetRolePermissions", ids(collect(t, ctx, 0, func(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.Permission], error) {
+		return repo.Role.GetRolePermissions(ctx, "support", page)
+	}), permissionID), "account:*:read", "document:read")
+
+	rolesWith := func(permissionID domain.PermissionID) []domain.RoleID {
+		return ids(collect(t, ctx, 0, func(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.Role], error) {
+			return repo.Role.ListRolesWithPermission(ctx, permissionID, page)
+		}), roleID)
+	}
+	expectIDs(t, "ListRolesWithPermission", rolesWith("document:read"), "editor", "support")
+
+	must(t, repo.Role.RemovePermissionFromRole(ctx, "editor", "document:read"))
+	must(t, repo.Role.RemovePermissionFromRole(ctx, "editor", "missing"))
+	expectIDs(t, "ListRolesWithPermission after RemovePermissionFromRole", rolesWith("document:read"), "support")
+
+	// Grants of soft-deleted permissions are hidden until they are restored.
+	must(t, repo.Permission.SoftDeletePermission(ctx, "account:*:read"))
+	expectIDs(t, "ListRoleGrants with a soft-deleted permission", ids(collect(t, ctx, 0, func(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.PermissionGrant], error) {
+		return repo.Role.ListRoleGrants(ctx, "support", page)
+	}), grantID), "account:finance:read", "document:read")
+}
+
+func testRoleInheritance(t *testing.T, repo repository.Repository, ctx context.Context) {
+	createRoles(t, repo, ctx, "admin", "editor", "viewer")
+	must(t, repo.Role.AddParentRole(ctx, "viewer", "editor"))
+	must(t, repo.Role.AddParentRole(ctx, "viewer", "admin"))
+	must(t, repo.Role.AddParentRole(ctx, "editor", "admin"))
+	must(t, repo.Role.AddParentRole(ctx, "editor", "admin"))
+
+	parents := func(id domain.RoleID) []domain.RoleID {
+		return ids(collect(t, ctx, 0, func(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.Role], error) {
+			return repo.Role.GetParentRoles(ctx, id, page)
+		}), roleID)
+	}
+	children := func(id domain.RoleID) []domain.RoleID {
+		return ids(collect(t, ctx, 0, func(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.Role], error) {
+			return repo.Role.GetChildRoles(ctx, id, page)
+		}), roleID)
+	}
+
+	expectIDs(t, "GetParentRoles", parents("viewer"), "admin", "editor")
+	expectIDs(t, "GetChildRoles", children("admin"), "editor", "viewer")
+	expectIDs(t, "GetParentRoles of a root role", parents("admin"))
+
+	must(t, repo.Role.RemoveParentRole(ctx, "viewer", "admin"))
+	expectIDs(t, "GetParentRoles after RemoveParentRole", parents("viewer"), "editor")
+	expectIDs(t, "GetChildRoles after RemoveParentRole", children("admin"), "editor")
+}
+
+func testGroupEdges(t *testing.T, repo repository.Repository, ctx context.Context) {
+	createUsers(t, repo, ctx, "u1", "u2")
+	createRoles(t, repo, ctx, "editor", "viewer")
+	createGroups(t, repo, ctx, "readers", "writers")
+	must(t, repo.Group.AddUserToGroup(ctx, "u1", "writers"))
+	must(t, repo.Group.AddUserToGroup(ctx, "u2", "writers"))
+	must(t, repo.Group.AddUserToGroup(ctx, "u1", "readers"))
+	must(t, repo.Group.AssignRoleToGroup(ctx, "writers", "editor"))
+	must(t, repo.Group.AssignRoleToGroup(ctx, "writers", "viewer"))
+	must(t, repo.Group.AssignRoleToGroup(ctx, "readers", "viewer"))
+
+	members := func(groupID domain.GroupID) []domain.UserID {
+		return ids(collect(t, ctx, 0, func(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.User], error) {
+			return repo.Group.ListGroupMembers(ctx, groupID, page)
+		}), userID)
+	}
+	groupsWith := func(roleID domain.RoleID) []domain.GroupID {
+		return ids(collect(t, ctx, 0, func(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.Group], error) {
+			return repo.Group.ListGroupsWithRole(ctx, roleID, page)
+		}), groupID)
+	}
+
+	expectIDs(t, "ListGroupMembers", members("writers"), "u1", "u2")
+	expectIDs(t, "GetUserGroups", ids(collect(t, ctx, 0, func(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.Group], error) {
+		return repo.Group.GetUserGroups(ctx, "u1", page)
+	}), groupID), "readers", "writers")
+	expectIDs(t, "GetGroupRoles", ids(collect(t, ctx, 0, func(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.Role], error) {
+		return repo.Group.GetGroupRoles(ctx, "writers", page)
+	}), roleID), "editor", "viewer")
+	expectIDs(t, "ListGroupsWithRole", groupsWith("viewer"), "readers", "writers")
+
+	count, err := repo.Group.RecountGroupMembers(ctx, "writers")
+	must(t, err)
+	group, err := repo.Group.GetGroupByID(ctx, "writers")
+	must(t, err)
+	if count != 2 || group.MemberCount != 2 {
+		t.Errorf("expected 2 members to be counted and stored; got %d and %d", count, group.MemberCount)
+	}
+
+	must(t, repo.Group.RemoveUserFromGroup(ctx, "u2", "writers"))
+	must(t, repo.Group.RemoveRoleFromGroup(ctx, "readers", "viewer"))
+	expectIDs(t, "ListGroupMembers after RemoveUserFromGroup", members("writers"), "u1")
+	expectIDs(t, "ListGroupsWithRole after RemoveRoleFromGroup", groupsWith("viewer"), "writers")
+	if count, err := repo.Group.RecountGroupMembers(ctx, "writers"); err != nil || count != 1 {
+		t.Errorf("expected 1 member; got %d, %v", count, err)
+	}
+}
+
+func testCascadingDeletes(t *testing.T, repo repository.Repository, ctx context.Context) {
+	createUsers(t, repo, ctx, "u1", "u2")
+	createRoles(t, repo, ctx, "admin", "editor", "viewer")
+	createPermissions(t, repo, ctx, "document:read", "document:write")
+	createGroups(t, repo, ctx, "writers")
+	must(t, repo.User.AssignRoleToUser(ctx, &domain.RoleAssignment{UserID: "u1", RoleID: "editor"}))
+	must(t, repo.User.AssignRoleToUser(ctx, &domain.RoleAssignment{UserID: "u1", RoleID: "viewer"}))
+	must(t, repo.User.AssignRoleToUser(ctx, &domain.RoleAssignment{UserID: "u2", RoleID: "editor", ResourceID: "org/acme"}))
+	must(t, repo.Role.AssignPermissionToRole(ctx, &domain.PermissionGrant{RoleID: "editor", PermissionID: "document:write"}))
+	must(t, repo.Role.AssignPermissionToRole(ctx, &domain.PermissionGrant{RoleID: "viewer", PermissionID: "document:read"}))
+	must(t, repo.Role.AssignPermissionToRole(ctx, &domain.PermissionGrant{RoleID: "admin", PermissionID: "document:read"}))
+	must(t, repo.Role.AddParentRole(ctx, "viewer", "editor"))
+	must(t, repo.Role.AddParentRole(ctx, "editor", "admin"))
+	must(t, repo.Group.AssignRoleToGroup(ctx, "writers", "editor"))
+	must(t, repo.Group.AddUserToGroup(ctx, "u1", "writers"))
+
+	must(t, repo.Role.DeleteRole(ctx, "editor"))
+	_, err := repo.Role.GetRoleByID(ctx, "editor")
+	expectError(t, "GetRoleByID after DeleteRole", err, repository.ErrNotFound)
+	expectIDs(t, "GetUserRoles after DeleteRole", ids(collect(t, ctx, 0, func(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.Role], error) {
+		return repo.User.GetUserRoles(ctx, "u1", page)
+	}), roleID), "viewer")
+	expectIDs(t, "GetUserRolesOnResource after DeleteRole", ids(collect(t, ctx, 0, func(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.Role], error) {
+		return repo.User.GetUserRolesOnResource(ctx, "u2", "org/acme", page)
+	}), roleID))
+	expectIDs(t, "ListRolesWithPermission after DeleteRole", ids(collect(t, ctx, 0, func(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.Role], error) {
+		return repo.Role.ListRolesWithPermission(ctx, "document:write", page)
+	}), roleID))
+	expectIDs(t, "GetParentRoles after DeleteRole", ids(collect(t, ctx, 0, func(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.Role], error) {
+		return repo.Role.GetParentRoles(ctx, "viewer", page)
+	}), roleID))
+	expectIDs(t, "GetChildRoles after DeleteRole", ids(collect(t, ctx, 0, func(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.Role], error) {
+		return repo.Role.GetChildRoles(ctx, "admin", page)
+	}), roleID))
+	expectIDs(t, "ListGroupsWithRole after DeleteRole", ids(collect(t, ctx, 0, func(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.Group], error) {
+		return repo.Group.ListGroupsWithRole(ctx, "editor", page)
+	}), groupID))
+
+	must(t, repo.Permission.DeletePermission(ctx, "document:read"))
+	expectIDs(t, "ListRolesWithPermission after DeletePermission", ids(collect(t, ctx, 0, func(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.Role], error) {
+		return repo.Role.ListRolesWithPermission(ctx, "document:read", page)
+	}), roleID))
+
+	must(t, repo.User.DeleteUser(ctx, "u1"))
+	expectIDs(t, "ListUsersInRole after DeleteUser", ids(collect(t, ctx, 0, func(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.User], error) {
+		return repo.User.ListUsersInRole(ctx, "viewer", page)
+	}), userID))
+	expectIDs(t, "ListGroupMembers after DeleteUser", ids(collect(t, ctx, 0, func(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.User], error) {
+		return repo.Group.ListGroupMembers(ctx, "writers", page)
+	}), userID))
+
+	must(t, repo.Group.DeleteGroup(ctx, "writers"))
+	_, err = repo.Group.GetGroupByID(ctx, "writers")
+	expectError(t, "GetGroupByID after DeleteGroup", err, repository.ErrNotFound)
+}
+
+func testDeleteMissing(t *testing.T, repo repository.Repository, ctx context.Context) {
+	expectError(t, "DeleteUser", repo.User.DeleteUser(ctx, "missing"), repository.ErrNotFound)
+	expectError(t, "DeleteRole", repo.Role.DeleteRole(ctx, "missing"), repository.ErrNotFound)
+	expectError(t, "DeletePermission", repo.Permission.DeletePermission(ctx, "missing"), repository.ErrNotFound)
+	expectError(t, "DeleteGroup", repo.Group.DeleteGroup(ctx, "missing"), repository.ErrNotFound)
+	expectError(t, "SoftDeleteUser", repo.User.SoftDeleteUser(ctx, "missing"), repository.ErrNotFound)
+	expectError(t, "SoftDeleteRole", repo.Role.SoftDeleteRole(ctx, "missing"), repository.ErrNotFound)
+	expectError(t, "SoftDeletePermission", repo.Permission.SoftDeletePermission(ctx, "missing"), repository.ErrNotFound)
+	_, err := repo.Group.RecountGroupMembers(ctx, "missing")
+	expectError(t, "RecountGroupMembers", err, repository.ErrNotFound)
+
+	// Deleting twice fails the second time.
+	createUsers(t, repo, ctx, "u1")
+	must(t, repo.User.DeleteUser(ctx, "u1"))
+	expectError(t, "DeleteUser twice", repo.User.DeleteUser(ctx, "u1"), repository.ErrNotFound)
+
+	// Edges left behind by an entity that no longer exists are still cleaned up.
+	must(t, repo.User.AssignRoleToUser(ctx, &domain.RoleAssignment{UserID: "ghost", RoleID: "missing-role"}))
+	must(t, repo.Role.DeleteRole(ctx, "missing-role"))
+	expectError(t, "DeleteRole after its edges were removed", repo.Role.DeleteRole(ctx, "missing-role"), repository.ErrNotFound)
+}
+
+func testPagination(t *testing.T, repo repository.Repository, ctx context.Context) {
+	roles := []domain.RoleID{"r1", "r2", "r3", "r4", "r5"}
+	createRoles(t, repo, ctx, roles...)
+	createUsers(t, repo, ctx, "u1")
+	for _, id := range roles {
+		must(t, repo.User.AssignRoleToUser(ctx, &domain.RoleAssignment{UserID: "u1", RoleID: id}))
+	}
+
+	for _, limit := range []int{1, 2, 5, 10} {
+		expectIDs(t, fmt.Sprintf("ListAllRoles by %d", limit), ids(collect(t, ctx, limit, repo.Role.ListAllRoles), roleID), roles...)
+		expectIDs(t, fmt.Sprintf("GetUserRoles by %d", limit), ids(collect(t, ctx, limit, func(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.Role], error) {
+			return repo.User.GetUserRoles(ctx, "u1", page)
+		}), roleID), roles...)
+	}
+
+	first, err := repo.Role.ListAllRoles(ctx, repository.PageRequest{Limit: 2})
+	must(t, err)
+	if first.NextCursor == "" {
+		t.Error("expected a cursor for the next page")
+	}
+	_, err = repo.Role.ListAllRoles(ctx, repository.PageRequest{Cursor: "!not a cursor!"})
+	expectError(t, "ListAllRoles with an invalid cursor", err, repository.ErrInvalidCursor)
+
+	all, err := repo.Role.ListAllRoles(ctx, repository.PageRequest{Limit: 10})
+	must(t, err)
+	if len(all.Items) != len(roles) {
+		t.Errorf("expected %d roles on one page; got %d", len(roles), len(all.Items))
+	}
+}
+
+func testTenants(t *testing.T, repo repository.Repository, ctx context.Context) {
+	other := newTenant(context.Background())
+	createUsers(t, repo, ctx, "u1")
+	createRoles(t, repo, ctx, "editor")
+	must(t, repo.User.AssignRoleToUser(ctx, &domain.RoleAssignment{UserID: "u1", RoleID: "editor"}))
+
+	_, err := repo.User.GetUserByID(other, "u1")
+	expectError(t, "GetUserByID in another tenant", err, repository.ErrNotFound)
+	expectIDs(t, "ListAllUsers in another tenant", ids(collect(t, other, 0, repo.User.ListAllUsers), userID))
+	expectIDs(t, "ListUsersInRole in another tenant", ids(collect(t, other, 0, func(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.User], error) {
+		return repo.User.ListUsersInRole(ctx, "editor", page)
+	}), userID))
+	expectError(t, "DeleteUser in another tenant", repo.User.DeleteUser(other, "u1"), repository.ErrNotFound)
+
+	// The same IDs can be used by every tenant.
+	createUsers(t, repo, other, "u1")
+
+	for _, tenantID := range []domain.TenantID{"", "a#b"} {
+		ctx := repository.WithTenant(context.Background(), tenantID)
+		_, err := repo.User.GetUserByID(ctx, "u1")
+		expectError(t, fmt.Sprintf("GetUserByID in tenant %q", tenantID), err, repository.ErrInvalidTenant)
+	}
+	_, err = repo.User.GetUserByID(context.Background(), "u1")
+	expectError(t, "GetUserByID without a tenant", err, repository.ErrInvalidTenant)
+}
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"aws-dynamodb-store/internal/repository"
+	"aws-dynamodb-store/internal/service"
+	"errors"
+	"net/http"
+)
+
+// writeServiceError maps repository errors returned through the service to a
+// response. Anything unexpected is reported with message as a server error.
+func writeServiceError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		writeJSONError(w, "Not found", http.StatusNotFound)
+	case errors.Is(err, repository.ErrAlreadyExists):
+		writeJSONError(w, "Already exists", http.StatusConflict)
+	case errors.Is(err, repository.ErrConflict):
+		writeJSONError(w, "Modified concurrently, reload and retry", http.StatusConflict)
+	case errors.Is(err, repository.ErrInvalidCursor):
+		writeJSONError(w, "Invalid cursor", http.StatusBadRequest)
+	case errors.Is(err, repository.ErrInvalidTenant):
+		writeJSONError(w, "Missing or invalid tenant", http.StatusBadRequest)
+	case errors.Is(err, service.ErrInvalidAssignment), errors.Is(err, service.ErrInvalidPermission),
+		errors.Is(err, service.ErrInvalidGrant):
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrRoleCycle):
+		writeJSONError(w, err.Error(), http.StatusConflict)
+	default:
+		writeJSONError(w, message, http.StatusInternalServerError)
+	}
+}
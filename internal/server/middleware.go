@@ -2,28 +2,51 @@ package server
 
 import (
 	"aws-dynamodb-store/internal/domain"
+	"aws-dynamodb-store/internal/repository"
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 )
 
-func (s *Server) AuthMiddleware(next http.Handler) http.Handler {
+// TenantMiddleware scopes the request to the tenant in the X-TENANT header.
+// Every repository call made for the request is limited to that tenant.
+//
+// The user in the X-USER header, when given, is looked up in that tenant
+// before the request is scoped to it, so a user can only act in the tenant it
+// belongs to. Requests naming a user of another tenant are rejected.
+func (s *Server) TenantMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		userId := r.Header.Get("X-USER")
+		tenantID := r.Header.Get("X-TENANT")
 
-		if len(userId) == 0 {
-			next.ServeHTTP(w, r)
+		if len(tenantID) == 0 || strings.Contains(tenantID, "#") {
+			writeJSONError(w, "Missing or invalid X-TENANT header", http.StatusBadRequest)
 			return
 		}
 
-		user, err := s.repository.User.GetUserByID(r.Context(), domain.UserID(userId))
+		ctx := repository.WithTenant(r.Context(), domain.TenantID(tenantID))
 
-		if err != nil {
-			writeJSONError(w, fmt.Sprintf("User ID '%s' does not exists in the database", userId), http.StatusUnauthorized)
-			return
+		if userId := r.Header.Get("X-USER"); len(userId) > 0 {
+			user, err := s.repository.User.GetUserByID(ctx, domain.UserID(userId))
+			if errors.Is(err, repository.ErrNotFound) {
+				writeJSONError(w, fmt.Sprintf("User ID '%s' does not belong to tenant '%s'", userId, tenantID), http.StatusForbidden)
+				return
+			}
+			if err != nil {
+				writeJSONError(w, "Failed to look up the user", http.StatusInternalServerError)
+				return
+			}
+			ctx = context.WithValue(ctx, "user", user)
 		}
 
-		ctx := context.WithValue(r.Context(), "user", user)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// authenticatedUser returns the user TenantMiddleware put into the context, or
+// nil for anonymous requests.
+func authenticatedUser(ctx context.Context) *domain.User {
+	user, _ := ctx.Value("user").(*domain.User)
+	return user
+}
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"aws-dynamodb-store/internal/repository"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 1000
+)
+
+// readPageRequest reads the ?limit=&cursor= query parameters of list endpoints.
+func readPageRequest(r *http.Request) (repository.PageRequest, error) {
+	page := repository.PageRequest{
+		Limit:  defaultPageLimit,
+		Cursor: r.URL.Query().Get("cursor"),
+	}
+
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return page, errors.New("limit must be a number between 1 and " + strconv.Itoa(maxPageLimit))
+		}
+		page.Limit = limit
+	}
+
+	return page, nil
+}
@@ -3,8 +3,11 @@ package server
 import (
 	"aws-dynamodb-store/internal/domain"
 	"aws-dynamodb-store/internal/model"
+	"aws-dynamodb-store/internal/repository"
 	"encoding/json"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"time"
 
@@ -25,21 +28,61 @@ func (s *Server) RegisterRoutes() http.Handler {
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"https://*", "http://*"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-TENANT", "X-USER"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
 
-	r.Use(s.AuthMiddleware)
-
 	r.Get("/", s.HelloWorldHandler)
-	r.Post("/users", s.CreateUser)
-	r.Get("/users", s.GetUsers)
-	r.Get("/{userID}", s.GetUser)
+
+	// Everything else is scoped to the tenant of the request, which the user
+	// of the request must belong to.
+	r.Group(func(r chi.Router) {
+		r.Use(s.TenantMiddleware)
+
+		r.Post("/users", s.CreateUser)
+		r.Get("/users", s.GetUsers)
+		r.Get("/roles", s.GetRoles)
+		r.Get("/permissions", s.GetPermissions)
+		r.Put("/users/{userID}", s.UpdateUser)
+		r.Put("/roles/{roleID}", s.UpdateRole)
+		r.Put("/permissions/{permissionID}", s.UpdatePermission)
+		r.Delete("/users/{userID}", s.DeleteUser)
+		r.Delete("/roles/{roleID}", s.DeleteRole)
+		r.Delete("/permissions/{permissionID}", s.DeletePermission)
+		r.Post("/users/{userID}/restore", s.RestoreUser)
+		r.Get("/users/{userID}/roles", s.GetUserRoles)
+		r.Get("/users/{userID}/assignments", s.GetUserAssignments)
+		r.Get("/users/{userID}/resources", s.GetUserResources)
+		r.Post("/users/{userID}/check", s.CheckAccess)
+		r.Post("/users/{userID}/roles", s.AssignRoleToUser)
+		r.Delete("/users/{userID}/roles/{roleID}", s.RemoveRoleFromUser)
+		r.Post("/roles/{roleID}/restore", s.RestoreRole)
+		r.Get("/roles/{roleID}/parents", s.GetParentRoles)
+		r.Post("/roles/{roleID}/parents", s.AddParentRole)
+		r.Delete("/roles/{roleID}/parents/{parentID}", s.RemoveParentRole)
+		r.Get("/roles/{roleID}/permissions", s.GetRoleGrants)
+		r.Post("/roles/{roleID}/permissions", s.AssignPermissionToRole)
+		r.Delete("/roles/{roleID}/permissions/{permissionID}", s.RemovePermissionFromRole)
+		r.Post("/permissions/{permissionID}/restore", s.RestorePermission)
+		r.Get("/users/{userID}/groups", s.GetUserGroups)
+		r.Post("/groups", s.CreateGroup)
+		r.Get("/groups", s.GetGroups)
+		r.Get("/groups/{groupID}", s.GetGroup)
+		r.Delete("/groups/{groupID}", s.DeleteGroup)
+		r.Get("/groups/{groupID}/members", s.GetGroupMembers)
+		r.Post("/groups/{groupID}/members", s.AddGroupMember)
+		r.Delete("/groups/{groupID}/members/{userID}", s.RemoveGroupMember)
+		r.Get("/groups/{groupID}/roles", s.GetGroupRoles)
+		r.Post("/groups/{groupID}/roles", s.AssignRoleToGroup)
+		r.Delete("/groups/{groupID}/roles/{roleID}", s.RemoveRoleFromGroup)
+		r.Get("/{userID}", s.GetUser)
+	})
 
 	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("OK"))
 	})
+	r.Get("/cache/stats", s.GetCacheStats)
 
 	return r
 }
@@ -121,18 +164,629 @@ func (s *Server) GetUser(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) GetUsers(w http.ResponseWriter, r *http.Request) {
+	page, err := readPageRequest(r)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	users, err := s.repository.User.ListAllUsers(r.Context())
+	users, err := s.repository.User.ListAllUsers(r.Context(), page)
 	if err != nil {
 		log.Println(err.Error())
-		writeJSONError(w, "Failed to get users", http.StatusInternalServerError)
+		writeServiceError(w, err, "Failed to get users")
 		return
 	}
-	if users == nil {
-		writeJSONError(w, "Users not found", http.StatusNotFound)
+
+	writeJSON(w, http.StatusOK, users)
+}
+
+func (s *Server) GetRoles(w http.ResponseWriter, r *http.Request) {
+	page, err := readPageRequest(r)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(users)
+	roles, err := s.service.RBACService.GetAllRoles(r.Context(), page)
+	if err != nil {
+		log.Println(err.Error())
+		writeServiceError(w, err, "Failed to get roles")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, roles)
+}
+
+func (s *Server) GetPermissions(w http.ResponseWriter, r *http.Request) {
+	page, err := readPageRequest(r)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	permissions, err := s.service.RBACService.GetAllPermissions(r.Context(), page)
+	if err != nil {
+		log.Println(err.Error())
+		writeServiceError(w, err, "Failed to get permissions")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, permissions)
+}
+
+// UpdateUser handles PUT /users/{userID}
+func (s *Server) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	var input model.UserUpdateInput
+	if err := readJSON(w, r, &input); err != nil {
+		writeJSONError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user, err := s.service.RBACService.GetUser(r.Context(), domain.UserID(chi.URLParam(r, "userID")))
+	if err != nil {
+		writeServiceError(w, err, "Failed to update user")
+		return
+	}
+	if input.DisplayName != nil {
+		user.DisplayName = *input.DisplayName
+	}
+	if input.Email != nil {
+		user.Email = *input.Email
+	}
+	if input.Attributes != nil {
+		user.Attributes = *input.Attributes
+	}
+	user.Version = input.Version
+
+	if err := s.service.RBACService.UpdateUser(r.Context(), user); err != nil {
+		log.Print(err)
+		writeServiceError(w, err, "Failed to update user")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, user)
+}
+
+// UpdateRole handles PUT /roles/{roleID}
+func (s *Server) UpdateRole(w http.ResponseWriter, r *http.Request) {
+	var input model.RoleUpdateInput
+	if err := readJSON(w, r, &input); err != nil {
+		writeJSONError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	role, err := s.service.RBACService.GetRole(r.Context(), domain.RoleID(chi.URLParam(r, "roleID")))
+	if err != nil {
+		writeServiceError(w, err, "Failed to update role")
+		return
+	}
+	if input.DisplayName != nil {
+		role.DisplayName = *input.DisplayName
+	}
+	if input.Description != nil {
+		role.Description = *input.Description
+	}
+	role.Version = input.Version
+
+	if err := s.service.RBACService.UpdateRole(r.Context(), role); err != nil {
+		log.Print(err)
+		writeServiceError(w, err, "Failed to update role")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, role)
+}
+
+// UpdatePermission handles PUT /permissions/{permissionID}
+func (s *Server) UpdatePermission(w http.ResponseWriter, r *http.Request) {
+	var input model.PermissionUpdateInput
+	if err := readJSON(w, r, &input); err != nil {
+		writeJSONError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	permission, err := s.service.RBACService.GetPermission(r.Context(), domain.PermissionID(chi.URLParam(r, "permissionID")))
+	if err != nil {
+		writeServiceError(w, err, "Failed to update permission")
+		return
+	}
+	if input.DisplayName != nil {
+		permission.DisplayName = *input.DisplayName
+	}
+	if input.Description != nil {
+		permission.Description = *input.Description
+	}
+	permission.Version = input.Version
+
+	if err := s.service.RBACService.UpdatePermission(r.Context(), permission); err != nil {
+		log.Print(err)
+		writeServiceError(w, err, "Failed to update permission")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, permission)
+}
+
+// DeleteUser handles DELETE /users/{userID}
+func (s *Server) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	if err := s.service.RBACService.DeleteUser(r.Context(), domain.UserID(chi.URLParam(r, "userID"))); err != nil {
+		log.Print(err)
+		writeServiceError(w, err, "Failed to delete user")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteRole handles DELETE /roles/{roleID}
+func (s *Server) DeleteRole(w http.ResponseWriter, r *http.Request) {
+	if err := s.service.RBACService.DeleteRole(r.Context(), domain.RoleID(chi.URLParam(r, "roleID"))); err != nil {
+		log.Print(err)
+		writeServiceError(w, err, "Failed to delete role")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeletePermission handles DELETE /permissions/{permissionID}
+func (s *Server) DeletePermission(w http.ResponseWriter, r *http.Request) {
+	if err := s.service.RBACService.DeletePermission(r.Context(), domain.PermissionID(chi.URLParam(r, "permissionID"))); err != nil {
+		log.Print(err)
+		writeServiceError(w, err, "Failed to delete permission")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RestoreUser handles POST /users/{userID}/restore
+func (s *Server) RestoreUser(w http.ResponseWriter, r *http.Request) {
+	if err := s.service.RBACService.RestoreUser(r.Context(), domain.UserID(chi.URLParam(r, "userID"))); err != nil {
+		log.Print(err)
+		writeServiceError(w, err, "Failed to restore user")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RestoreRole handles POST /roles/{roleID}/restore
+func (s *Server) RestoreRole(w http.ResponseWriter, r *http.Request) {
+	if err := s.service.RBACService.RestoreRole(r.Context(), domain.RoleID(chi.URLParam(r, "roleID"))); err != nil {
+		log.Print(err)
+		writeServiceError(w, err, "Failed to restore role")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RestorePermission handles POST /permissions/{permissionID}/restore
+func (s *Server) RestorePermission(w http.ResponseWriter, r *http.Request) {
+	if err := s.service.RBACService.RestorePermission(r.Context(), domain.PermissionID(chi.URLParam(r, "permissionID"))); err != nil {
+		log.Print(err)
+		writeServiceError(w, err, "Failed to restore permission")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetUserRoles handles GET /users/{userID}/roles. With ?resource= only the
+// roles assigned on that resource are listed.
+func (s *Server) GetUserRoles(w http.ResponseWriter, r *http.Request) {
+	page, err := readPageRequest(r)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userID := domain.UserID(chi.URLParam(r, "userID"))
+	var roles *repository.Page[*domain.Role]
+	if resourceID := r.URL.Query().Get("resource"); resourceID != "" {
+		roles, err = s.service.RBACService.GetUserRolesOnResource(r.Context(), userID, resourceID, page)
+	} else {
+		roles, err = s.service.RBACService.GetUserRoles(r.Context(), userID, page)
+	}
+	if err != nil {
+		log.Println(err.Error())
+		writeServiceError(w, err, "Failed to get user roles")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, roles)
+}
+
+// AssignRoleToUser handles POST /users/{userID}/roles
+func (s *Server) AssignRoleToUser(w http.ResponseWriter, r *http.Request) {
+	var input model.RoleAssignmentInput
+	if err := readJSON(w, r, &input); err != nil || input.RoleID == "" {
+		writeJSONError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	assignment := &domain.RoleAssignment{
+		UserID:     domain.UserID(chi.URLParam(r, "userID")),
+		RoleID:     domain.RoleID(input.RoleID),
+		ResourceID: input.ResourceID,
+		Reason:     input.Reason,
+		TicketRef:  input.TicketRef,
+		NotBefore:  input.NotBefore,
+		ExpiresAt:  input.ExpiresAt,
+	}
+	if actor := authenticatedUser(r.Context()); actor != nil {
+		assignment.AssignedBy = actor.ID
+	}
+	if err := s.service.RBACService.AssignRoleToUser(r.Context(), assignment); err != nil {
+		log.Print(err)
+		writeServiceError(w, err, "Failed to assign role")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, assignment)
+}
+
+// RemoveRoleFromUser handles DELETE /users/{userID}/roles/{roleID}. With
+// ?resource= the assignment on that resource is removed instead of the global one.
+func (s *Server) RemoveRoleFromUser(w http.ResponseWriter, r *http.Request) {
+	userID := domain.UserID(chi.URLParam(r, "userID"))
+	roleID := domain.RoleID(chi.URLParam(r, "roleID"))
+	var err error
+	if resourceID := r.URL.Query().Get("resource"); resourceID != "" {
+		err = s.service.RBACService.RemoveRoleFromUserOnResource(r.Context(), userID, roleID, resourceID)
+	} else {
+		err = s.service.RBACService.RemoveRoleFromUser(r.Context(), userID, roleID)
+	}
+	if err != nil {
+		log.Print(err)
+		writeServiceError(w, err, "Failed to remove role")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetUserResources handles GET /users/{userID}/resources?prefix=
+func (s *Server) GetUserResources(w http.ResponseWriter, r *http.Request) {
+	page, err := readPageRequest(r)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userID := domain.UserID(chi.URLParam(r, "userID"))
+	resources, err := s.service.RBACService.ListUserResources(r.Context(), userID, r.URL.Query().Get("prefix"), page)
+	if err != nil {
+		log.Println(err.Error())
+		writeServiceError(w, err, "Failed to get user resources")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resources)
+}
+
+// GetUserAssignments handles GET /users/{userID}/assignments
+func (s *Server) GetUserAssignments(w http.ResponseWriter, r *http.Request) {
+	page, err := readPageRequest(r)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	assignments, err := s.service.RBACService.ListUserAssignments(r.Context(), domain.UserID(chi.URLParam(r, "userID")), page)
+	if err != nil {
+		log.Println(err.Error())
+		writeServiceError(w, err, "Failed to get user assignments")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, assignments)
+}
+
+// GetParentRoles handles GET /roles/{roleID}/parents
+func (s *Server) GetParentRoles(w http.ResponseWriter, r *http.Request) {
+	page, err := readPageRequest(r)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	roles, err := s.service.RBACService.GetParentRoles(r.Context(), domain.RoleID(chi.URLParam(r, "roleID")), page)
+	if err != nil {
+		log.Println(err.Error())
+		writeServiceError(w, err, "Failed to get parent roles")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, roles)
+}
+
+// AddParentRole handles POST /roles/{roleID}/parents
+func (s *Server) AddParentRole(w http.ResponseWriter, r *http.Request) {
+	var input model.ParentRoleInput
+	if err := readJSON(w, r, &input); err != nil || input.ParentID == "" {
+		writeJSONError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	roleID := domain.RoleID(chi.URLParam(r, "roleID"))
+	if err := s.service.RBACService.AddParentRole(r.Context(), roleID, domain.RoleID(input.ParentID)); err != nil {
+		log.Print(err)
+		writeServiceError(w, err, "Failed to add parent role")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RemoveParentRole handles DELETE /roles/{roleID}/parents/{parentID}
+func (s *Server) RemoveParentRole(w http.ResponseWriter, r *http.Request) {
+	roleID := domain.RoleID(chi.URLParam(r, "roleID"))
+	parentID := domain.RoleID(chi.URLParam(r, "parentID"))
+	if err := s.service.RBACService.RemoveParentRole(r.Context(), roleID, parentID); err != nil {
+		log.Print(err)
+		writeServiceError(w, err, "Failed to remove parent role")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CheckAccess handles POST /users/{userID}/check. The decision is returned
+// with 200 whether or not access is allowed.
+func (s *Server) CheckAccess(w http.ResponseWriter, r *http.Request) {
+	var input model.AccessCheckInput
+	if err := readJSON(w, r, &input); err != nil || input.PermissionID == "" {
+		writeJSONError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	for name := range input.Attributes {
+		if domain.ServerAttribute(name) {
+			writeJSONError(w, fmt.Sprintf("Attribute '%s' is set by the server", name), http.StatusBadRequest)
+			return
+		}
+	}
+
+	decision, err := s.service.RBACService.CheckAccess(r.Context(), &domain.AccessRequest{
+		UserID:       domain.UserID(chi.URLParam(r, "userID")),
+		PermissionID: domain.PermissionID(input.PermissionID),
+		ResourceID:   input.ResourceID,
+		Attributes:   input.Attributes,
+		SourceIP:     sourceIP(r),
+	})
+	if err != nil {
+		log.Print(err)
+		writeServiceError(w, err, "Failed to check access")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, decision)
+}
+
+// sourceIP returns the client address of r. middleware.RealIP has already
+// replaced RemoteAddr with the forwarded address when behind a proxy, without
+// a port.
+func sourceIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// GetRoleGrants handles GET /roles/{roleID}/permissions
+func (s *Server) GetRoleGrants(w http.ResponseWriter, r *http.Request) {
+	page, err := readPageRequest(r)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	grants, err := s.service.RBACService.GetRoleGrants(r.Context(), domain.RoleID(chi.URLParam(r, "roleID")), page)
+	if err != nil {
+		log.Println(err.Error())
+		writeServiceError(w, err, "Failed to get role permissions")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, grants)
+}
+
+// AssignPermissionToRole handles POST /roles/{roleID}/permissions
+func (s *Server) AssignPermissionToRole(w http.ResponseWriter, r *http.Request) {
+	var input model.RolePermissionInput
+	if err := readJSON(w, r, &input); err != nil || input.PermissionID == "" {
+		writeJSONError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	grant := &domain.PermissionGrant{
+		RoleID:       domain.RoleID(chi.URLParam(r, "roleID")),
+		PermissionID: domain.PermissionID(input.PermissionID),
+		Effect:       domain.Effect(input.Effect),
+		Condition:    domain.Condition(input.Condition),
+	}
+	if err := s.service.RBACService.AssignPermissionToRole(r.Context(), grant); err != nil {
+		log.Print(err)
+		writeServiceError(w, err, "Failed to assign permission to role")
+		return
+	}
+	writeJSON(w, http.StatusCreated, grant)
+}
+
+// RemovePermissionFromRole handles DELETE /roles/{roleID}/permissions/{permissionID}
+func (s *Server) RemovePermissionFromRole(w http.ResponseWriter, r *http.Request) {
+	roleID := domain.RoleID(chi.URLParam(r, "roleID"))
+	permissionID := domain.PermissionID(chi.URLParam(r, "permissionID"))
+	if err := s.service.RBACService.RemovePermissionFromRole(r.Context(), roleID, permissionID); err != nil {
+		log.Print(err)
+		writeServiceError(w, err, "Failed to remove permission from role")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CreateGroup handles POST /groups
+func (s *Server) CreateGroup(w http.ResponseWriter, r *http.Request) {
+	var input model.GroupCreateInput
+	if err := readJSON(w, r, &input); err != nil || input.DisplayName == "" {
+		writeJSONError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	group, err := s.service.RBACService.CreateGroup(r.Context(), input.DisplayName, input.Description)
+	if err != nil {
+		log.Print(err)
+		writeServiceError(w, err, "Failed to create group")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, group)
+}
+
+// GetGroups handles GET /groups
+func (s *Server) GetGroups(w http.ResponseWriter, r *http.Request) {
+	page, err := readPageRequest(r)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	groups, err := s.service.RBACService.GetAllGroups(r.Context(), page)
+	if err != nil {
+		log.Println(err.Error())
+		writeServiceError(w, err, "Failed to get groups")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, groups)
+}
+
+// GetGroup handles GET /groups/{groupID}
+func (s *Server) GetGroup(w http.ResponseWriter, r *http.Request) {
+	group, err := s.service.RBACService.GetGroup(r.Context(), domain.GroupID(chi.URLParam(r, "groupID")))
+	if err != nil {
+		writeServiceError(w, err, "Failed to get group")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, group)
+}
+
+// DeleteGroup handles DELETE /groups/{groupID}
+func (s *Server) DeleteGroup(w http.ResponseWriter, r *http.Request) {
+	if err := s.service.RBACService.DeleteGroup(r.Context(), domain.GroupID(chi.URLParam(r, "groupID"))); err != nil {
+		log.Print(err)
+		writeServiceError(w, err, "Failed to delete group")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetGroupMembers handles GET /groups/{groupID}/members
+func (s *Server) GetGroupMembers(w http.ResponseWriter, r *http.Request) {
+	page, err := readPageRequest(r)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	users, err := s.service.RBACService.GetGroupMembers(r.Context(), domain.GroupID(chi.URLParam(r, "groupID")), page)
+	if err != nil {
+		log.Println(err.Error())
+		writeServiceError(w, err, "Failed to get group members")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, users)
+}
+
+// AddGroupMember handles POST /groups/{groupID}/members
+func (s *Server) AddGroupMember(w http.ResponseWriter, r *http.Request) {
+	var input model.GroupMemberInput
+	if err := readJSON(w, r, &input); err != nil || input.UserID == "" {
+		writeJSONError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	groupID := domain.GroupID(chi.URLParam(r, "groupID"))
+	if err := s.service.RBACService.AddUserToGroup(r.Context(), domain.UserID(input.UserID), groupID); err != nil {
+		log.Print(err)
+		writeServiceError(w, err, "Failed to add group member")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RemoveGroupMember handles DELETE /groups/{groupID}/members/{userID}
+func (s *Server) RemoveGroupMember(w http.ResponseWriter, r *http.Request) {
+	groupID := domain.GroupID(chi.URLParam(r, "groupID"))
+	userID := domain.UserID(chi.URLParam(r, "userID"))
+	if err := s.service.RBACService.RemoveUserFromGroup(r.Context(), userID, groupID); err != nil {
+		log.Print(err)
+		writeServiceError(w, err, "Failed to remove group member")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetGroupRoles handles GET /groups/{groupID}/roles
+func (s *Server) GetGroupRoles(w http.ResponseWriter, r *http.Request) {
+	page, err := readPageRequest(r)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	roles, err := s.service.RBACService.GetGroupRoles(r.Context(), domain.GroupID(chi.URLParam(r, "groupID")), page)
+	if err != nil {
+		log.Println(err.Error())
+		writeServiceError(w, err, "Failed to get group roles")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, roles)
+}
+
+// AssignRoleToGroup handles POST /groups/{groupID}/roles
+func (s *Server) AssignRoleToGroup(w http.ResponseWriter, r *http.Request) {
+	var input model.GroupRoleInput
+	if err := readJSON(w, r, &input); err != nil || input.RoleID == "" {
+		writeJSONError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	groupID := domain.GroupID(chi.URLParam(r, "groupID"))
+	if err := s.service.RBACService.AssignRoleToGroup(r.Context(), groupID, domain.RoleID(input.RoleID)); err != nil {
+		log.Print(err)
+		writeServiceError(w, err, "Failed to assign role to group")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RemoveRoleFromGroup handles DELETE /groups/{groupID}/roles/{roleID}
+func (s *Server) RemoveRoleFromGroup(w http.ResponseWriter, r *http.Request) {
+	groupID := domain.GroupID(chi.URLParam(r, "groupID"))
+	roleID := domain.RoleID(chi.URLParam(r, "roleID"))
+	if err := s.service.RBACService.RemoveRoleFromGroup(r.Context(), groupID, roleID); err != nil {
+		log.Print(err)
+		writeServiceError(w, err, "Failed to remove role from group")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetUserGroups handles GET /users/{userID}/groups
+func (s *Server) GetUserGroups(w http.ResponseWriter, r *http.Request) {
+	page, err := readPageRequest(r)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	groups, err := s.service.RBACService.GetUserGroups(r.Context(), domain.UserID(chi.URLParam(r, "userID")), page)
+	if err != nil {
+		log.Println(err.Error())
+		writeServiceError(w, err, "Failed to get user groups")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, groups)
+}
+
+// GetCacheStats handles GET /cache/stats
+func (s *Server) GetCacheStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.service.RBACService.CacheStats())
 }
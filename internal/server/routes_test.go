@@ -1,10 +1,19 @@
 package server
 
 import (
+	"aws-dynamodb-store/internal/domain"
+	"aws-dynamodb-store/internal/repository"
+	"aws-dynamodb-store/internal/service"
+	"context"
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+
+	memoryrepo "aws-dynamodb-store/internal/repository/memory"
 )
 
 func TestHandler(t *testing.T) {
@@ -29,3 +38,402 @@ func TestHandler(t *testing.T) {
 		t.Errorf("expected response body to be %v; got %v", expected, string(body))
 	}
 }
+
+func newTestServer(t *testing.T) (*httptest.Server, service.RBACService) {
+	t.Helper()
+	repo := memoryrepo.NewMemoryRepository(time.Hour)
+	rbac := service.NewRBACService(repo)
+	s := &Server{repository: repo, service: &service.Service{RBACService: rbac}}
+	server := httptest.NewServer(s.RegisterRoutes())
+	t.Cleanup(server.Close)
+	return server, rbac
+}
+
+func do(t *testing.T, method, url, tenant, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tenant != "" {
+		req.Header.Set("X-TENANT", tenant)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("error making request to server. Err: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestTenantHeaderIsRequired(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	tests := []struct {
+		name   string
+		tenant string
+		want   int
+	}{
+		{"missing", "", http.StatusBadRequest},
+		{"invalid", "a#b", http.StatusBadRequest},
+		{"valid", "t1", http.StatusOK},
+	}
+	for _, tt := range tests {
+		resp := do(t, http.MethodGet, server.URL+"/users", tt.tenant, "")
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s tenant: expected status %d; got %v", tt.name, tt.want, resp.Status)
+		}
+	}
+}
+
+func TestUserMustBelongToTenant(t *testing.T) {
+	server, rbac := newTestServer(t)
+	user, err := rbac.CreateUser(repository.WithTenant(context.Background(), "t1"), "Alice", "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		tenant string
+		user   string
+		want   int
+	}{
+		{name: "anonymous", tenant: "t1", want: http.StatusOK},
+		{name: "member", tenant: "t1", user: string(user.ID), want: http.StatusOK},
+		{name: "other tenant", tenant: "t2", user: string(user.ID), want: http.StatusForbidden},
+		{name: "unknown user", tenant: "t1", user: "missing", want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, server.URL+"/users", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("X-TENANT", tt.tenant)
+			if tt.user != "" {
+				req.Header.Set("X-USER", tt.user)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("expected status %d; got %v", tt.want, resp.Status)
+			}
+		})
+	}
+}
+
+func TestCORSAllowsCustomHeaders(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	req, err := http.NewRequest(http.MethodOptions, server.URL+"/users", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	req.Header.Set("Access-Control-Request-Headers", "X-Tenant, X-User")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	allowed := strings.ToLower(resp.Header.Get("Access-Control-Allow-Headers"))
+	for _, header := range []string{"x-tenant", "x-user"} {
+		if !strings.Contains(allowed, header) {
+			t.Errorf("expected %s to be allowed; got %q", header, allowed)
+		}
+	}
+}
+
+func TestUsersAreScopedByTenant(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	resp := do(t, http.MethodPost, server.URL+"/users", "t1", `{"name":"Alice","email":"alice@example.com"}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected status Created; got %v", resp.Status)
+	}
+	var user domain.User
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		t.Fatal(err)
+	}
+
+	// The user handlers report failures as 400, so only success is checked.
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		tenant string
+		body   string
+		wantOK bool
+	}{
+		{"get in its tenant", http.MethodGet, server.URL + "/" + string(user.ID), "t1", "", true},
+		{"get in another tenant", http.MethodGet, server.URL + "/" + string(user.ID), "t2", "", false},
+		{"create twice", http.MethodPost, server.URL + "/users", "t1", `{"name":"Alice","email":"alice@example.com"}`, false},
+		{"create in another tenant", http.MethodPost, server.URL + "/users", "t2", `{"name":"Alice","email":"alice@example.com"}`, true},
+	}
+	for _, tt := range tests {
+		resp := do(t, tt.method, tt.url, tt.tenant, tt.body)
+		if ok := resp.StatusCode < http.StatusBadRequest; ok != tt.wantOK {
+			t.Errorf("%s: expected success=%v; got %v", tt.name, tt.wantOK, resp.Status)
+		}
+	}
+}
+
+func TestUpdateRequiresCurrentVersion(t *testing.T) {
+	server, rbac := newTestServer(t)
+	user, err := rbac.CreateUser(repository.WithTenant(context.Background(), "t1"), "Alice", "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"current version", `{"name":"Alice A.","version":1}`, http.StatusOK},
+		{"stale version", `{"name":"Alice B.","version":1}`, http.StatusConflict},
+		{"after reloading", `{"name":"Alice B.","version":2}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		resp := do(t, http.MethodPut, server.URL+"/users/"+string(user.ID), "t1", tt.body)
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s: expected status %d; got %v", tt.name, tt.want, resp.Status)
+		}
+	}
+}
+
+func TestAssignmentsRecordTheActor(t *testing.T) {
+	server, rbac := newTestServer(t)
+	ctx := repository.WithTenant(context.Background(), "t1")
+	alice, err := rbac.CreateUser(ctx, "Alice", "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, err := rbac.CreateUser(ctx, "Admin", "admin@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	editor, err := rbac.CreateRole(ctx, "editor", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	viewer, err := rbac.CreateRole(ctx, "viewer", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		role  domain.RoleID
+		actor domain.UserID
+	}{
+		{editor.ID, admin.ID},
+		{viewer.ID, ""},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/users/"+string(alice.ID)+"/roles", strings.NewReader(`{"roleId":"`+string(tt.role)+`","reason":"onboarding","ticketRef":"T-1"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-TENANT", "t1")
+		if tt.actor != "" {
+			req.Header.Set("X-USER", string(tt.actor))
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("%s: expected status Created; got %v", tt.role, resp.Status)
+		}
+	}
+
+	resp := do(t, http.MethodGet, server.URL+"/users/"+string(alice.ID)+"/assignments", "t1", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status OK; got %v", resp.Status)
+	}
+	var page repository.Page[domain.RoleAssignment]
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != len(tests) {
+		t.Fatalf("expected %d assignments; got %+v", len(tests), page.Items)
+	}
+	for _, tt := range tests {
+		for _, assignment := range page.Items {
+			if assignment.RoleID != tt.role {
+				continue
+			}
+			if assignment.AssignedBy != tt.actor || assignment.Reason != "onboarding" || assignment.TicketRef != "T-1" || assignment.AssignedAt.IsZero() {
+				t.Errorf("%s: unexpected provenance %+v", tt.role, assignment)
+			}
+		}
+	}
+}
+
+func TestCheckAccess(t *testing.T) {
+	server, rbac := newTestServer(t)
+	ctx := repository.WithTenant(context.Background(), "t1")
+	user, err := rbac.CreateUser(ctx, "Alice", "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	role, err := rbac.CreateRole(ctx, "editor", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rbac.CreatePermission(ctx, "document:write", "Write", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := do(t, http.MethodPost, server.URL+"/roles/"+string(role.ID)+"/permissions", "t1", `{"permissionId":"document:write"}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected status Created; got %v", resp.Status)
+	}
+	resp = do(t, http.MethodPost, server.URL+"/users/"+string(user.ID)+"/roles", "t1", `{"roleId":"`+string(role.ID)+`","resourceId":"org/acme"}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected status Created; got %v", resp.Status)
+	}
+
+	tests := []struct {
+		name string
+		user domain.UserID
+		body string
+		want bool
+	}{
+		{"on the resource", user.ID, `{"permissionId":"document:write","resourceId":"org/acme"}`, true},
+		{"beneath the resource", user.ID, `{"permissionId":"document:write","resourceId":"org/acme/doc/1"}`, true},
+		{"on another resource", user.ID, `{"permissionId":"document:write","resourceId":"org/other"}`, false},
+		{"globally", user.ID, `{"permissionId":"document:write"}`, false},
+		{"unknown user", "user-nobody", `{"permissionId":"document:write","resourceId":"org/acme"}`, false},
+	}
+	for _, tt := range tests {
+		resp := do(t, http.MethodPost, server.URL+"/users/"+string(tt.user)+"/check", "t1", tt.body)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: expected status OK; got %v", tt.name, resp.Status)
+		}
+		var decision domain.Decision
+		if err := json.NewDecoder(resp.Body).Decode(&decision); err != nil {
+			t.Fatal(err)
+		}
+		if decision.Allowed != tt.want {
+			t.Errorf("%s: expected allowed=%v; got %+v", tt.name, tt.want, decision)
+		}
+	}
+
+	resp = do(t, http.MethodPost, server.URL+"/users/"+string(user.ID)+"/check", "t1", `{}`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status Bad Request without a permission; got %v", resp.Status)
+	}
+	resp = do(t, http.MethodPost, server.URL+"/users/"+string(user.ID)+"/check", "t1", `{"permissionId":"document:write","attributes":{"request.ip":"10.0.0.1"}}`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status Bad Request for a server attribute; got %v", resp.Status)
+	}
+}
+
+func TestCheckAccessUsesConnectionIP(t *testing.T) {
+	server, rbac := newTestServer(t)
+	ctx := repository.WithTenant(context.Background(), "t1")
+	user, err := rbac.CreateUser(ctx, "Alice", "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	role, err := rbac.CreateRole(ctx, "reader", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []domain.PermissionID{"report:read", "report:export"} {
+		if _, err := rbac.CreatePermission(ctx, id, string(id), ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	grants := []*domain.PermissionGrant{
+		{RoleID: role.ID, PermissionID: "report:read", Condition: `request.ip in "127.0.0.0/8"`},
+		{RoleID: role.ID, PermissionID: "report:export", Condition: `request.ip in "10.0.0.0/8"`},
+	}
+	for _, grant := range grants {
+		if err := rbac.AssignPermissionToRole(ctx, grant); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rbac.AssignRoleToUser(ctx, &domain.RoleAssignment{UserID: user.ID, RoleID: role.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		body string
+		want bool
+	}{
+		{`{"permissionId":"report:read"}`, true},
+		{`{"permissionId":"report:export"}`, false},
+	}
+	for _, tt := range tests {
+		resp := do(t, http.MethodPost, server.URL+"/users/"+string(user.ID)+"/check", "t1", tt.body)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: expected status OK; got %v", tt.body, resp.Status)
+		}
+		var decision domain.Decision
+		if err := json.NewDecoder(resp.Body).Decode(&decision); err != nil {
+			t.Fatal(err)
+		}
+		if decision.Allowed != tt.want {
+			t.Errorf("%s: expected allowed=%v; got %+v", tt.body, tt.want, decision)
+		}
+	}
+}
+
+func TestGroupMembers(t *testing.T) {
+	server, rbac := newTestServer(t)
+	ctx := repository.WithTenant(context.Background(), "t1")
+	user, err := rbac.CreateUser(ctx, "Alice", "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp := do(t, http.MethodPost, server.URL+"/groups", "t1", `{"displayName":"writers"}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected status Created; got %v", resp.Status)
+	}
+	var group domain.Group
+	if err := json.NewDecoder(resp.Body).Decode(&group); err != nil {
+		t.Fatal(err)
+	}
+	members := server.URL + "/groups/" + string(group.ID) + "/members"
+
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		body       string
+		wantStatus int
+		wantListed bool
+	}{
+		{"add", http.MethodPost, members, `{"userId":"` + string(user.ID) + `"}`, http.StatusNoContent, true},
+		{"add unknown user", http.MethodPost, members, `{"userId":"missing"}`, http.StatusNotFound, true},
+		{"remove", http.MethodDelete, members + "/" + string(user.ID), "", http.StatusNoContent, false},
+	}
+	for _, tt := range tests {
+		resp := do(t, tt.method, tt.url, "t1", tt.body)
+		if resp.StatusCode != tt.wantStatus {
+			t.Fatalf("%s: expected status %d; got %v", tt.name, tt.wantStatus, resp.Status)
+		}
+
+		resp = do(t, http.MethodGet, members, "t1", "")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: expected status OK; got %v", tt.name, resp.Status)
+		}
+		var page repository.Page[domain.User]
+		if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+			t.Fatal(err)
+		}
+		listed := len(page.Items) == 1 && page.Items[0].ID == user.ID
+		if listed != tt.wantListed || len(page.Items) > 1 {
+			t.Errorf("%s: expected %s to be listed=%v; got %+v", tt.name, user.ID, tt.wantListed, page.Items)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package service
+
+import (
+	"aws-dynamodb-store/internal/domain"
+	"aws-dynamodb-store/internal/repository"
+	"container/list"
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Access checks of hot users repeat the same role and grant lookups many times
+// a second. WithCache keeps their results for a short while. Writes made
+// through the service drop the entries they affect; writes made elsewhere,
+// e.g. by another instance, and role assignments that start or expire show
+// once the entries expire. Recomputing effective permissions always reads the
+// repository, so stale entries never end up stored on a user.
+
+// CacheStats counts the lookups answered from the cache and those that had to
+// read the repository.
+type CacheStats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+}
+
+// WithCache caches the roles of users and the grants of roles for ttl, at most
+// size entries each, evicting the least recently used ones first.
+func WithCache(ttl time.Duration, size int) Option {
+	return func(s *rbacServiceImpl) {
+		s.cache = &decisionCache{
+			userRoles:  newLookupCache[[]*domain.Role](ttl, size),
+			roleGrants: newLookupCache[[]*domain.PermissionGrant](ttl, size),
+		}
+	}
+}
+
+type decisionCache struct {
+	userRoles  *lookupCache[[]*domain.Role]
+	roleGrants *lookupCache[[]*domain.PermissionGrant]
+}
+
+// CacheStats returns the hit and miss counts of the cache, zero without one.
+func (s *rbacServiceImpl) CacheStats() CacheStats {
+	if s.cache == nil {
+		return CacheStats{}
+	}
+	return CacheStats{
+		Hits:   s.cache.userRoles.hits.Load() + s.cache.roleGrants.hits.Load(),
+		Misses: s.cache.userRoles.misses.Load() + s.cache.roleGrants.misses.Load(),
+	}
+}
+
+// cachedUserRoles is userRoles, answered from the cache when there is one.
+func (s *rbacServiceImpl) cachedUserRoles(ctx context.Context, userID domain.UserID) ([]*domain.Role, error) {
+	if s.cache == nil {
+		return s.userRoles(ctx, userID)
+	}
+	roles, err := s.cache.userRoles.get(ctx, string(userID), func() ([]*domain.Role, error) {
+		return s.userRoles(ctx, userID)
+	})
+	// Callers append to the roles, which must not write into the cache.
+	return slices.Clone(roles), err
+}
+
+// cachedGrantsOfRole is grantsOfRole, answered from the cache when there is
+// one.
+func (s *rbacServiceImpl) cachedGrantsOfRole(ctx context.Context, roleID domain.RoleID) ([]*domain.PermissionGrant, error) {
+	if s.cache == nil {
+		return s.grantsOfRole(ctx, roleID)
+	}
+	return s.cache.roleGrants.get(ctx, string(roleID), func() ([]*domain.PermissionGrant, error) {
+		return s.grantsOfRole(ctx, roleID)
+	})
+}
+
+// forgetUserRoles drops the cached roles of the users.
+func (s *rbacServiceImpl) forgetUserRoles(ctx context.Context, userIDs ...domain.UserID) {
+	if s.cache == nil {
+		return
+	}
+	for _, userID := range userIDs {
+		s.cache.userRoles.remove(ctx, string(userID))
+	}
+}
+
+// forgetAllUserRoles drops the cached roles of every user of the tenant, for
+// changes whose holders are not known up front: a group's roles, or a role
+// that is deleted or restored.
+func (s *rbacServiceImpl) forgetAllUserRoles(ctx context.Context) {
+	if s.cache == nil {
+		return
+	}
+	s.cache.userRoles.removeTenant(ctx)
+}
+
+// forgetRoleGrants drops the cached grants of the roles.
+func (s *rbacServiceImpl) forgetRoleGrants(ctx context.Context, roleIDs ...domain.RoleID) {
+	if s.cache == nil {
+		return
+	}
+	for _, roleID := range roleIDs {
+		s.cache.roleGrants.remove(ctx, string(roleID))
+	}
+}
+
+// forgetAllRoleGrants drops the cached grants of every role of the tenant, for
+// permissions that are deleted or restored.
+func (s *rbacServiceImpl) forgetAllRoleGrants(ctx context.Context) {
+	if s.cache == nil {
+		return
+	}
+	s.cache.roleGrants.removeTenant(ctx)
+}
+
+type cacheKey struct {
+	tenant domain.TenantID
+	id     string
+}
+
+type cacheEntry[V any] struct {
+	key       cacheKey
+	value     V
+	expiresAt time.Time
+}
+
+// lookupCache is a size-bounded LRU cache whose entries expire after ttl.
+// Entries are keyed by the tenant of the context and an ID.
+type lookupCache[V any] struct {
+	ttl  time.Duration
+	size int
+
+	mu      sync.Mutex
+	entries map[cacheKey]*list.Element
+	order   *list.List // Most recently used first
+	// Bumped by every removal, so values loaded before it are not stored.
+	generation uint64
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+func newLookupCache[V any](ttl time.Duration, size int) *lookupCache[V] {
+	return &lookupCache[V]{
+		ttl:     ttl,
+		size:    size,
+		entries: make(map[cacheKey]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// get returns the cached value of id, or loads and caches it. Calls without a
+// tenant bypass the cache, so the repository reports the missing tenant.
+func (c *lookupCache[V]) get(ctx context.Context, id string, load func() (V, error)) (V, error) {
+	tenantID, ok := repository.TenantFromContext(ctx)
+	if !ok || c.size <= 0 {
+		return load()
+	}
+	key := cacheKey{tenant: tenantID, id: id}
+
+	value, generation, ok := c.lookup(key)
+	if ok {
+		c.hits.Add(1)
+		return value, nil
+	}
+	c.misses.Add(1)
+
+	value, err := load()
+	if err != nil {
+		return value, err
+	}
+	c.store(key, value, generation)
+	return value, nil
+}
+
+// lookup returns the live entry of key, or the current generation when there
+// is none.
+func (c *lookupCache[V]) lookup(key cacheKey) (V, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+	element, ok := c.entries[key]
+	if !ok {
+		return zero, c.generation, false
+	}
+	entry := element.Value.(*cacheEntry[V])
+	if !time.Now().Before(entry.expiresAt) {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		return zero, c.generation, false
+	}
+	c.order.MoveToFront(element)
+	return entry.value, c.generation, true
+}
+
+// store caches value unless an entry was removed since it was loaded in
+// generation, as value may predate the write that removed it.
+func (c *lookupCache[V]) store(key cacheKey, value V, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+	entry := &cacheEntry[V]{key: key, value: value, expiresAt: time.Now().Add(c.ttl)}
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry[V]).key)
+	}
+}
+
+func (c *lookupCache[V]) remove(ctx context.Context, id string) {
+	tenantID, ok := repository.TenantFromContext(ctx)
+	if !ok {
+		return
+	}
+	key := cacheKey{tenant: tenantID, id: id}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	if element, ok := c.entries[key]; ok {
+		c.order.Remove(element)
+		delete(c.entries, key)
+	}
+}
+
+func (c *lookupCache[V]) removeTenant(ctx context.Context) {
+	tenantID, ok := repository.TenantFromContext(ctx)
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	for key, element := range c.entries {
+		if key.tenant == tenantID {
+			c.order.Remove(element)
+			delete(c.entries, key)
+		}
+	}
+}
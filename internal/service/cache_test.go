@@ -0,0 +1,140 @@
+package service
+
+import (
+	"aws-dynamodb-store/internal/domain"
+	"aws-dynamodb-store/internal/repository"
+	"context"
+	"testing"
+	"time"
+
+	memoryrepo "aws-dynamodb-store/internal/repository/memory"
+)
+
+// newCachedService returns a cached service over repo with a user holding a
+// role that grants document:read.
+func newCachedService(t *testing.T, repo repository.Repository, ttl time.Duration, size int) (RBACService, context.Context, *domain.User, *domain.Role) {
+	t.Helper()
+	ctx := repository.WithTenant(context.Background(), "t1")
+	s := NewRBACService(repo, WithCache(ttl, size))
+	user, err := s.CreateUser(ctx, "Alice", "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	role, err := s.CreateRole(ctx, "editor", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreatePermission(ctx, "document:read", "Read", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AssignPermissionToRole(ctx, &domain.PermissionGrant{RoleID: role.ID, PermissionID: "document:read"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AssignRoleToUser(ctx, &domain.RoleAssignment{UserID: user.ID, RoleID: role.ID}); err != nil {
+		t.Fatal(err)
+	}
+	return s, ctx, user, role
+}
+
+// resourceAccess checks on a resource, which the effective permissions stored
+// on the user do not answer, so the roles and grants are looked up.
+func resourceAccess(t *testing.T, s RBACService, ctx context.Context, userID domain.UserID, permissionID domain.PermissionID) bool {
+	t.Helper()
+	got, err := s.UserHasPermissionOnResource(ctx, userID, permissionID, "org/acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestCacheCountsHitsAndMisses(t *testing.T) {
+	s, ctx, user, _ := newCachedService(t, memoryrepo.NewMemoryRepository(time.Hour), time.Hour, 100)
+
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		want  bool
+		stats CacheStats
+	}{
+		{"first check", ctx, true, CacheStats{Hits: 0, Misses: 2}},
+		{"second check", ctx, true, CacheStats{Hits: 2, Misses: 2}},
+		// Other tenants do not see the entries.
+		{"other tenant", repository.WithTenant(context.Background(), "t2"), false, CacheStats{Hits: 2, Misses: 2}},
+	}
+	for _, tt := range tests {
+		if got := resourceAccess(t, s, tt.ctx, user.ID, "document:read"); got != tt.want {
+			t.Errorf("%s: expected access %v; got %v", tt.name, tt.want, got)
+		}
+		if got := s.CacheStats(); got != tt.stats {
+			t.Errorf("%s: CacheStats() = %+v; want %+v", tt.name, got, tt.stats)
+		}
+	}
+}
+
+func TestCacheIsInvalidatedByWrites(t *testing.T) {
+	s, ctx, user, role := newCachedService(t, memoryrepo.NewMemoryRepository(time.Hour), time.Hour, 100)
+	if _, err := s.CreatePermission(ctx, "document:write", "Write", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name string
+		do   func() error
+		want bool
+	}{
+		{"no write grant", func() error { return nil }, false},
+		{"grant write", func() error {
+			return s.AssignPermissionToRole(ctx, &domain.PermissionGrant{RoleID: role.ID, PermissionID: "document:write"})
+		}, true},
+		{"remove role", func() error { return s.RemoveRoleFromUser(ctx, user.ID, role.ID) }, false},
+		{"assign role", func() error {
+			return s.AssignRoleToUser(ctx, &domain.RoleAssignment{UserID: user.ID, RoleID: role.ID})
+		}, true},
+		{"delete permission", func() error { return s.DeletePermission(ctx, "document:write") }, false},
+	}
+	for _, step := range steps {
+		if err := step.do(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if got := resourceAccess(t, s, ctx, user.ID, "document:write"); got != step.want {
+			t.Errorf("after %s: expected access %v; got %v", step.name, step.want, got)
+		}
+	}
+}
+
+func TestCacheEntriesExpire(t *testing.T) {
+	repo := memoryrepo.NewMemoryRepository(time.Hour)
+	s, ctx, user, role := newCachedService(t, repo, 50*time.Millisecond, 100)
+	if !resourceAccess(t, s, ctx, user.ID, "document:read") {
+		t.Fatal("expected access before the write")
+	}
+
+	// Writes that bypass the service only show once the entries expire.
+	if err := repo.User.RemoveRoleFromUser(ctx, user.ID, role.ID); err != nil {
+		t.Fatal(err)
+	}
+	if !resourceAccess(t, s, ctx, user.ID, "document:read") {
+		t.Error("expected the cached roles to be used before they expire")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if resourceAccess(t, s, ctx, user.ID, "document:read") {
+		t.Error("expected the write to show once the entries expired")
+	}
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := repository.WithTenant(context.Background(), "t1")
+	s := NewRBACService(memoryrepo.NewMemoryRepository(time.Hour), WithCache(time.Hour, 2))
+	page := repository.PageRequest{Limit: 10}
+	for _, userID := range []domain.UserID{"a", "b", "a", "c", "a", "b"} {
+		if _, err := s.GetUserRoles(ctx, userID, page); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// c evicts b, the least recently used entry, so only the later lookups of
+	// a are hits.
+	if got, want := s.CacheStats(), (CacheStats{Hits: 2, Misses: 4}); got != want {
+		t.Errorf("CacheStats() = %+v; want %+v", got, want)
+	}
+}
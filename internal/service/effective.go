@@ -0,0 +1,336 @@
+package service
+
+import (
+	"aws-dynamodb-store/internal/domain"
+	"aws-dynamodb-store/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Every change to a user's roles, a group's members or roles, a role's grants
+// or parents, or the deletion of a role or permission recomputes the effective
+// permissions stored on the affected users, so most global checks need a
+// single read. With WithDeferredFanOut only the changed user is recomputed in
+// line; the Recompute methods are then called by the change-stream processor.
+
+// RecomputeEffectivePermissions refreshes the effective permissions stored on
+// the user from its current grants.
+func (s *rbacServiceImpl) RecomputeEffectivePermissions(ctx context.Context, userID domain.UserID) error {
+	if err := s.refreshUsers(ctx, userSet(userID)); err != nil {
+		return fmt.Errorf("service.RecomputeEffectivePermissions: %w", err)
+	}
+	return nil
+}
+
+// RecomputeRoleHolders refreshes the effective permissions of every user
+// holding the role or a role inheriting from it.
+func (s *rbacServiceImpl) RecomputeRoleHolders(ctx context.Context, roleID domain.RoleID) error {
+	if err := s.recomputeRoleHolders(ctx, roleID); err != nil {
+		return fmt.Errorf("service.RecomputeRoleHolders: %w", err)
+	}
+	return nil
+}
+
+// RecomputePermissionHolders refreshes the effective permissions of every
+// user holding a role with a grant of the permission.
+func (s *rbacServiceImpl) RecomputePermissionHolders(ctx context.Context, permissionID domain.PermissionID) error {
+	if err := s.recomputePermissionHolders(ctx, permissionID); err != nil {
+		return fmt.Errorf("service.RecomputePermissionHolders: %w", err)
+	}
+	return nil
+}
+
+// RecomputeGroupMembers refreshes the effective permissions of every member
+// of the group.
+func (s *rbacServiceImpl) RecomputeGroupMembers(ctx context.Context, groupID domain.GroupID) error {
+	if err := s.recomputeGroupMembers(ctx, groupID); err != nil {
+		return fmt.Errorf("service.RecomputeGroupMembers: %w", err)
+	}
+	return nil
+}
+
+// RecountGroupMembers refreshes the member count stored on the group.
+func (s *rbacServiceImpl) RecountGroupMembers(ctx context.Context, groupID domain.GroupID) error {
+	if _, err := s.repository.Group.RecountGroupMembers(ctx, groupID); err != nil {
+		return fmt.Errorf("service.RecountGroupMembers: %w", err)
+	}
+	return nil
+}
+
+// refreshRoleHolders, refreshPermissionHolders, refreshGroupMembers and
+// refreshGroupCount update the derived data after a change, unless it is left
+// to the change-stream processor.
+
+func (s *rbacServiceImpl) refreshRoleHolders(ctx context.Context, roleID domain.RoleID) error {
+	if s.deferFanOut {
+		return nil
+	}
+	return s.recomputeRoleHolders(ctx, roleID)
+}
+
+func (s *rbacServiceImpl) refreshPermissionHolders(ctx context.Context, permissionID domain.PermissionID) error {
+	if s.deferFanOut {
+		return nil
+	}
+	return s.recomputePermissionHolders(ctx, permissionID)
+}
+
+func (s *rbacServiceImpl) refreshGroupMembers(ctx context.Context, groupID domain.GroupID) error {
+	if s.deferFanOut {
+		return nil
+	}
+	return s.recomputeGroupMembers(ctx, groupID)
+}
+
+func (s *rbacServiceImpl) refreshGroupCount(ctx context.Context, groupID domain.GroupID) error {
+	if s.deferFanOut {
+		return nil
+	}
+	if _, err := s.repository.Group.RecountGroupMembers(ctx, groupID); err != nil {
+		return fmt.Errorf("failed to count members of group %s: %w", groupID, err)
+	}
+	return nil
+}
+
+// effectivePermissions computes the outcome of the user's global grants.
+func (s *rbacServiceImpl) effectivePermissions(ctx context.Context, userID domain.UserID) (*domain.EffectivePermissions, error) {
+	now := time.Now().UTC()
+
+	roles, err := s.userRoles(ctx, userID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
+	grants, err := s.roleGrants(ctx, roles, s.grantsOfRole)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := make(map[domain.PermissionID]bool)
+	denied := make(map[domain.PermissionID]bool)
+	conditional := make(map[domain.PermissionID]bool)
+	for _, g := range grants {
+		switch {
+		case g.Condition != "":
+			conditional[g.PermissionID] = true
+		case g.Effect == domain.EffectDeny:
+			denied[g.PermissionID] = true
+		default:
+			allowed[g.PermissionID] = true
+		}
+	}
+
+	// The permissions only hold until the next assignment starts or expires.
+	assignments, err := repository.CollectAll(ctx, func(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.RoleAssignment], error) {
+		return s.repository.User.ListUserAssignments(ctx, userID, page)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get assignments of %s: %w", userID, err)
+	}
+	var validUntil *time.Time
+	for _, a := range assignments {
+		if a.ResourceID != "" {
+			continue
+		}
+		for _, t := range []*time.Time{a.NotBefore, a.ExpiresAt} {
+			if t != nil && t.After(now) && (validUntil == nil || t.Before(*validUntil)) {
+				validUntil = t
+			}
+		}
+	}
+
+	return &domain.EffectivePermissions{
+		Allowed:     sortedPermissions(allowed),
+		Denied:      sortedPermissions(denied),
+		Conditional: sortedPermissions(conditional),
+		ComputedAt:  now,
+		ValidUntil:  validUntil,
+	}, nil
+}
+
+// roleGrants returns the grants of roles and every role they inherit from,
+// looking up the grants of each role with grantsOf.
+func (s *rbacServiceImpl) roleGrants(ctx context.Context, roles []*domain.Role, grantsOf func(context.Context, domain.RoleID) ([]*domain.PermissionGrant, error)) ([]*domain.PermissionGrant, error) {
+	roleIDs := make([]domain.RoleID, 0, len(roles))
+	for _, role := range roles {
+		roleIDs = append(roleIDs, role.ID)
+	}
+	// Roles inherit the permissions of their whole ancestor chain.
+	effectiveRoles, err := s.ancestorRoles(ctx, roleIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var grants []*domain.PermissionGrant
+	for roleID := range effectiveRoles {
+		roleGrants, err := grantsOf(ctx, roleID)
+		if err != nil {
+			// Skipping the role could miss one of its denies.
+			return nil, err
+		}
+		grants = append(grants, roleGrants...)
+	}
+	return grants, nil
+}
+
+func (s *rbacServiceImpl) grantsOfRole(ctx context.Context, roleID domain.RoleID) ([]*domain.PermissionGrant, error) {
+	grants, err := repository.CollectAll(ctx, func(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.PermissionGrant], error) {
+		return s.repository.Role.ListRoleGrants(ctx, roleID, page)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get grants of role %s: %w", roleID, err)
+	}
+	return grants, nil
+}
+
+// refreshUsers recomputes the effective permissions of userIDs. Permissions
+// that cannot be recomputed are cleared, so checks evaluate the user's grants
+// in full instead of trusting stale ones.
+func (s *rbacServiceImpl) refreshUsers(ctx context.Context, userIDs map[domain.UserID]bool) error {
+	var errs []error
+	for userID := range userIDs {
+		permissions, err := s.effectivePermissions(ctx, userID)
+		if err == nil {
+			err = s.repository.User.SetEffectivePermissions(ctx, userID, permissions)
+		}
+		// ErrConflict: the user is gone or newer permissions are already stored.
+		if err == nil || errors.Is(err, repository.ErrConflict) {
+			continue
+		}
+		errs = append(errs, fmt.Errorf("failed to recompute permissions of %s: %w", userID, err))
+		if err := s.repository.User.SetEffectivePermissions(ctx, userID, nil); err != nil && !errors.Is(err, repository.ErrConflict) {
+			errs = append(errs, fmt.Errorf("failed to clear permissions of %s: %w", userID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// recomputeRoleHolders recomputes the effective permissions of every user
+// holding roleIDs or a role inheriting from them, directly or through a group.
+func (s *rbacServiceImpl) recomputeRoleHolders(ctx context.Context, roleIDs ...domain.RoleID) error {
+	holders, err := s.roleHolders(ctx, roleIDs)
+	if err != nil {
+		return err
+	}
+	return s.refreshUsers(ctx, holders)
+}
+
+// recomputePermissionHolders recomputes the effective permissions of every
+// user holding a role with a grant of permissionID.
+func (s *rbacServiceImpl) recomputePermissionHolders(ctx context.Context, permissionID domain.PermissionID) error {
+	roles, err := repository.CollectAll(ctx, func(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.Role], error) {
+		return s.repository.Role.ListRolesWithPermission(ctx, permissionID, page)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get roles with permission %s: %w", permissionID, err)
+	}
+	roleIDs := make([]domain.RoleID, 0, len(roles))
+	for _, role := range roles {
+		roleIDs = append(roleIDs, role.ID)
+	}
+	return s.recomputeRoleHolders(ctx, roleIDs...)
+}
+
+func (s *rbacServiceImpl) recomputeGroupMembers(ctx context.Context, groupID domain.GroupID) error {
+	members, err := s.groupMembers(ctx, groupID)
+	if err != nil {
+		return err
+	}
+	return s.refreshUsers(ctx, members)
+}
+
+func (s *rbacServiceImpl) roleHolders(ctx context.Context, roleIDs []domain.RoleID) (map[domain.UserID]bool, error) {
+	roles, err := s.descendantRoles(ctx, roleIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	holders := make(map[domain.UserID]bool)
+	for roleID := range roles {
+		users, err := repository.CollectAll(ctx, func(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.User], error) {
+			return s.repository.User.ListUsersInRole(ctx, roleID, page)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get users in role %s: %w", roleID, err)
+		}
+		for _, user := range users {
+			holders[user.ID] = true
+		}
+
+		groups, err := repository.CollectAll(ctx, func(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.Group], error) {
+			return s.repository.Group.ListGroupsWithRole(ctx, roleID, page)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get groups with role %s: %w", roleID, err)
+		}
+		for _, group := range groups {
+			members, err := s.groupMembers(ctx, group.ID)
+			if err != nil {
+				return nil, err
+			}
+			for userID := range members {
+				holders[userID] = true
+			}
+		}
+	}
+	return holders, nil
+}
+
+func (s *rbacServiceImpl) groupMembers(ctx context.Context, groupID domain.GroupID) (map[domain.UserID]bool, error) {
+	users, err := repository.CollectAll(ctx, func(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.User], error) {
+		return s.repository.Group.ListGroupMembers(ctx, groupID, page)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get members of group %s: %w", groupID, err)
+	}
+	members := make(map[domain.UserID]bool, len(users))
+	for _, user := range users {
+		members[user.ID] = true
+	}
+	return members, nil
+}
+
+// descendantRoles returns roleIDs together with every role inheriting from
+// them, directly or transitively.
+func (s *rbacServiceImpl) descendantRoles(ctx context.Context, roleIDs []domain.RoleID) (map[domain.RoleID]bool, error) {
+	visited := make(map[domain.RoleID]bool, len(roleIDs))
+	queue := append([]domain.RoleID{}, roleIDs...)
+	for len(queue) > 0 {
+		roleID := queue[0]
+		queue = queue[1:]
+		if visited[roleID] {
+			continue
+		}
+		visited[roleID] = true
+
+		children, err := repository.CollectAll(ctx, func(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.Role], error) {
+			return s.repository.Role.GetChildRoles(ctx, roleID, page)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get child roles of %s: %w", roleID, err)
+		}
+		for _, child := range children {
+			queue = append(queue, child.ID)
+		}
+	}
+	return visited, nil
+}
+
+func userSet(userIDs ...domain.UserID) map[domain.UserID]bool {
+	set := make(map[domain.UserID]bool, len(userIDs))
+	for _, userID := range userIDs {
+		set[userID] = true
+	}
+	return set
+}
+
+func sortedPermissions(set map[domain.PermissionID]bool) []domain.PermissionID {
+	ids := make([]domain.PermissionID, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
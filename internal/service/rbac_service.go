@@ -6,6 +6,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidAssignment = errors.New("invalid role assignment")
+	ErrInvalidPermission = errors.New("invalid permission")
+	ErrInvalidGrant      = errors.New("invalid permission grant")
+	ErrRoleCycle         = errors.New("role inheritance cycle")
 )
 
 // RBACService provides methods for managing users, roles, permissions, and checking access.
@@ -13,36 +23,99 @@ type RBACService interface {
 	// User Management
 	CreateUser(ctx context.Context, displayName, email string) (*domain.User, error)
 	GetUser(ctx context.Context, userID domain.UserID) (*domain.User, error)
-	AssignRoleToUser(ctx context.Context, userID domain.UserID, roleID domain.RoleID) error
+	UpdateUser(ctx context.Context, user *domain.User) error
+	DeleteUser(ctx context.Context, userID domain.UserID) error
+	RestoreUser(ctx context.Context, userID domain.UserID) error
+	AssignRoleToUser(ctx context.Context, assignment *domain.RoleAssignment) error
 	RemoveRoleFromUser(ctx context.Context, userID domain.UserID, roleID domain.RoleID) error
-	GetUserRoles(ctx context.Context, userID domain.UserID) ([]*domain.Role, error)
+	GetUserRoles(ctx context.Context, userID domain.UserID, page repository.PageRequest) (*repository.Page[*domain.Role], error)
+	RemoveRoleFromUserOnResource(ctx context.Context, userID domain.UserID, roleID domain.RoleID, resourceID string) error
+	GetUserRolesOnResource(ctx context.Context, userID domain.UserID, resourceID string, page repository.PageRequest) (*repository.Page[*domain.Role], error)
+	ListUserResources(ctx context.Context, userID domain.UserID, prefix string, page repository.PageRequest) (*repository.Page[string], error)
+	ListUserAssignments(ctx context.Context, userID domain.UserID, page repository.PageRequest) (*repository.Page[*domain.RoleAssignment], error)
 
 	// // Role Management
 	CreateRole(ctx context.Context, displayName, description string) (*domain.Role, error)
-	// GetRole(ctx context.Context, roleID domain.RoleID) (*domain.Role, error)
-	AssignPermissionToRole(ctx context.Context, roleID domain.RoleID, permissionID domain.PermissionID) error
-	// RemovePermissionFromRole(ctx context.Context, roleID domain.RoleID, permissionID domain.PermissionID) error
-	// GetRolePermissions(ctx context.Context, roleID domain.RoleID) ([]*domain.Permission, error)
+	GetRole(ctx context.Context, roleID domain.RoleID) (*domain.Role, error)
+	UpdateRole(ctx context.Context, role *domain.Role) error
+	DeleteRole(ctx context.Context, roleID domain.RoleID) error
+	RestoreRole(ctx context.Context, roleID domain.RoleID) error
+	AssignPermissionToRole(ctx context.Context, grant *domain.PermissionGrant) error
+	RemovePermissionFromRole(ctx context.Context, roleID domain.RoleID, permissionID domain.PermissionID) error
+	GetRolePermissions(ctx context.Context, roleID domain.RoleID, page repository.PageRequest) (*repository.Page[*domain.Permission], error)
+	GetRoleGrants(ctx context.Context, roleID domain.RoleID, page repository.PageRequest) (*repository.Page[*domain.PermissionGrant], error)
+	AddParentRole(ctx context.Context, roleID domain.RoleID, parentID domain.RoleID) error
+	RemoveParentRole(ctx context.Context, roleID domain.RoleID, parentID domain.RoleID) error
+	GetParentRoles(ctx context.Context, roleID domain.RoleID, page repository.PageRequest) (*repository.Page[*domain.Role], error)
 
 	// // Permission Management
 	CreatePermission(ctx context.Context, id domain.PermissionID, displayName, description string) (*domain.Permission, error) // ID is often predefined string
-	// GetPermission(ctx context.Context, permissionID domain.PermissionID) (*domain.Permission, error)
-	GetAllPermissions(ctx context.Context) ([]*domain.Permission, error)
-	GetAllRoles(ctx context.Context) ([]*domain.Role, error)
+	GetPermission(ctx context.Context, permissionID domain.PermissionID) (*domain.Permission, error)
+	UpdatePermission(ctx context.Context, permission *domain.Permission) error
+	DeletePermission(ctx context.Context, permissionID domain.PermissionID) error
+	RestorePermission(ctx context.Context, permissionID domain.PermissionID) error
+	GetAllPermissions(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.Permission], error)
+	GetAllRoles(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.Role], error)
+
+	// // Group Management
+	CreateGroup(ctx context.Context, displayName, description string) (*domain.Group, error)
+	GetGroup(ctx context.Context, groupID domain.GroupID) (*domain.Group, error)
+	DeleteGroup(ctx context.Context, groupID domain.GroupID) error
+	GetAllGroups(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.Group], error)
+	AddUserToGroup(ctx context.Context, userID domain.UserID, groupID domain.GroupID) error
+	RemoveUserFromGroup(ctx context.Context, userID domain.UserID, groupID domain.GroupID) error
+	GetUserGroups(ctx context.Context, userID domain.UserID, page repository.PageRequest) (*repository.Page[*domain.Group], error)
+	GetGroupMembers(ctx context.Context, groupID domain.GroupID, page repository.PageRequest) (*repository.Page[*domain.User], error)
+	AssignRoleToGroup(ctx context.Context, groupID domain.GroupID, roleID domain.RoleID) error
+	RemoveRoleFromGroup(ctx context.Context, groupID domain.GroupID, roleID domain.RoleID) error
+	GetGroupRoles(ctx context.Context, groupID domain.GroupID, page repository.PageRequest) (*repository.Page[*domain.Role], error)
 
 	// // Authorization
 	UserHasPermission(ctx context.Context, userID domain.UserID, permissionID domain.PermissionID) (bool, error)
+	UserHasPermissionOnResource(ctx context.Context, userID domain.UserID, permissionID domain.PermissionID, resourceID string) (bool, error)
+	CheckAccess(ctx context.Context, request *domain.AccessRequest) (*domain.Decision, error)
+
+	// // Derived data
+	RecomputeEffectivePermissions(ctx context.Context, userID domain.UserID) error
+	RecomputeRoleHolders(ctx context.Context, roleID domain.RoleID) error
+	RecomputePermissionHolders(ctx context.Context, permissionID domain.PermissionID) error
+	RecomputeGroupMembers(ctx context.Context, groupID domain.GroupID) error
+	RecountGroupMembers(ctx context.Context, groupID domain.GroupID) error
+
+	// Cache
+	CacheStats() CacheStats
 }
 
 type rbacServiceImpl struct {
 	repository repository.Repository
 	// idGenerator func() string // For generating IDs if not client-provided
+
+	deferFanOut bool
+	cache       *decisionCache
 }
 
-func NewRBACService(repository repository.Repository) RBACService {
-	return &rbacServiceImpl{
+// Option configures the service returned by NewRBACService.
+type Option func(*rbacServiceImpl)
+
+// WithDeferredFanOut leaves the derived data of other users than the one a
+// change names to the change-stream processor (cmd/processor): changes to a
+// role, a permission or a group's roles return without recomputing the
+// effective permissions of their holders, and group member counts are not
+// recounted.
+func WithDeferredFanOut() Option {
+	return func(s *rbacServiceImpl) {
+		s.deferFanOut = true
+	}
+}
+
+func NewRBACService(repository repository.Repository, options ...Option) RBACService {
+	s := &rbacServiceImpl{
 		repository: repository,
 	}
+	for _, option := range options {
+		option(s)
+	}
+	return s
 }
 
 // --- User Management Methods ---
@@ -54,6 +127,7 @@ func (s *rbacServiceImpl) CreateUser(ctx context.Context, displayName, email str
 		ID:          userID,
 		DisplayName: displayName,
 		Email:       email,
+		Permissions: &domain.EffectivePermissions{ComputedAt: time.Now().UTC()}, // No roles yet
 	}
 	if err := s.repository.User.CreateUser(ctx, user); err != nil {
 		return nil, fmt.Errorf("service.CreateUser: %w", err)
@@ -69,20 +143,67 @@ func (s *rbacServiceImpl) GetUser(ctx context.Context, userID domain.UserID) (*d
 	return user, nil
 }
 
-func (s *rbacServiceImpl) AssignRoleToUser(ctx context.Context, userID domain.UserID, roleID domain.RoleID) error {
+// UpdateUser saves the metadata of user. user.Version must match the stored
+// version, otherwise repository.ErrConflict is returned.
+func (s *rbacServiceImpl) UpdateUser(ctx context.Context, user *domain.User) error {
+	if err := s.repository.User.UpdateUser(ctx, user); err != nil {
+		return fmt.Errorf("service.UpdateUser: %w", err)
+	}
+	return nil
+}
+
+// DeleteUser soft-deletes the user. Its role assignments are kept so it can be
+// restored until the retention window passes.
+func (s *rbacServiceImpl) DeleteUser(ctx context.Context, userID domain.UserID) error {
+	if err := s.repository.User.SoftDeleteUser(ctx, userID); err != nil {
+		return fmt.Errorf("service.DeleteUser: %w", err)
+	}
+	return nil
+}
+
+func (s *rbacServiceImpl) RestoreUser(ctx context.Context, userID domain.UserID) error {
+	if err := s.repository.User.RestoreUser(ctx, userID); err != nil {
+		return fmt.Errorf("service.RestoreUser: %w", err)
+	}
+	return nil
+}
+
+// AssignRoleToUser grants assignment.RoleID to assignment.UserID, on
+// assignment.ResourceID only when it is set. The optional NotBefore/ExpiresAt
+// window must not be empty or already over.
+func (s *rbacServiceImpl) AssignRoleToUser(ctx context.Context, assignment *domain.RoleAssignment) error {
+	assignment.ResourceID = domain.CleanResourcePath(assignment.ResourceID)
+	if strings.Contains(assignment.ResourceID, "#") {
+		return fmt.Errorf("service.AssignRoleToUser: %w: resourceId must not contain '#'", ErrInvalidAssignment)
+	}
+	if assignment.ExpiresAt != nil {
+		if !assignment.ExpiresAt.After(time.Now()) {
+			return fmt.Errorf("service.AssignRoleToUser: %w: expiresAt is in the past", ErrInvalidAssignment)
+		}
+		if assignment.NotBefore != nil && !assignment.ExpiresAt.After(*assignment.NotBefore) {
+			return fmt.Errorf("service.AssignRoleToUser: %w: expiresAt must be after notBefore", ErrInvalidAssignment)
+		}
+	}
+
 	// Optional: Check if user and role exist before assigning
-	_, err := s.repository.User.GetUserByID(ctx, userID)
+	_, err := s.repository.User.GetUserByID(ctx, assignment.UserID)
 	if err != nil {
 		return fmt.Errorf("service.AssignRoleToUser: user not found: %w", err)
 	}
-	_, err = s.repository.Role.GetRoleByID(ctx, roleID)
+	_, err = s.repository.Role.GetRoleByID(ctx, assignment.RoleID)
 	if err != nil {
 		return fmt.Errorf("service.AssignRoleToUser: role not found: %w", err)
 	}
 
-	if err := s.repository.User.AssignRoleToUser(ctx, userID, roleID); err != nil {
+	if err := s.repository.User.AssignRoleToUser(ctx, assignment); err != nil {
 		return fmt.Errorf("service.AssignRoleToUser: %w", err)
 	}
+	if assignment.ResourceID == "" {
+		s.forgetUserRoles(ctx, assignment.UserID)
+		if err := s.refreshUsers(ctx, userSet(assignment.UserID)); err != nil {
+			return fmt.Errorf("service.AssignRoleToUser: %w", err)
+		}
+	}
 	return nil
 }
 
@@ -90,17 +211,102 @@ func (s *rbacServiceImpl) RemoveRoleFromUser(ctx context.Context, userID domain.
 	if err := s.repository.User.RemoveRoleFromUser(ctx, userID, roleID); err != nil {
 		return fmt.Errorf("service.RemoveRoleFromUser: %w", err)
 	}
+	s.forgetUserRoles(ctx, userID)
+	if err := s.refreshUsers(ctx, userSet(userID)); err != nil {
+		return fmt.Errorf("service.RemoveRoleFromUser: %w", err)
+	}
 	return nil
 }
 
-func (s *rbacServiceImpl) GetUserRoles(ctx context.Context, userID domain.UserID) ([]*domain.Role, error) {
-	roles, err := s.repository.User.GetUserRoles(ctx, userID)
+// GetUserRoles returns the roles assigned to the user directly and those it
+// holds through its groups.
+func (s *rbacServiceImpl) GetUserRoles(ctx context.Context, userID domain.UserID, page repository.PageRequest) (*repository.Page[*domain.Role], error) {
+	roles, err := s.cachedUserRoles(ctx, userID)
 	if err != nil {
 		return nil, fmt.Errorf("service.GetUserRoles: %w", err)
 	}
+	result, err := repository.PageSlice(roles, page)
+	if err != nil {
+		return nil, fmt.Errorf("service.GetUserRoles: %w", err)
+	}
+	return result, nil
+}
+
+// userRoles collects the user's active direct roles followed by the roles of
+// every group it belongs to, without duplicates.
+func (s *rbacServiceImpl) userRoles(ctx context.Context, userID domain.UserID) ([]*domain.Role, error) {
+	roles, err := repository.CollectAll(ctx, func(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.Role], error) {
+		return s.repository.User.GetUserRoles(ctx, userID, page)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get roles of %s: %w", userID, err)
+	}
+
+	groups, err := repository.CollectAll(ctx, func(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.Group], error) {
+		return s.repository.Group.GetUserGroups(ctx, userID, page)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get groups of %s: %w", userID, err)
+	}
+
+	seen := make(map[domain.RoleID]bool, len(roles))
+	for _, role := range roles {
+		seen[role.ID] = true
+	}
+	for _, group := range groups {
+		groupRoles, err := repository.CollectAll(ctx, func(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.Role], error) {
+			return s.repository.Group.GetGroupRoles(ctx, group.ID, page)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get roles of group %s: %w", group.ID, err)
+		}
+		for _, role := range groupRoles {
+			if !seen[role.ID] {
+				seen[role.ID] = true
+				roles = append(roles, role)
+			}
+		}
+	}
 	return roles, nil
 }
 
+func (s *rbacServiceImpl) RemoveRoleFromUserOnResource(ctx context.Context, userID domain.UserID, roleID domain.RoleID, resourceID string) error {
+	if err := s.repository.User.RemoveRoleFromUserOnResource(ctx, userID, roleID, domain.CleanResourcePath(resourceID)); err != nil {
+		return fmt.Errorf("service.RemoveRoleFromUserOnResource: %w", err)
+	}
+	return nil
+}
+
+// GetUserRolesOnResource returns the roles assigned to the user on resourceID
+// itself, without the ones it holds globally or on ancestors of resourceID.
+func (s *rbacServiceImpl) GetUserRolesOnResource(ctx context.Context, userID domain.UserID, resourceID string, page repository.PageRequest) (*repository.Page[*domain.Role], error) {
+	roles, err := s.repository.User.GetUserRolesOnResource(ctx, userID, domain.CleanResourcePath(resourceID), page)
+	if err != nil {
+		return nil, fmt.Errorf("service.GetUserRolesOnResource: %w", err)
+	}
+	return roles, nil
+}
+
+// ListUserResources returns the resource paths under prefix the user has been
+// assigned roles on.
+func (s *rbacServiceImpl) ListUserResources(ctx context.Context, userID domain.UserID, prefix string, page repository.PageRequest) (*repository.Page[string], error) {
+	resources, err := s.repository.User.ListUserResources(ctx, userID, strings.TrimPrefix(prefix, "/"), page)
+	if err != nil {
+		return nil, fmt.Errorf("service.ListUserResources: %w", err)
+	}
+	return resources, nil
+}
+
+// ListUserAssignments returns the user's role assignments with their
+// provenance, including expired and not yet active ones.
+func (s *rbacServiceImpl) ListUserAssignments(ctx context.Context, userID domain.UserID, page repository.PageRequest) (*repository.Page[*domain.RoleAssignment], error) {
+	assignments, err := s.repository.User.ListUserAssignments(ctx, userID, page)
+	if err != nil {
+		return nil, fmt.Errorf("service.ListUserAssignments: %w", err)
+	}
+	return assignments, nil
+}
+
 // --- Role Management Methods (implement similarly) ---
 func (s *rbacServiceImpl) CreateRole(ctx context.Context, displayName, description string) (*domain.Role, error) {
 	roleID := domain.RoleID("role-" + displayName) // Simplistic
@@ -115,29 +321,189 @@ func (s *rbacServiceImpl) CreateRole(ctx context.Context, displayName, descripti
 	return role, nil
 }
 
-// ... other role methods
+func (s *rbacServiceImpl) GetRole(ctx context.Context, roleID domain.RoleID) (*domain.Role, error) {
+	role, err := s.repository.Role.GetRoleByID(ctx, roleID)
+	if err != nil {
+		return nil, fmt.Errorf("service.GetRole: %w", err)
+	}
+	return role, nil
+}
+
+// UpdateRole saves the metadata of role. role.Version must match the stored
+// version, otherwise repository.ErrConflict is returned.
+func (s *rbacServiceImpl) UpdateRole(ctx context.Context, role *domain.Role) error {
+	if err := s.repository.Role.UpdateRole(ctx, role); err != nil {
+		return fmt.Errorf("service.UpdateRole: %w", err)
+	}
+	return nil
+}
+
+// DeleteRole soft-deletes the role. While deleted it grants nothing, but its
+// permission and user assignments are kept so it can be restored.
+func (s *rbacServiceImpl) DeleteRole(ctx context.Context, roleID domain.RoleID) error {
+	if err := s.repository.Role.SoftDeleteRole(ctx, roleID); err != nil {
+		return fmt.Errorf("service.DeleteRole: %w", err)
+	}
+	s.forgetAllUserRoles(ctx)
+	if err := s.refreshRoleHolders(ctx, roleID); err != nil {
+		return fmt.Errorf("service.DeleteRole: %w", err)
+	}
+	return nil
+}
+
+func (s *rbacServiceImpl) RestoreRole(ctx context.Context, roleID domain.RoleID) error {
+	if err := s.repository.Role.RestoreRole(ctx, roleID); err != nil {
+		return fmt.Errorf("service.RestoreRole: %w", err)
+	}
+	s.forgetAllUserRoles(ctx)
+	if err := s.refreshRoleHolders(ctx, roleID); err != nil {
+		return fmt.Errorf("service.RestoreRole: %w", err)
+	}
+	return nil
+}
+
+// AssignPermissionToRole allows, or with EffectDeny denies, the permission to
+// every holder of the role. Grants without an effect allow.
+func (s *rbacServiceImpl) AssignPermissionToRole(ctx context.Context, grant *domain.PermissionGrant) error {
+	switch grant.Effect {
+	case "":
+		grant.Effect = domain.EffectAllow
+	case domain.EffectAllow, domain.EffectDeny:
+	default:
+		return fmt.Errorf("service.AssignPermissionToRole: %w: unknown effect %q", ErrInvalidGrant, grant.Effect)
+	}
+	if err := grant.Condition.Validate(); err != nil {
+		return fmt.Errorf("service.AssignPermissionToRole: %w: %v", ErrInvalidGrant, err)
+	}
 
-func (s *rbacServiceImpl) AssignPermissionToRole(ctx context.Context, roleID domain.RoleID, permissionID domain.PermissionID) error {
 	// Optional: Check if role and permission exist
-	_, err := s.repository.Role.GetRoleByID(ctx, roleID)
+	_, err := s.repository.Role.GetRoleByID(ctx, grant.RoleID)
 	if err != nil {
 		return fmt.Errorf("service.AssignPermissionToRole: role not found: %w", err)
 	}
-	_, err = s.repository.Permission.GetPermissionByID(ctx, permissionID)
+	_, err = s.repository.Permission.GetPermissionByID(ctx, grant.PermissionID)
 	if err != nil {
 		return fmt.Errorf("service.AssignPermissionToRole: permission not found: %w", err)
 	}
 
-	if err := s.repository.Role.AssignPermissionToRole(ctx, roleID, permissionID); err != nil {
+	if err := s.repository.Role.AssignPermissionToRole(ctx, grant); err != nil {
+		return fmt.Errorf("service.AssignPermissionToRole: %w", err)
+	}
+	s.forgetRoleGrants(ctx, grant.RoleID)
+	if err := s.refreshRoleHolders(ctx, grant.RoleID); err != nil {
 		return fmt.Errorf("service.AssignPermissionToRole: %w", err)
 	}
 	return nil
 }
 
-// ... other role methods
+func (s *rbacServiceImpl) RemovePermissionFromRole(ctx context.Context, roleID domain.RoleID, permissionID domain.PermissionID) error {
+	if err := s.repository.Role.RemovePermissionFromRole(ctx, roleID, permissionID); err != nil {
+		return fmt.Errorf("service.RemovePermissionFromRole: %w", err)
+	}
+	s.forgetRoleGrants(ctx, roleID)
+	if err := s.refreshRoleHolders(ctx, roleID); err != nil {
+		return fmt.Errorf("service.RemovePermissionFromRole: %w", err)
+	}
+	return nil
+}
+
+func (s *rbacServiceImpl) GetRolePermissions(ctx context.Context, roleID domain.RoleID, page repository.PageRequest) (*repository.Page[*domain.Permission], error) {
+	permissions, err := s.repository.Role.GetRolePermissions(ctx, roleID, page)
+	if err != nil {
+		return nil, fmt.Errorf("service.GetRolePermissions: %w", err)
+	}
+	return permissions, nil
+}
+
+func (s *rbacServiceImpl) GetRoleGrants(ctx context.Context, roleID domain.RoleID, page repository.PageRequest) (*repository.Page[*domain.PermissionGrant], error) {
+	grants, err := s.repository.Role.ListRoleGrants(ctx, roleID, page)
+	if err != nil {
+		return nil, fmt.Errorf("service.GetRoleGrants: %w", err)
+	}
+	return grants, nil
+}
+
+// AddParentRole makes roleID inherit every permission of parentID and its
+// ancestors. It fails with ErrRoleCycle if parentID already inherits from roleID.
+func (s *rbacServiceImpl) AddParentRole(ctx context.Context, roleID domain.RoleID, parentID domain.RoleID) error {
+	if roleID == parentID {
+		return fmt.Errorf("service.AddParentRole: %w: a role cannot inherit from itself", ErrRoleCycle)
+	}
+	if _, err := s.repository.Role.GetRoleByID(ctx, roleID); err != nil {
+		return fmt.Errorf("service.AddParentRole: role not found: %w", err)
+	}
+	if _, err := s.repository.Role.GetRoleByID(ctx, parentID); err != nil {
+		return fmt.Errorf("service.AddParentRole: parent role not found: %w", err)
+	}
+
+	ancestors, err := s.ancestorRoles(ctx, []domain.RoleID{parentID})
+	if err != nil {
+		return fmt.Errorf("service.AddParentRole: %w", err)
+	}
+	if ancestors[roleID] {
+		return fmt.Errorf("service.AddParentRole: %w: %s already inherits from %s", ErrRoleCycle, parentID, roleID)
+	}
+
+	if err := s.repository.Role.AddParentRole(ctx, roleID, parentID); err != nil {
+		return fmt.Errorf("service.AddParentRole: %w", err)
+	}
+	if err := s.refreshRoleHolders(ctx, roleID); err != nil {
+		return fmt.Errorf("service.AddParentRole: %w", err)
+	}
+	return nil
+}
+
+func (s *rbacServiceImpl) RemoveParentRole(ctx context.Context, roleID domain.RoleID, parentID domain.RoleID) error {
+	if err := s.repository.Role.RemoveParentRole(ctx, roleID, parentID); err != nil {
+		return fmt.Errorf("service.RemoveParentRole: %w", err)
+	}
+	if err := s.refreshRoleHolders(ctx, roleID); err != nil {
+		return fmt.Errorf("service.RemoveParentRole: %w", err)
+	}
+	return nil
+}
+
+func (s *rbacServiceImpl) GetParentRoles(ctx context.Context, roleID domain.RoleID, page repository.PageRequest) (*repository.Page[*domain.Role], error) {
+	roles, err := s.repository.Role.GetParentRoles(ctx, roleID, page)
+	if err != nil {
+		return nil, fmt.Errorf("service.GetParentRoles: %w", err)
+	}
+	return roles, nil
+}
+
+// ancestorRoles returns roleIDs together with every role they inherit from,
+// directly or transitively.
+func (s *rbacServiceImpl) ancestorRoles(ctx context.Context, roleIDs []domain.RoleID) (map[domain.RoleID]bool, error) {
+	visited := make(map[domain.RoleID]bool, len(roleIDs))
+	queue := append([]domain.RoleID{}, roleIDs...)
+	for len(queue) > 0 {
+		roleID := queue[0]
+		queue = queue[1:]
+		if visited[roleID] {
+			continue
+		}
+		visited[roleID] = true
+
+		parents, err := repository.CollectAll(ctx, func(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.Role], error) {
+			return s.repository.Role.GetParentRoles(ctx, roleID, page)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get parent roles of %s: %w", roleID, err)
+		}
+		for _, parent := range parents {
+			queue = append(queue, parent.ID)
+		}
+	}
+	return visited, nil
+}
 
 // --- Permission Management Methods (implement similarly) ---
+// CreatePermission creates a permission. Its ID may use * segments to create a
+// wildcard permission that grants every permission it matches.
 func (s *rbacServiceImpl) CreatePermission(ctx context.Context, id domain.PermissionID, displayName, description string) (*domain.Permission, error) {
+	if !id.ValidPattern() {
+		return nil, fmt.Errorf("service.CreatePermission: %w: * must be a whole segment in %s", ErrInvalidPermission, id)
+	}
 	permission := &domain.Permission{
 		ID:          id, // Permissions often have predefined string IDs like "document:create"
 		DisplayName: displayName,
@@ -149,43 +515,365 @@ func (s *rbacServiceImpl) CreatePermission(ctx context.Context, id domain.Permis
 	return permission, nil
 }
 
-// ... other permission methods
+func (s *rbacServiceImpl) GetPermission(ctx context.Context, permissionID domain.PermissionID) (*domain.Permission, error) {
+	permission, err := s.repository.Permission.GetPermissionByID(ctx, permissionID)
+	if err != nil {
+		return nil, fmt.Errorf("service.GetPermission: %w", err)
+	}
+	return permission, nil
+}
+
+// UpdatePermission saves the metadata of permission. permission.Version must
+// match the stored version, otherwise repository.ErrConflict is returned.
+func (s *rbacServiceImpl) UpdatePermission(ctx context.Context, permission *domain.Permission) error {
+	if err := s.repository.Permission.UpdatePermission(ctx, permission); err != nil {
+		return fmt.Errorf("service.UpdatePermission: %w", err)
+	}
+	return nil
+}
+
+// DeletePermission soft-deletes the permission. While deleted no role grants
+// it, but its role assignments are kept so it can be restored.
+func (s *rbacServiceImpl) DeletePermission(ctx context.Context, permissionID domain.PermissionID) error {
+	if err := s.repository.Permission.SoftDeletePermission(ctx, permissionID); err != nil {
+		return fmt.Errorf("service.DeletePermission: %w", err)
+	}
+	s.forgetAllRoleGrants(ctx)
+	if err := s.refreshPermissionHolders(ctx, permissionID); err != nil {
+		return fmt.Errorf("service.DeletePermission: %w", err)
+	}
+	return nil
+}
+
+func (s *rbacServiceImpl) RestorePermission(ctx context.Context, permissionID domain.PermissionID) error {
+	if err := s.repository.Permission.RestorePermission(ctx, permissionID); err != nil {
+		return fmt.Errorf("service.RestorePermission: %w", err)
+	}
+	s.forgetAllRoleGrants(ctx)
+	if err := s.refreshPermissionHolders(ctx, permissionID); err != nil {
+		return fmt.Errorf("service.RestorePermission: %w", err)
+	}
+	return nil
+}
+
+func (s *rbacServiceImpl) GetAllPermissions(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.Permission], error) {
+	return s.repository.Permission.ListAllPermissions(ctx, page)
+}
 
-func (s *rbacServiceImpl) GetAllPermissions(ctx context.Context) ([]*domain.Permission, error) {
-	return s.repository.Permission.ListAllPermissions(ctx)
+func (s *rbacServiceImpl) GetAllRoles(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.Role], error) {
+	return s.repository.Role.ListAllRoles(ctx, page)
 }
 
-func (s *rbacServiceImpl) GetAllRoles(ctx context.Context) ([]*domain.Role, error) {
-	return s.repository.Role.ListAllRoles(ctx)
+// --- Group Management Methods ---
+func (s *rbacServiceImpl) CreateGroup(ctx context.Context, displayName, description string) (*domain.Group, error) {
+	groupID := domain.GroupID("group-" + displayName) // Simplistic
+	group := &domain.Group{
+		ID:          groupID,
+		DisplayName: displayName,
+		Description: description,
+	}
+	if err := s.repository.Group.CreateGroup(ctx, group); err != nil {
+		return nil, fmt.Errorf("service.CreateGroup: %w", err)
+	}
+	return group, nil
+}
+
+func (s *rbacServiceImpl) GetGroup(ctx context.Context, groupID domain.GroupID) (*domain.Group, error) {
+	group, err := s.repository.Group.GetGroupByID(ctx, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("service.GetGroup: %w", err)
+	}
+	return group, nil
+}
+
+// DeleteGroup removes the group together with its memberships and role
+// assignments; its members lose the roles they held through it.
+func (s *rbacServiceImpl) DeleteGroup(ctx context.Context, groupID domain.GroupID) error {
+	// The memberships are gone once the group is deleted.
+	var members map[domain.UserID]bool
+	if !s.deferFanOut {
+		var err error
+		if members, err = s.groupMembers(ctx, groupID); err != nil {
+			return fmt.Errorf("service.DeleteGroup: %w", err)
+		}
+	}
+	if err := s.repository.Group.DeleteGroup(ctx, groupID); err != nil {
+		return fmt.Errorf("service.DeleteGroup: %w", err)
+	}
+	s.forgetAllUserRoles(ctx)
+	if err := s.refreshUsers(ctx, members); err != nil {
+		return fmt.Errorf("service.DeleteGroup: %w", err)
+	}
+	return nil
+}
+
+func (s *rbacServiceImpl) GetAllGroups(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.Group], error) {
+	return s.repository.Group.ListAllGroups(ctx, page)
+}
+
+func (s *rbacServiceImpl) AddUserToGroup(ctx context.Context, userID domain.UserID, groupID domain.GroupID) error {
+	if _, err := s.repository.User.GetUserByID(ctx, userID); err != nil {
+		return fmt.Errorf("service.AddUserToGroup: user not found: %w", err)
+	}
+	if _, err := s.repository.Group.GetGroupByID(ctx, groupID); err != nil {
+		return fmt.Errorf("service.AddUserToGroup: group not found: %w", err)
+	}
+
+	if err := s.repository.Group.AddUserToGroup(ctx, userID, groupID); err != nil {
+		return fmt.Errorf("service.AddUserToGroup: %w", err)
+	}
+	s.forgetUserRoles(ctx, userID)
+	if err := s.refreshUsers(ctx, userSet(userID)); err != nil {
+		return fmt.Errorf("service.AddUserToGroup: %w", err)
+	}
+	if err := s.refreshGroupCount(ctx, groupID); err != nil {
+		return fmt.Errorf("service.AddUserToGroup: %w", err)
+	}
+	return nil
+}
+
+func (s *rbacServiceImpl) RemoveUserFromGroup(ctx context.Context, userID domain.UserID, groupID domain.GroupID) error {
+	if err := s.repository.Group.RemoveUserFromGroup(ctx, userID, groupID); err != nil {
+		return fmt.Errorf("service.RemoveUserFromGroup: %w", err)
+	}
+	s.forgetUserRoles(ctx, userID)
+	if err := s.refreshUsers(ctx, userSet(userID)); err != nil {
+		return fmt.Errorf("service.RemoveUserFromGroup: %w", err)
+	}
+	if err := s.refreshGroupCount(ctx, groupID); err != nil {
+		return fmt.Errorf("service.RemoveUserFromGroup: %w", err)
+	}
+	return nil
+}
+
+func (s *rbacServiceImpl) GetUserGroups(ctx context.Context, userID domain.UserID, page repository.PageRequest) (*repository.Page[*domain.Group], error) {
+	groups, err := s.repository.Group.GetUserGroups(ctx, userID, page)
+	if err != nil {
+		return nil, fmt.Errorf("service.GetUserGroups: %w", err)
+	}
+	return groups, nil
+}
+
+func (s *rbacServiceImpl) GetGroupMembers(ctx context.Context, groupID domain.GroupID, page repository.PageRequest) (*repository.Page[*domain.User], error) {
+	users, err := s.repository.Group.ListGroupMembers(ctx, groupID, page)
+	if err != nil {
+		return nil, fmt.Errorf("service.GetGroupMembers: %w", err)
+	}
+	return users, nil
+}
+
+// AssignRoleToGroup grants roleID to every current and future member of groupID.
+func (s *rbacServiceImpl) AssignRoleToGroup(ctx context.Context, groupID domain.GroupID, roleID domain.RoleID) error {
+	if _, err := s.repository.Group.GetGroupByID(ctx, groupID); err != nil {
+		return fmt.Errorf("service.AssignRoleToGroup: group not found: %w", err)
+	}
+	if _, err := s.repository.Role.GetRoleByID(ctx, roleID); err != nil {
+		return fmt.Errorf("service.AssignRoleToGroup: role not found: %w", err)
+	}
+
+	if err := s.repository.Group.AssignRoleToGroup(ctx, groupID, roleID); err != nil {
+		return fmt.Errorf("service.AssignRoleToGroup: %w", err)
+	}
+	s.forgetAllUserRoles(ctx)
+	if err := s.refreshGroupMembers(ctx, groupID); err != nil {
+		return fmt.Errorf("service.AssignRoleToGroup: %w", err)
+	}
+	return nil
+}
+
+func (s *rbacServiceImpl) RemoveRoleFromGroup(ctx context.Context, groupID domain.GroupID, roleID domain.RoleID) error {
+	if err := s.repository.Group.RemoveRoleFromGroup(ctx, groupID, roleID); err != nil {
+		return fmt.Errorf("service.RemoveRoleFromGroup: %w", err)
+	}
+	s.forgetAllUserRoles(ctx)
+	if err := s.refreshGroupMembers(ctx, groupID); err != nil {
+		return fmt.Errorf("service.RemoveRoleFromGroup: %w", err)
+	}
+	return nil
+}
+
+func (s *rbacServiceImpl) GetGroupRoles(ctx context.Context, groupID domain.GroupID, page repository.PageRequest) (*repository.Page[*domain.Role], error) {
+	roles, err := s.repository.Group.GetGroupRoles(ctx, groupID, page)
+	if err != nil {
+		return nil, fmt.Errorf("service.GetGroupRoles: %w", err)
+	}
+	return roles, nil
 }
 
 // --- Authorization Method ---
+
+// UserHasPermission reports whether the user holds targetPermissionID through
+// its global role assignments, directly or through its groups.
 func (s *rbacServiceImpl) UserHasPermission(ctx context.Context, userID domain.UserID, targetPermissionID domain.PermissionID) (bool, error) {
-	roles, err := s.repository.User.GetUserRoles(ctx, userID)
+	decision, err := s.decide(ctx, &domain.AccessRequest{UserID: userID, PermissionID: targetPermissionID})
+	if err != nil {
+		return false, fmt.Errorf("service.UserHasPermission: %w", err)
+	}
+	return decision.Allowed, nil
+}
+
+// UserHasPermissionOnResource reports whether the user holds
+// targetPermissionID on resourceID, through a role assigned on that resource
+// path or one of its ancestors, or through a global grant.
+func (s *rbacServiceImpl) UserHasPermissionOnResource(ctx context.Context, userID domain.UserID, targetPermissionID domain.PermissionID, resourceID string) (bool, error) {
+	decision, err := s.decide(ctx, &domain.AccessRequest{UserID: userID, PermissionID: targetPermissionID, ResourceID: resourceID})
+	if err != nil {
+		return false, fmt.Errorf("service.UserHasPermissionOnResource: %w", err)
+	}
+	return decision.Allowed, nil
+}
+
+// CheckAccess decides the request, evaluating grant conditions against its
+// attributes, and reports which condition failed when access is refused.
+func (s *rbacServiceImpl) CheckAccess(ctx context.Context, request *domain.AccessRequest) (*domain.Decision, error) {
+	decision, err := s.decide(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("service.CheckAccess: %w", err)
+	}
+	return decision, nil
+}
+
+// rolesCoveringResource returns the roles assigned to the user on path or any
+// of its ancestors. A single begins_with query on the path's root finds the
+// user's resources in that subtree, so only ancestors the user actually has
+// roles on are read.
+func (s *rbacServiceImpl) rolesCoveringResource(ctx context.Context, userID domain.UserID, path string) ([]*domain.Role, error) {
+	resources, err := repository.CollectAll(ctx, func(ctx context.Context, page repository.PageRequest) (*repository.Page[string], error) {
+		return s.repository.User.ListUserResources(ctx, userID, domain.ResourceRoot(path), page)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get resources of %s: %w", userID, err)
+	}
+
+	var roles []*domain.Role
+	for _, resource := range resources {
+		if !domain.ResourceCovers(resource, path) {
+			continue
+		}
+		resourceRoles, err := repository.CollectAll(ctx, func(ctx context.Context, page repository.PageRequest) (*repository.Page[*domain.Role], error) {
+			return s.repository.User.GetUserRolesOnResource(ctx, userID, resource, page)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get roles of %s on %s: %w", userID, resource, err)
+		}
+		roles = append(roles, resourceRoles...)
+	}
+	return roles, nil
+}
+
+// decide checks the user's global roles and, when the request names a
+// resource, the roles assigned to it on that resource. A deny matching the
+// permission in any of those roles overrides every allow. Grants whose
+// condition the request does not satisfy are ignored. Global checks are
+// answered from the user's effective permissions when they are conclusive.
+func (s *rbacServiceImpl) decide(ctx context.Context, request *domain.AccessRequest) (*domain.Decision, error) {
+	// Deleted users keep their role assignments until reaped, but must not be granted anything.
+	user, err := s.repository.User.GetUserByID(ctx, request.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return &domain.Decision{}, nil
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	// Global checks are usually answered by the permissions precomputed on the user.
+	if request.ResourceID == "" && user.Permissions != nil {
+		if decision := user.Permissions.Decide(request.PermissionID, time.Now()); decision != nil {
+			return decision, nil
+		}
+	}
+
+	// Direct roles and roles granted through group membership.
+	roles, err := s.cachedUserRoles(ctx, request.UserID)
 	if err != nil {
 		if errors.Is(err, repository.ErrNotFound) { // User might not exist or have no roles
-			return false, nil
+			return &domain.Decision{}, nil
+		}
+		return nil, err
+	}
+
+	if request.ResourceID != "" {
+		resourceRoles, err := s.rolesCoveringResource(ctx, request.UserID, domain.CleanResourcePath(request.ResourceID))
+		if err != nil {
+			return nil, err
 		}
-		return false, fmt.Errorf("service.UserHasPermission: failed to get user roles: %w", err)
+		roles = append(roles, resourceRoles...)
 	}
 
 	if len(roles) == 0 {
-		return false, nil // No roles, no permissions
+		return &domain.Decision{}, nil // No roles, no permissions
 	}
 
-	userPermissions := make(map[domain.PermissionID]bool)
+	grants, err := s.roleGrants(ctx, roles, s.cachedGrantsOfRole)
+	if err != nil {
+		return nil, err
+	}
 
-	for _, role := range roles {
-		permissions, err := s.repository.Role.GetRolePermissions(ctx, role.ID)
-		if err != nil {
-			// Log this error, but continue checking other roles might be appropriate
-			fmt.Printf("Warning: Could not get permissions for role %s: %v\n", role.ID, err)
+	// Allow and deny grants matching the permission, exactly or as a wildcard
+	// pattern such as document:* or *:read.
+	var allows, denies []*domain.PermissionGrant
+	for _, g := range grants {
+		if !g.PermissionID.Matches(request.PermissionID) {
 			continue
 		}
-		for _, p := range permissions {
-			userPermissions[p.ID] = true
+		if g.Effect == domain.EffectDeny {
+			denies = append(denies, g)
+		} else {
+			allows = append(allows, g)
+		}
+	}
+
+	attributes := conditionAttributes(user, request, time.Now())
+
+	for _, g := range denies {
+		// A deny whose condition cannot be evaluated, or references an
+		// attribute the check does not have, still applies.
+		if ok, _, err := g.Condition.Evaluate(attributes); ok || err != nil {
+			return &domain.Decision{DeniedBy: g.PermissionID}, nil
+		}
+	}
+
+	// Exact grants first, then the most specific pattern, so a failed
+	// condition is reported for the grant closest to the permission.
+	sort.SliceStable(allows, func(i, j int) bool {
+		return allows[i].PermissionID.MoreSpecific(allows[j].PermissionID)
+	})
+	decision := &domain.Decision{}
+	for _, g := range allows {
+		ok, failed, _ := g.Condition.Evaluate(attributes)
+		if ok {
+			return &domain.Decision{Allowed: true}, nil
+		}
+		if decision.FailedCondition == "" {
+			decision.FailedCondition = failed
+		}
+	}
+	return decision, nil
+}
+
+// conditionAttributes combines the request.* and resource.* attributes of a
+// check with the user's stored attributes. request.time (HH:MM, UTC) and
+// request.weekday (Mon..Sun) are set from now and request.ip from the source
+// IP of the check, whatever the caller passed for them.
+func conditionAttributes(user *domain.User, request *domain.AccessRequest, now time.Time) map[string]string {
+	attributes := make(map[string]string, len(request.Attributes)+len(user.Attributes)+4)
+	for name, value := range request.Attributes {
+		// user.* attributes only come from the stored user.
+		if (strings.HasPrefix(name, "request.") || strings.HasPrefix(name, "resource.")) && !domain.ServerAttribute(name) {
+			attributes[name] = value
 		}
 	}
 
-	return userPermissions[targetPermissionID], nil
+	now = now.UTC()
+	attributes[domain.AttributeRequestTime] = now.Format("15:04")
+	attributes[domain.AttributeRequestWeekday] = now.Format("Mon")
+	if request.SourceIP != "" {
+		attributes[domain.AttributeRequestIP] = request.SourceIP
+	}
+
+	for name, value := range user.Attributes {
+		attributes["user."+name] = value
+	}
+	attributes["user.id"] = string(user.ID)
+	return attributes
 }
@@ -0,0 +1,948 @@
+package service
+
+import (
+	"aws-dynamodb-store/internal/domain"
+	"aws-dynamodb-store/internal/repository"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	memoryrepo "aws-dynamodb-store/internal/repository/memory"
+)
+
+func TestRoleInheritance(t *testing.T) {
+	ctx := repository.WithTenant(context.Background(), "t1")
+	s := NewRBACService(memoryrepo.NewMemoryRepository(time.Hour))
+	user, err := s.CreateUser(ctx, "Alice", "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// viewer inherits from editor, which inherits from admin.
+	roles := make(map[string]*domain.Role)
+	for _, name := range []string{"viewer", "editor", "admin"} {
+		role, err := s.CreateRole(ctx, name, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		permission := domain.PermissionID("document:" + name)
+		if _, err := s.CreatePermission(ctx, permission, name, ""); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.AssignPermissionToRole(ctx, &domain.PermissionGrant{RoleID: role.ID, PermissionID: permission}); err != nil {
+			t.Fatal(err)
+		}
+		roles[name] = role
+	}
+	if err := s.AddParentRole(ctx, roles["viewer"].ID, roles["editor"].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddParentRole(ctx, roles["editor"].ID, roles["admin"].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AssignRoleToUser(ctx, &domain.RoleAssignment{UserID: user.ID, RoleID: roles["viewer"].ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		permission domain.PermissionID
+		want       bool
+	}{
+		{"document:viewer", true},
+		{"document:editor", true},
+		{"document:admin", true},
+		{"document:owner", false},
+	}
+	for _, tt := range tests {
+		got, err := s.UserHasPermission(ctx, user.ID, tt.permission)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("UserHasPermission(%s) = %v; want %v", tt.permission, got, tt.want)
+		}
+	}
+
+	cycles := []struct {
+		role   string
+		parent string
+	}{
+		{"admin", "viewer"},
+		{"editor", "viewer"},
+		{"admin", "admin"},
+	}
+	for _, tt := range cycles {
+		if err := s.AddParentRole(ctx, roles[tt.role].ID, roles[tt.parent].ID); !errors.Is(err, ErrRoleCycle) {
+			t.Errorf("AddParentRole(%s, %s): expected ErrRoleCycle; got %v", tt.role, tt.parent, err)
+		}
+	}
+
+	// Removing a parent takes its permissions away.
+	if err := s.RemoveParentRole(ctx, roles["editor"].ID, roles["admin"].ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.UserHasPermission(ctx, user.ID, "document:admin"); err != nil || got {
+		t.Errorf("expected document:admin to be gone with the parent; got %v, %v", got, err)
+	}
+}
+
+func TestGroupRoles(t *testing.T) {
+	ctx := repository.WithTenant(context.Background(), "t1")
+	s := NewRBACService(memoryrepo.NewMemoryRepository(time.Hour))
+	user, err := s.CreateUser(ctx, "Bob", "bob@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	role, err := s.CreateRole(ctx, "writer", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreatePermission(ctx, "document:write", "Write", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AssignPermissionToRole(ctx, &domain.PermissionGrant{RoleID: role.ID, PermissionID: "document:write"}); err != nil {
+		t.Fatal(err)
+	}
+	group, err := s.CreateGroup(ctx, "writers", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name        string
+		do          func() error
+		want        bool
+		wantMembers int
+	}{
+		{"join", func() error { return s.AddUserToGroup(ctx, user.ID, group.ID) }, false, 1},
+		{"assign role to group", func() error { return s.AssignRoleToGroup(ctx, group.ID, role.ID) }, true, 1},
+		{"remove role from group", func() error { return s.RemoveRoleFromGroup(ctx, group.ID, role.ID) }, false, 1},
+		{"assign role again", func() error { return s.AssignRoleToGroup(ctx, group.ID, role.ID) }, true, 1},
+		{"leave", func() error { return s.RemoveUserFromGroup(ctx, user.ID, group.ID) }, false, 0},
+		{"join again", func() error { return s.AddUserToGroup(ctx, user.ID, group.ID) }, true, 1},
+	}
+	for _, step := range steps {
+		if err := step.do(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		got, err := s.UserHasPermission(ctx, user.ID, "document:write")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != step.want {
+			t.Errorf("after %s: UserHasPermission = %v; want %v", step.name, got, step.want)
+		}
+		stored, err := s.GetGroup(ctx, group.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.MemberCount != step.wantMembers {
+			t.Errorf("after %s: expected %d members; got %d", step.name, step.wantMembers, stored.MemberCount)
+		}
+	}
+
+	// Deleting the group takes the roles held through it away.
+	if err := s.DeleteGroup(ctx, group.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.UserHasPermission(ctx, user.ID, "document:write"); err != nil || got {
+		t.Errorf("expected document:write to be gone with the group; got %v, %v", got, err)
+	}
+	if _, err := s.GetGroup(ctx, group.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a deleted group; got %v", err)
+	}
+}
+
+func TestTenantsAreIsolated(t *testing.T) {
+	s := NewRBACService(memoryrepo.NewMemoryRepository(time.Hour))
+	acme := repository.WithTenant(context.Background(), "acme")
+	user, err := s.CreateUser(acme, "Alice", "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	role, err := s.CreateRole(acme, "reader", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreatePermission(acme, "document:read", "Read", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AssignPermissionToRole(acme, &domain.PermissionGrant{RoleID: role.ID, PermissionID: "document:read"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AssignRoleToUser(acme, &domain.RoleAssignment{UserID: user.ID, RoleID: role.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		tenant  domain.TenantID
+		want    bool
+		wantGet error
+	}{
+		{"acme", true, nil},
+		{"globex", false, repository.ErrNotFound},
+	}
+	for _, tt := range tests {
+		ctx := repository.WithTenant(context.Background(), tt.tenant)
+		got, err := s.UserHasPermission(ctx, user.ID, "document:read")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: UserHasPermission = %v; want %v", tt.tenant, got, tt.want)
+		}
+		if _, err := s.GetUser(ctx, user.ID); !errors.Is(err, tt.wantGet) {
+			t.Errorf("%s: expected %v from GetUser; got %v", tt.tenant, tt.wantGet, err)
+		}
+	}
+
+	if _, err := s.GetUser(context.Background(), user.ID); !errors.Is(err, repository.ErrInvalidTenant) {
+		t.Errorf("expected ErrInvalidTenant without a tenant; got %v", err)
+	}
+}
+
+func TestWildcardGrants(t *testing.T) {
+	ctx := repository.WithTenant(context.Background(), "t1")
+	s := NewRBACService(memoryrepo.NewMemoryRepository(time.Hour))
+	user, err := s.CreateUser(ctx, "Alice", "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	role, err := s.CreateRole(ctx, "support", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pattern := range []domain.PermissionID{"document:*", "*:read", "billing:invoice:*"} {
+		if _, err := s.CreatePermission(ctx, pattern, string(pattern), ""); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.AssignPermissionToRole(ctx, &domain.PermissionGrant{RoleID: role.ID, PermissionID: pattern}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.AssignRoleToUser(ctx, &domain.RoleAssignment{UserID: user.ID, RoleID: role.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		permission domain.PermissionID
+		want       bool
+	}{
+		{"document:write", true},
+		{"document:page:delete", true},
+		{"report:read", true},
+		{"report:write", false},
+		{"report:page:read", false}, // * matches a single segment
+		{"billing:invoice:pay", true},
+		{"billing:refund", false},
+	}
+	for _, tt := range tests {
+		got, err := s.UserHasPermission(ctx, user.ID, tt.permission)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("UserHasPermission(%s) = %v; want %v", tt.permission, got, tt.want)
+		}
+	}
+
+	if _, err := s.CreatePermission(ctx, "doc*:read", "", ""); !errors.Is(err, ErrInvalidPermission) {
+		t.Errorf("expected ErrInvalidPermission for a partial wildcard; got %v", err)
+	}
+}
+
+func TestDenyOverridesAllow(t *testing.T) {
+	ctx := repository.WithTenant(context.Background(), "t1")
+	s := NewRBACService(memoryrepo.NewMemoryRepository(time.Hour))
+	user, err := s.CreateUser(ctx, "Alice", "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// editor allows broadly, auditor carves exceptions out of it and inherits
+	// one more from legal-hold.
+	grants := []struct {
+		role       string
+		permission domain.PermissionID
+		effect     domain.Effect
+	}{
+		{"editor", "document:*", domain.EffectAllow},
+		{"editor", "account:*:read", domain.EffectAllow},
+		{"auditor", "document:delete", domain.EffectDeny},
+		{"auditor", "account:finance:read", domain.EffectDeny},
+		{"legal-hold", "document:purge", domain.EffectDeny},
+	}
+	roles := make(map[string]*domain.Role)
+	for _, g := range grants {
+		role, ok := roles[g.role]
+		if !ok {
+			if role, err = s.CreateRole(ctx, g.role, ""); err != nil {
+				t.Fatal(err)
+			}
+			roles[g.role] = role
+		}
+		if _, err := s.CreatePermission(ctx, g.permission, string(g.permission), ""); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.AssignPermissionToRole(ctx, &domain.PermissionGrant{RoleID: role.ID, PermissionID: g.permission, Effect: g.effect}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.AddParentRole(ctx, roles["auditor"].ID, roles["legal-hold"].ID); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"editor", "auditor"} {
+		if err := s.AssignRoleToUser(ctx, &domain.RoleAssignment{UserID: user.ID, RoleID: roles[name].ID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		permission   domain.PermissionID
+		want         bool
+		wantDeniedBy domain.PermissionID
+	}{
+		{"document:read", true, ""},
+		{"document:delete", false, "document:delete"},
+		{"document:purge", false, "document:purge"}, // Inherited deny
+		{"account:sales:read", true, ""},
+		{"account:finance:read", false, "account:finance:read"},
+	}
+	for _, tt := range tests {
+		decision, err := s.CheckAccess(ctx, &domain.AccessRequest{UserID: user.ID, PermissionID: tt.permission})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decision.Allowed != tt.want || decision.DeniedBy != tt.wantDeniedBy {
+			t.Errorf("CheckAccess(%s) = %+v; want allowed=%v, deniedBy=%q", tt.permission, decision, tt.want, tt.wantDeniedBy)
+		}
+		got, err := s.UserHasPermission(ctx, user.ID, tt.permission)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("UserHasPermission(%s) = %v; want %v", tt.permission, got, tt.want)
+		}
+	}
+}
+
+func TestCheckAccessEvaluatesConditions(t *testing.T) {
+	ctx := repository.WithTenant(context.Background(), "t1")
+	s := NewRBACService(memoryrepo.NewMemoryRepository(time.Hour))
+	user, err := s.CreateUser(ctx, "Alice", "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.Attributes = map[string]string{"department": "finance"}
+	if err := s.UpdateUser(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+
+	grants := []struct {
+		role       string
+		permission domain.PermissionID
+		effect     domain.Effect
+		condition  domain.Condition
+	}{
+		{"reader", "report:read", domain.EffectAllow, `request.ip in "10.0.0.0/8"`},
+		{"reader", "report:export", domain.EffectAllow, "user.department == resource.department"},
+		{"classified", "report:read", domain.EffectDeny, `resource.classification == "secret"`},
+		// Stored least specific first, so the failed condition reported is
+		// that of the more specific grant only because allows are ordered.
+		{"invoicing", "*:invoice:create", domain.EffectAllow, `resource.region == "us"`},
+		{"invoicing", "billing:invoice:*", domain.EffectAllow, `resource.region == "eu"`},
+	}
+	for _, id := range []domain.PermissionID{"report:read", "report:export", "*:invoice:create", "billing:invoice:*"} {
+		if _, err := s.CreatePermission(ctx, id, string(id), ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	roles := make(map[string]*domain.Role)
+	for _, g := range grants {
+		role, ok := roles[g.role]
+		if !ok {
+			if role, err = s.CreateRole(ctx, g.role, ""); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.AssignRoleToUser(ctx, &domain.RoleAssignment{UserID: user.ID, RoleID: role.ID}); err != nil {
+				t.Fatal(err)
+			}
+			roles[g.role] = role
+		}
+		if err := s.AssignPermissionToRole(ctx, &domain.PermissionGrant{RoleID: role.ID, PermissionID: g.permission, Effect: g.effect, Condition: g.condition}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		request    domain.AccessRequest
+		want       bool
+		wantFailed string
+		wantDenied domain.PermissionID
+	}{
+		{
+			name:    "inside the network",
+			request: domain.AccessRequest{PermissionID: "report:read", SourceIP: "10.1.2.3", Attributes: map[string]string{"resource.classification": "public"}},
+			want:    true,
+		},
+		{
+			name:       "outside the network",
+			request:    domain.AccessRequest{PermissionID: "report:read", SourceIP: "192.168.1.1", Attributes: map[string]string{"resource.classification": "public"}},
+			wantFailed: `request.ip in "10.0.0.0/8"`,
+		},
+		{
+			name:       "caller cannot set request.ip",
+			request:    domain.AccessRequest{PermissionID: "report:read", SourceIP: "192.168.1.1", Attributes: map[string]string{"request.ip": "10.1.2.3", "resource.classification": "public"}},
+			wantFailed: `request.ip in "10.0.0.0/8"`,
+		},
+		{
+			name:       "secret resource",
+			request:    domain.AccessRequest{PermissionID: "report:read", SourceIP: "10.1.2.3", Attributes: map[string]string{"resource.classification": "secret"}},
+			wantDenied: "report:read",
+		},
+		{
+			name:       "unclassified resource", // A deny that cannot be decided applies
+			request:    domain.AccessRequest{PermissionID: "report:read", SourceIP: "10.1.2.3"},
+			wantDenied: "report:read",
+		},
+		{
+			name:    "own department",
+			request: domain.AccessRequest{PermissionID: "report:export", Attributes: map[string]string{"resource.department": "finance"}},
+			want:    true,
+		},
+		{
+			name:       "other department",
+			request:    domain.AccessRequest{PermissionID: "report:export", Attributes: map[string]string{"resource.department": "legal"}},
+			wantFailed: "user.department == resource.department",
+		},
+		{
+			name:       "caller cannot set user attributes",
+			request:    domain.AccessRequest{PermissionID: "report:export", Attributes: map[string]string{"user.department": "legal", "resource.department": "legal"}},
+			wantFailed: "user.department == resource.department",
+		},
+		{
+			name:    "less specific pattern allows",
+			request: domain.AccessRequest{PermissionID: "billing:invoice:create", Attributes: map[string]string{"resource.region": "us"}},
+			want:    true,
+		},
+		{
+			name:       "more specific pattern reported",
+			request:    domain.AccessRequest{PermissionID: "billing:invoice:create", Attributes: map[string]string{"resource.region": "ap"}},
+			wantFailed: `resource.region == "eu"`,
+		},
+	}
+	for _, tt := range tests {
+		tt.request.UserID = user.ID
+		decision, err := s.CheckAccess(ctx, &tt.request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decision.Allowed != tt.want || decision.FailedCondition != tt.wantFailed || decision.DeniedBy != tt.wantDenied {
+			t.Errorf("%s: unexpected decision %+v", tt.name, decision)
+		}
+	}
+
+	err = s.AssignPermissionToRole(ctx, &domain.PermissionGrant{RoleID: roles["reader"].ID, PermissionID: "report:read", Condition: "request.ip ~ 1"})
+	if !errors.Is(err, ErrInvalidGrant) {
+		t.Errorf("expected ErrInvalidGrant; got %v", err)
+	}
+}
+
+func TestResourceScopedRoles(t *testing.T) {
+	ctx := repository.WithTenant(context.Background(), "t1")
+	s := NewRBACService(memoryrepo.NewMemoryRepository(time.Hour))
+	user, err := s.CreateUser(ctx, "Alice", "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	role, err := s.CreateRole(ctx, "owner", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreatePermission(ctx, "document:write", "Write", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AssignPermissionToRole(ctx, &domain.PermissionGrant{RoleID: role.ID, PermissionID: "document:write"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, resource := range []string{"/org/acme/project/42", "org/acme/project/7"} {
+		if err := s.AssignRoleToUser(ctx, &domain.RoleAssignment{UserID: user.ID, RoleID: role.ID, ResourceID: resource}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = s.AssignRoleToUser(ctx, &domain.RoleAssignment{UserID: user.ID, RoleID: role.ID, ResourceID: "org/a#b"})
+	if !errors.Is(err, ErrInvalidAssignment) {
+		t.Errorf("expected ErrInvalidAssignment for a resource with '#'; got %v", err)
+	}
+	if err := s.RemoveRoleFromUserOnResource(ctx, user.ID, role.ID, "org/acme/project/7"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		resource string
+		want     bool
+	}{
+		{"org/acme/project/42", true},
+		{"/org/acme/project/42/", true},
+		{"org/acme/project/7", false}, // Removed
+		{"org/acme/project/43", false},
+	}
+	for _, tt := range tests {
+		got, err := s.UserHasPermissionOnResource(ctx, user.ID, "document:write", tt.resource)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("UserHasPermissionOnResource(%s) = %v; want %v", tt.resource, got, tt.want)
+		}
+	}
+
+	// A role held on a resource grants nothing globally.
+	if got, err := s.UserHasPermission(ctx, user.ID, "document:write"); err != nil || got {
+		t.Errorf("expected no global access; got %v, %v", got, err)
+	}
+}
+
+func TestAssignmentWindows(t *testing.T) {
+	now := time.Now()
+	at := func(d time.Duration) *time.Time {
+		v := now.Add(d)
+		return &v
+	}
+
+	tests := []struct {
+		name      string
+		notBefore *time.Time
+		expiresAt *time.Time
+		want      bool
+		wantErr   error
+	}{
+		{name: "no window", want: true},
+		{name: "started", notBefore: at(-time.Hour), want: true},
+		{name: "not started", notBefore: at(time.Hour), want: false},
+		{name: "not expired", expiresAt: at(time.Hour), want: true},
+		{name: "started and not expired", notBefore: at(-time.Hour), expiresAt: at(time.Hour), want: true},
+		{name: "expired", expiresAt: at(-time.Hour), wantErr: ErrInvalidAssignment},
+		{name: "empty window", notBefore: at(2 * time.Hour), expiresAt: at(time.Hour), wantErr: ErrInvalidAssignment},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := repository.WithTenant(context.Background(), "t1")
+			s := NewRBACService(memoryrepo.NewMemoryRepository(time.Hour))
+			user, err := s.CreateUser(ctx, "Alice", "alice@example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			role, err := s.CreateRole(ctx, "oncall", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.CreatePermission(ctx, "incident:resolve", "Resolve incidents", ""); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.AssignPermissionToRole(ctx, &domain.PermissionGrant{RoleID: role.ID, PermissionID: "incident:resolve"}); err != nil {
+				t.Fatal(err)
+			}
+
+			err = s.AssignRoleToUser(ctx, &domain.RoleAssignment{UserID: user.ID, RoleID: role.ID, NotBefore: tt.notBefore, ExpiresAt: tt.expiresAt})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v; got %v", tt.wantErr, err)
+			}
+			got, err := s.UserHasPermission(ctx, user.ID, "incident:resolve")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("UserHasPermission = %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResourceRolesApplyBeneathTheirPath(t *testing.T) {
+	ctx := repository.WithTenant(context.Background(), "t1")
+	s := NewRBACService(memoryrepo.NewMemoryRepository(time.Hour))
+	user, err := s.CreateUser(ctx, "Alice", "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bindings := []struct {
+		role       string
+		permission domain.PermissionID
+		resource   string
+	}{
+		{"viewer", "document:read", "org/acme"},
+		{"editor", "document:write", "org/acme/project/42"},
+	}
+	for _, b := range bindings {
+		role, err := s.CreateRole(ctx, b.role, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.CreatePermission(ctx, b.permission, string(b.permission), ""); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.AssignPermissionToRole(ctx, &domain.PermissionGrant{RoleID: role.ID, PermissionID: b.permission}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.AssignRoleToUser(ctx, &domain.RoleAssignment{UserID: user.ID, RoleID: role.ID, ResourceID: b.resource}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		resource   string
+		permission domain.PermissionID
+		want       bool
+	}{
+		{"org/acme", "document:read", true},
+		{"org/acme/project/42/doc/7", "document:read", true},
+		{"org/acme/project/42/doc/7", "document:write", true},
+		{"org/acme/project/42", "document:write", true},
+		{"org/acme/project/420", "document:write", false}, // Not beneath project/42
+		{"org/acme", "document:write", false},             // Above the binding
+		{"org/acmecorp", "document:read", false},
+		{"org", "document:read", false},
+	}
+	for _, tt := range tests {
+		got, err := s.UserHasPermissionOnResource(ctx, user.ID, tt.permission, tt.resource)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("UserHasPermissionOnResource(%s, %s) = %v; want %v", tt.permission, tt.resource, got, tt.want)
+		}
+	}
+}
+
+func TestSoftDeletedEntitiesGrantNothing(t *testing.T) {
+	type action func(s RBACService, ctx context.Context, user *domain.User, role *domain.Role) error
+
+	tests := []struct {
+		name    string
+		delete  action
+		restore action
+		get     action
+	}{
+		{
+			name: "role",
+			delete: func(s RBACService, ctx context.Context, user *domain.User, role *domain.Role) error {
+				return s.DeleteRole(ctx, role.ID)
+			},
+			restore: func(s RBACService, ctx context.Context, user *domain.User, role *domain.Role) error {
+				return s.RestoreRole(ctx, role.ID)
+			},
+			get: func(s RBACService, ctx context.Context, user *domain.User, role *domain.Role) error {
+				_, err := s.GetRole(ctx, role.ID)
+				return err
+			},
+		},
+		{
+			name: "permission",
+			delete: func(s RBACService, ctx context.Context, user *domain.User, role *domain.Role) error {
+				return s.DeletePermission(ctx, "document:read")
+			},
+			restore: func(s RBACService, ctx context.Context, user *domain.User, role *domain.Role) error {
+				return s.RestorePermission(ctx, "document:read")
+			},
+			get: func(s RBACService, ctx context.Context, user *domain.User, role *domain.Role) error {
+				_, err := s.GetPermission(ctx, "document:read")
+				return err
+			},
+		},
+		{
+			name: "user",
+			delete: func(s RBACService, ctx context.Context, user *domain.User, role *domain.Role) error {
+				return s.DeleteUser(ctx, user.ID)
+			},
+			restore: func(s RBACService, ctx context.Context, user *domain.User, role *domain.Role) error {
+				return s.RestoreUser(ctx, user.ID)
+			},
+			get: func(s RBACService, ctx context.Context, user *domain.User, role *domain.Role) error {
+				_, err := s.GetUser(ctx, user.ID)
+				return err
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := repository.WithTenant(context.Background(), "t1")
+			s := NewRBACService(memoryrepo.NewMemoryRepository(time.Hour))
+			user, err := s.CreateUser(ctx, "Alice", "alice@example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			role, err := s.CreateRole(ctx, "reader", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.CreatePermission(ctx, "document:read", "Read", ""); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.AssignPermissionToRole(ctx, &domain.PermissionGrant{RoleID: role.ID, PermissionID: "document:read"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.AssignRoleToUser(ctx, &domain.RoleAssignment{UserID: user.ID, RoleID: role.ID}); err != nil {
+				t.Fatal(err)
+			}
+
+			steps := []struct {
+				name    string
+				do      action
+				want    bool
+				wantGet error
+			}{
+				{"delete", tt.delete, false, repository.ErrNotFound},
+				{"restore", tt.restore, true, nil},
+			}
+			for _, step := range steps {
+				if err := step.do(s, ctx, user, role); err != nil {
+					t.Fatalf("%s: %v", step.name, err)
+				}
+				got, err := s.UserHasPermission(ctx, user.ID, "document:read")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != step.want {
+					t.Errorf("after %s: UserHasPermission = %v; want %v", step.name, got, step.want)
+				}
+				if err := tt.get(s, ctx, user, role); !errors.Is(err, step.wantGet) {
+					t.Errorf("after %s: expected %v from get; got %v", step.name, step.wantGet, err)
+				}
+			}
+		})
+	}
+}
+
+func TestEffectivePermissionsAreStored(t *testing.T) {
+	ctx := repository.WithTenant(context.Background(), "t1")
+	repo := memoryrepo.NewMemoryRepository(time.Hour)
+	s := NewRBACService(repo)
+	user, err := s.CreateUser(ctx, "Alice", "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	role, err := s.CreateRole(ctx, "editor", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []domain.PermissionID{"document:read", "document:write", "document:delete", "report:read"} {
+		if _, err := s.CreatePermission(ctx, id, string(id), ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	grant := func(id domain.PermissionID, effect domain.Effect, condition domain.Condition) func() error {
+		return func() error {
+			return s.AssignPermissionToRole(ctx, &domain.PermissionGrant{RoleID: role.ID, PermissionID: id, Effect: effect, Condition: condition})
+		}
+	}
+
+	steps := []struct {
+		name            string
+		do              func() error
+		wantAllowed     []domain.PermissionID
+		wantDenied      []domain.PermissionID
+		wantConditional []domain.PermissionID
+	}{
+		{
+			name: "assign role",
+			do:   func() error { return s.AssignRoleToUser(ctx, &domain.RoleAssignment{UserID: user.ID, RoleID: role.ID}) },
+		},
+		{
+			name:        "allow read",
+			do:          grant("document:read", domain.EffectAllow, ""),
+			wantAllowed: []domain.PermissionID{"document:read"},
+		},
+		{
+			name:        "allow write",
+			do:          grant("document:write", domain.EffectAllow, ""),
+			wantAllowed: []domain.PermissionID{"document:read", "document:write"},
+		},
+		{
+			name:        "deny delete",
+			do:          grant("document:delete", domain.EffectDeny, ""),
+			wantAllowed: []domain.PermissionID{"document:read", "document:write"},
+			wantDenied:  []domain.PermissionID{"document:delete"},
+		},
+		{
+			name:            "allow reports conditionally",
+			do:              grant("report:read", domain.EffectAllow, `request.ip in "10.0.0.0/8"`),
+			wantAllowed:     []domain.PermissionID{"document:read", "document:write"},
+			wantDenied:      []domain.PermissionID{"document:delete"},
+			wantConditional: []domain.PermissionID{"report:read"},
+		},
+		{
+			name:            "remove write",
+			do:              func() error { return s.RemovePermissionFromRole(ctx, role.ID, "document:write") },
+			wantAllowed:     []domain.PermissionID{"document:read"},
+			wantDenied:      []domain.PermissionID{"document:delete"},
+			wantConditional: []domain.PermissionID{"report:read"},
+		},
+		{
+			name: "remove role",
+			do:   func() error { return s.RemoveRoleFromUser(ctx, user.ID, role.ID) },
+		},
+	}
+	for _, step := range steps {
+		if err := step.do(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		stored, err := repo.User.GetUserByID(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.Permissions == nil {
+			t.Fatalf("%s: expected effective permissions to be stored", step.name)
+		}
+		if !slices.Equal(stored.Permissions.Allowed, step.wantAllowed) ||
+			!slices.Equal(stored.Permissions.Denied, step.wantDenied) ||
+			!slices.Equal(stored.Permissions.Conditional, step.wantConditional) {
+			t.Errorf("%s: unexpected effective permissions %+v", step.name, stored.Permissions)
+		}
+	}
+}
+
+func TestDeferredFanOut(t *testing.T) {
+	// Each case changes something that reaches the user through the role
+	// or the group, and recomputes what the change-stream processor would.
+	tests := []struct {
+		name      string
+		change    func(s RBACService, ctx context.Context, role *domain.Role, group *domain.Group) error
+		recompute func(s RBACService, ctx context.Context, role *domain.Role, group *domain.Group) error
+	}{
+		{
+			name: "role grant",
+			change: func(s RBACService, ctx context.Context, role *domain.Role, group *domain.Group) error {
+				return s.AssignPermissionToRole(ctx, &domain.PermissionGrant{RoleID: role.ID, PermissionID: "document:write"})
+			},
+			recompute: func(s RBACService, ctx context.Context, role *domain.Role, group *domain.Group) error {
+				return s.RecomputeRoleHolders(ctx, role.ID)
+			},
+		},
+		{
+			name: "group role",
+			change: func(s RBACService, ctx context.Context, role *domain.Role, group *domain.Group) error {
+				writer, err := s.CreateRole(ctx, "writer", "")
+				if err != nil {
+					return err
+				}
+				if err := s.AssignPermissionToRole(ctx, &domain.PermissionGrant{RoleID: writer.ID, PermissionID: "document:write"}); err != nil {
+					return err
+				}
+				return s.AssignRoleToGroup(ctx, group.ID, writer.ID)
+			},
+			recompute: func(s RBACService, ctx context.Context, role *domain.Role, group *domain.Group) error {
+				return s.RecomputeGroupMembers(ctx, group.ID)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := repository.WithTenant(context.Background(), "t1")
+			repo := memoryrepo.NewMemoryRepository(time.Hour)
+			s := NewRBACService(repo, WithDeferredFanOut())
+			user, err := s.CreateUser(ctx, "Alice", "alice@example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			role, err := s.CreateRole(ctx, "editor", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.CreatePermission(ctx, "document:write", "Write", ""); err != nil {
+				t.Fatal(err)
+			}
+			group, err := s.CreateGroup(ctx, "writers", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := s.AssignRoleToUser(ctx, &domain.RoleAssignment{UserID: user.ID, RoleID: role.ID}); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.AddUserToGroup(ctx, user.ID, group.ID); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := tt.change(s, ctx, role, group); err != nil {
+				t.Fatal(err)
+			}
+			stored, err := repo.User.GetUserByID(ctx, user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if slices.Contains(stored.Permissions.Allowed, "document:write") {
+				t.Fatalf("expected the user to be left alone; got %v", stored.Permissions.Allowed)
+			}
+
+			if err := tt.recompute(s, ctx, role, group); err != nil {
+				t.Fatal(err)
+			}
+			stored, err = repo.User.GetUserByID(ctx, user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Contains(stored.Permissions.Allowed, "document:write") {
+				t.Errorf("expected the recompute to add document:write; got %v", stored.Permissions.Allowed)
+			}
+		})
+	}
+}
+
+func TestUpdatesDetectConflicts(t *testing.T) {
+	ctx := repository.WithTenant(context.Background(), "t1")
+	s := NewRBACService(memoryrepo.NewMemoryRepository(time.Hour))
+	user, err := s.CreateUser(ctx, "Alice", "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	role, err := s.CreateRole(ctx, "editor", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	permission, err := s.CreatePermission(ctx, "document:read", "Read", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each update renames a copy carrying version and returns the version it
+	// was saved with.
+	tests := []struct {
+		name   string
+		update func(version int64) (int64, error)
+	}{
+		{"user", func(version int64) (int64, error) {
+			u := *user
+			u.Version, u.DisplayName = version, "Alice A."
+			err := s.UpdateUser(ctx, &u)
+			return u.Version, err
+		}},
+		{"role", func(version int64) (int64, error) {
+			r := *role
+			r.Version, r.DisplayName = version, "Editor"
+			err := s.UpdateRole(ctx, &r)
+			return r.Version, err
+		}},
+		{"permission", func(version int64) (int64, error) {
+			p := *permission
+			p.Version, p.DisplayName = version, "Read documents"
+			err := s.UpdatePermission(ctx, &p)
+			return p.Version, err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, err := tt.update(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if version != 2 {
+				t.Errorf("expected version 2; got %d", version)
+			}
+			if _, err := tt.update(1); !errors.Is(err, repository.ErrConflict) {
+				t.Errorf("expected ErrConflict for a stale version; got %v", err)
+			}
+		})
+	}
+}